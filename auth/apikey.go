@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"alice-tss/types"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// HeaderAPIKey carries a static API key.
+const HeaderAPIKey = "X-API-Key"
+
+type apiKey struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// apiKeys compares hashes of the keys in constant time, so that neither the
+// length nor a prefix of a key leaks through timing.
+type apiKeys []apiKey
+
+func newAPIKeys(configs []types.APIKeyConfig) (apiKeys, error) {
+	keys := make(apiKeys, 0, len(configs))
+	for _, config := range configs {
+		if config.Name == "" || config.Key == "" {
+			return nil, errors.New("api keys need a name and a key")
+		}
+		keys = append(keys, apiKey{name: config.Name, hash: sha256.Sum256([]byte(config.Key))})
+	}
+	return keys, nil
+}
+
+func (k apiKeys) authenticate(req *Request) (*Principal, error) {
+	key := req.Header(HeaderAPIKey)
+	if key == "" {
+		return nil, errNoCredentials
+	}
+	hash := sha256.Sum256([]byte(key))
+	var name string
+	for _, candidate := range k {
+		if subtle.ConstantTimeCompare(hash[:], candidate.hash[:]) == 1 {
+			name = candidate.name
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("unknown api key")
+	}
+	return &Principal{Subject: name, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,146 @@
+// Package auth authenticates callers of the JSON-RPC and gRPC APIs. Both
+// transports turn an incoming call into a Request, so that every method is
+// enforced the same way whatever the caller uses.
+package auth
+
+import (
+	"alice-tss/types"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MethodAPIKey        = "api-key"
+	MethodJWT           = "jwt"
+	MethodMTLS          = "mtls"
+	MethodSignedRequest = "signed-request"
+)
+
+var (
+	// ErrUnauthenticated is returned when a caller cannot be authenticated.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// errNoCredentials is returned by a method when the request carries none
+	// of its credentials, so that the next method is tried.
+	errNoCredentials = errors.New("no credentials")
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Subject identifies the caller: an API key name, a JWT subject, a
+	// certificate common name or an Ethereum address.
+	Subject string `json:"subject"`
+	// Method is the authentication method that identified the caller.
+	Method string `json:"method"`
+}
+
+// String returns the subject of p qualified by its method, e.g. "jwt:alice",
+// so that callers of different methods sharing a subject are told apart.
+// Access rules, admins, job owners and client webhooks name callers this way.
+func (p *Principal) String() string {
+	return fmt.Sprintf("%s:%s", p.Method, p.Subject)
+}
+
+// CheckSubject checks that subject names a caller as Principal.String does.
+func CheckSubject(subject string) error {
+	method, name, ok := strings.Cut(subject, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("subject %q is not qualified by an authentication method, e.g. %s:%s", subject, MethodAPIKey, subject)
+	}
+	switch method {
+	case MethodAPIKey, MethodJWT, MethodMTLS, MethodSignedRequest:
+		return nil
+	}
+	return fmt.Errorf("subject %q has an unknown authentication method %q", subject, method)
+}
+
+// Request is what a transport knows about a call.
+type Request struct {
+	// Method is the called method, e.g. "signer.SignMessage" or
+	// "/pb.TssService/SignMessage".
+	Method string
+	// Header returns the value of an HTTP header or gRPC metadata key.
+	Header func(name string) string
+	// Certificates is the verified client certificate chain, leaf first.
+	Certificates []*x509.Certificate
+	// Body is the HTTP body of a JSON-RPC call, or the deterministic protobuf
+	// encoding of a unary gRPC request.
+	Body []byte
+}
+
+type method interface {
+	authenticate(req *Request) (*Principal, error)
+}
+
+// Authenticator authenticates requests with the methods of an AuthConfig.
+type Authenticator struct {
+	methods []method
+}
+
+// New returns the authenticator configured by config, or nil when no method
+// is configured and authentication is disabled.
+func New(config types.AuthConfig) (*Authenticator, error) {
+	var methods []method
+	if len(config.APIKeys) > 0 {
+		m, err := newAPIKeys(config.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+	if config.JWT.Secret != "" || config.JWT.PublicKeyFile != "" {
+		m, err := newJWT(config.JWT)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+	if len(config.SignedRequests.Addresses) > 0 {
+		m, err := newSignedRequests(config.SignedRequests)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+	// Certificates come last: a caller may present one and still
+	// authenticate as someone else with a token.
+	if config.MTLS {
+		methods = append(methods, mtls{})
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+	return &Authenticator{methods: methods}, nil
+}
+
+// Authenticate returns the caller of req, or an error wrapping
+// ErrUnauthenticated.
+func (a *Authenticator) Authenticate(req *Request) (*Principal, error) {
+	for _, m := range a.methods {
+		principal, err := m.authenticate(req)
+		if errors.Is(err, errNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+		return principal, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, errNoCredentials)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the caller p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller carried by ctx, if any. It is nil when
+// authentication is disabled.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"alice-tss/types"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// HeaderAuthorization carries a JWT as "Bearer <token>".
+const HeaderAuthorization = "Authorization"
+
+type jwtMethod struct {
+	secret    []byte
+	publicKey *ecdsa.PublicKey
+	parser    *jwt.Parser
+}
+
+func newJWT(config types.JWTConfig) (*jwtMethod, error) {
+	m := &jwtMethod{}
+	var algorithms []string
+	if config.Secret != "" {
+		m.secret = []byte(config.Secret)
+		algorithms = append(algorithms, "HS256", "HS384", "HS512")
+	}
+	if config.PublicKeyFile != "" {
+		data, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		m.publicKey, err = jwt.ParseECPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt public key %s: %w", config.PublicKeyFile, err)
+		}
+		algorithms = append(algorithms, "ES256", "ES384", "ES512")
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithExpirationRequired()}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	m.parser = jwt.NewParser(options...)
+	return m, nil
+}
+
+func (m *jwtMethod) authenticate(req *Request) (*Principal, error) {
+	header := req.Header(HeaderAuthorization)
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, errNoCredentials
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := m.parser.ParseWithClaims(strings.TrimSpace(token), &claims, m.key)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{Subject: claims.Subject, Method: MethodJWT}, nil
+}
+
+// key returns the key matching the algorithm of token. The parser has
+// already checked that the algorithm is one of the configured ones.
+func (m *jwtMethod) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return m.secret, nil
+	case *jwt.SigningMethodECDSA:
+		return m.publicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
@@ -0,0 +1,18 @@
+package auth
+
+import "errors"
+
+// mtls identifies callers by the common name of their client certificate. The
+// transport has already verified the chain against tls.clientCAFile.
+type mtls struct{}
+
+func (mtls) authenticate(req *Request) (*Principal, error) {
+	if len(req.Certificates) == 0 {
+		return nil, errNoCredentials
+	}
+	name := req.Certificates[0].Subject.CommonName
+	if name == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	return &Principal{Subject: name, Method: MethodMTLS}, nil
+}
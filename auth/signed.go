@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"alice-tss/types"
+	"alice-tss/utils"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Headers of a signed request.
+const (
+	HeaderSignatureAddress  = "X-Signature-Address"
+	HeaderSignatureIssuedAt = "X-Signature-Issued-At"
+	HeaderSignatureNonce    = "X-Signature-Nonce"
+	HeaderSignature         = "X-Signature"
+)
+
+const defaultSignedRequestMaxAge = 5 * time.Minute
+
+// SignedRequestMessage returns the message that address signs, with
+// personal_sign (EIP-191), to call method with body. It follows the layout of
+// Sign-In With Ethereum (EIP-4361) messages, bound to a single request.
+func SignedRequestMessage(domain string, address common.Address, method, nonce string, issuedAt time.Time, body []byte) string {
+	return fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nURI: %s\nNonce: %s\nIssued At: %s\nRequest Hash: %s",
+		domain, address.Hex(), method, nonce, issuedAt.UTC().Format(time.RFC3339), hexutil.Encode(crypto.Keccak256(body)))
+}
+
+// SignRequest returns the X-Signature header of a request signed by key, as a
+// client computes it.
+func SignRequest(key *ecdsa.PrivateKey, domain, method, nonce string, issuedAt time.Time, body []byte) (string, error) {
+	message := SignedRequestMessage(domain, crypto.PubkeyToAddress(key.PublicKey), method, nonce, issuedAt, body)
+	signature, err := crypto.Sign(utils.EthSignMessage([]byte(message)), key)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(signature), nil
+}
+
+type signedRequests struct {
+	domain    string
+	addresses map[common.Address]bool
+	maxAge    time.Duration
+	now       func() time.Time
+
+	// nonces holds the nonces seen within maxAge, so that a signed request
+	// cannot be replayed.
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func newSignedRequests(config types.SignedRequestConfig) (*signedRequests, error) {
+	if config.Domain == "" {
+		return nil, errors.New("signed requests need a domain")
+	}
+	m := &signedRequests{
+		domain:    config.Domain,
+		addresses: make(map[common.Address]bool),
+		maxAge:    config.MaxAge,
+		now:       time.Now,
+		nonces:    make(map[string]time.Time),
+	}
+	if m.maxAge <= 0 {
+		m.maxAge = defaultSignedRequestMaxAge
+	}
+	for _, address := range config.Addresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid signer address %q", address)
+		}
+		m.addresses[common.HexToAddress(address)] = true
+	}
+	return m, nil
+}
+
+func (m *signedRequests) authenticate(req *Request) (*Principal, error) {
+	header := req.Header(HeaderSignature)
+	if header == "" {
+		return nil, errNoCredentials
+	}
+	addressHeader := req.Header(HeaderSignatureAddress)
+	if !common.IsHexAddress(addressHeader) {
+		return nil, fmt.Errorf("invalid %s header", HeaderSignatureAddress)
+	}
+	address := common.HexToAddress(addressHeader)
+	if !m.addresses[address] {
+		return nil, fmt.Errorf("signer %s is not allowed", address.Hex())
+	}
+	nonce := req.Header(HeaderSignatureNonce)
+	if len(nonce) < 8 {
+		return nil, fmt.Errorf("%s must be at least 8 characters", HeaderSignatureNonce)
+	}
+	issuedAt, err := time.Parse(time.RFC3339, req.Header(HeaderSignatureIssuedAt))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", HeaderSignatureIssuedAt, err)
+	}
+	now := m.now()
+	if age := now.Sub(issuedAt); age > m.maxAge || age < -m.maxAge {
+		return nil, errors.New("signed request expired")
+	}
+
+	signature, err := hexutil.Decode(header)
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("invalid %s header", HeaderSignature)
+	}
+	// Wallets produce v = 27 or 28.
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	message := SignedRequestMessage(m.domain, address, req.Method, nonce, issuedAt, req.Body)
+	pubkey, err := crypto.SigToPub(utils.EthSignMessage([]byte(message)), signature)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != address {
+		return nil, errors.New("signature does not match the signer address")
+	}
+	if err := m.useNonce(address.Hex()+"/"+nonce, now); err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: address.Hex(), Method: MethodSignedRequest}, nil
+}
+
+// useNonce records a nonce, failing if it was already used within maxAge.
+// Older nonces are forgotten: their requests have expired anyway.
+func (m *signedRequests) useNonce(key string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for seen, at := range m.nonces {
+		if now.Sub(at) > 2*m.maxAge {
+			delete(m.nonces, seen)
+		}
+	}
+	if _, ok := m.nonces[key]; ok {
+		return errors.New("nonce already used")
+	}
+	m.nonces[key] = now
+	return nil
+}
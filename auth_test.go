@@ -0,0 +1,253 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/server"
+	"alice-tss/types"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func authRequest(method string, body []byte, headers map[string]string) *auth.Request {
+	return &auth.Request{
+		Method: method,
+		Header: func(name string) string { return headers[name] },
+		Body:   body,
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	authenticator, err := auth.New(types.AuthConfig{})
+	if err != nil || authenticator != nil {
+		t.Fatalf("got %v, %v without any method", authenticator, err)
+	}
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	authenticator, err := auth.New(types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "service-a", Key: "secret-a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := authenticator.Authenticate(authRequest("signer.SignMessage", nil, map[string]string{auth.HeaderAPIKey: "secret-a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "service-a" || principal.Method != auth.MethodAPIKey {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+	for _, headers := range []map[string]string{{auth.HeaderAPIKey: "secret-b"}, {}} {
+		if _, err := authenticator.Authenticate(authRequest("signer.SignMessage", nil, headers)); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("got %v for headers %v", err, headers)
+		}
+	}
+}
+
+func TestAuthJWT(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(publicKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(types.AuthConfig{JWT: types.JWTConfig{
+		Secret:        "hmac secret",
+		PublicKeyFile: publicKeyFile,
+		Issuer:        "issuer",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.RegisteredClaims{
+		Subject:   "service-b",
+		Issuer:    "issuer",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("hmac secret"))
+	es, _ := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(ecKey)
+	for _, token := range []string{hs, es} {
+		principal, err := authenticator.Authenticate(authRequest("signer.SignMessage", nil, map[string]string{auth.HeaderAuthorization: "Bearer " + token}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if principal.Subject != "service-b" || principal.Method != auth.MethodJWT {
+			t.Fatalf("unexpected principal %+v", principal)
+		}
+	}
+
+	expired := claims
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := claims
+	wrongIssuer.Issuer = "someone else"
+	noExpiry := claims
+	noExpiry.ExpiresAt = nil
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("other secret"))
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	rejected := []string{forged, none}
+	for _, c := range []jwt.RegisteredClaims{expired, wrongIssuer, noExpiry} {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte("hmac secret"))
+		rejected = append(rejected, token)
+	}
+	for i, token := range rejected {
+		_, err := authenticator.Authenticate(authRequest("signer.SignMessage", nil, map[string]string{auth.HeaderAuthorization: "Bearer " + token}))
+		if !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("token %d: got %v", i, err)
+		}
+	}
+}
+
+func TestAuthSignedRequest(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	authenticator, err := auth.New(types.AuthConfig{SignedRequests: types.SignedRequestConfig{
+		Domain:    "tss.example.com",
+		Addresses: []string{address},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"method":"signer.SignMessage"}`)
+	signed := func(key *ecdsa.PrivateKey, nonce string, issuedAt time.Time) map[string]string {
+		signature, err := auth.SignRequest(key, "tss.example.com", "signer.SignMessage", nonce, issuedAt, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]string{
+			auth.HeaderSignatureAddress:  crypto.PubkeyToAddress(key.PublicKey).Hex(),
+			auth.HeaderSignatureIssuedAt: issuedAt.UTC().Format(time.RFC3339),
+			auth.HeaderSignatureNonce:    nonce,
+			auth.HeaderSignature:         signature,
+		}
+	}
+
+	headers := signed(key, "nonce-0001", time.Now())
+	principal, err := authenticator.Authenticate(authRequest("signer.SignMessage", body, headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != address || principal.Method != auth.MethodSignedRequest {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+	if _, err := authenticator.Authenticate(authRequest("signer.SignMessage", body, headers)); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("got %v when replaying a signed request", err)
+	}
+
+	tampered := signed(key, "nonce-0002", time.Now())
+	stale := signed(key, "nonce-0003", time.Now().Add(-time.Hour))
+	stranger := signed(other, "nonce-0004", time.Now())
+	impostor := signed(other, "nonce-0005", time.Now())
+	impostor[auth.HeaderSignatureAddress] = address
+	cases := map[string]*auth.Request{
+		"tampered body":   authRequest("signer.SignMessage", []byte(`{"method":"signer.Reshare"}`), tampered),
+		"other method":    authRequest("signer.Reshare", body, signed(key, "nonce-0006", time.Now())),
+		"stale":           authRequest("signer.SignMessage", body, stale),
+		"unknown signer":  authRequest("signer.SignMessage", body, stranger),
+		"wrong signature": authRequest("signer.SignMessage", body, impostor),
+	}
+	for name, req := range cases {
+		if _, err := authenticator.Authenticate(req); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestAuthMTLS(t *testing.T) {
+	authenticator, err := auth.New(types.AuthConfig{MTLS: true, APIKeys: []types.APIKeyConfig{{Name: "service-a", Key: "secret-a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "service-c"}}
+	req := authRequest("signer.SignMessage", nil, map[string]string{})
+	req.Certificates = []*x509.Certificate{cert}
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "service-c" || principal.Method != auth.MethodMTLS {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	// a token takes precedence over the certificate
+	req = authRequest("signer.SignMessage", nil, map[string]string{auth.HeaderAPIKey: "secret-a"})
+	req.Certificates = []*x509.Certificate{cert}
+	if principal, err = authenticator.Authenticate(req); err != nil || principal.Subject != "service-a" {
+		t.Fatalf("got %+v, %v", principal, err)
+	}
+}
+
+func TestAuthTransports(t *testing.T) {
+	authenticator, err := auth.New(types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "service-a", Key: "secret-a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen *auth.Principal
+	handler := server.AuthHandler(authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.FromContext(r.Context())
+	}))
+	body := `{"jsonrpc":"2.0","method":"signer.SignMessage","params":[],"id":"7"}`
+	req := httptest.NewRequest(http.MethodPost, "/tss", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d without credentials", rec.Code)
+	}
+	var reply struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil || reply.Error.Code == 0 || reply.ID != "7" {
+		t.Fatalf("got %s", rec.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPost, "/tss", strings.NewReader(body))
+	req.Header.Set(auth.HeaderAPIKey, "secret-a")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Subject != "service-a" {
+		t.Fatalf("handler saw principal %+v", seen)
+	}
+
+	interceptor := server.UnaryAuthInterceptor(authenticator)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.TssService/SignMessage"}
+	call := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return auth.FromContext(ctx), nil
+	}
+	if _, err := interceptor(context.Background(), nil, info, call); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v without credentials", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "secret-a"))
+	principal, err := interceptor(ctx, nil, info, call)
+	if err != nil || principal.(*auth.Principal).Subject != "service-a" {
+		t.Fatalf("got %v, %v", principal, err)
+	}
+}
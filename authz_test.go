@@ -0,0 +1,247 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/types"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type rpcReply struct {
+	Result struct {
+		Data json.RawMessage
+	} `json:"result"`
+	Error json.RawMessage `json:"error"`
+}
+
+// rpcCall calls method on handler as the caller of apiKey. It returns the
+// reply data, or fails the test unless the call is expected to fail.
+func rpcCall(t *testing.T, handler http.Handler, apiKey, method string, params interface{}, wantErr bool) json.RawMessage {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  []interface{}{params},
+		"id":      "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var reply rpcReply
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("%s: %v in %s", method, err, rec.Body.String())
+	}
+	failed := len(reply.Error) > 0 && string(reply.Error) != "null"
+	if failed != wantErr {
+		t.Fatalf("%s as %q: got error %s, want error %v", method, apiKey, reply.Error, wantErr)
+	}
+	if failed && !strings.Contains(string(reply.Error), "denied") && !strings.Contains(string(reply.Error), "unauthenticated") {
+		t.Fatalf("%s as %q: unexpected error %s", method, apiKey, reply.Error)
+	}
+	return reply.Result.Data
+}
+
+func listedKeys(t *testing.T, data json.RawMessage) []string {
+	t.Helper()
+	var page types.KeyPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for _, key := range page.Keys {
+		hashes = append(hashes, key.Hash)
+	}
+	return hashes
+}
+
+func TestAccessControl(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	for _, hash := range []string{"0x01", "0x02"} {
+		if err := storeDB.SaveDKGResultData(hash, storetest.NewDKGResult(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "service-a", Key: "a-key"},
+			{Name: "service-b", Key: "b-key"},
+		},
+		Admins: []string{"api-key:ops"},
+	}}
+	handler, err := server.NewRouter(config, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := func(v interface{}) map[string]interface{} { return map[string]interface{}{"data": v} }
+	key := func(k string) map[string]interface{} { return map[string]interface{}{"key": k} }
+
+	rpcCall(t, handler, "", "signer.ListKeys", data(map[string]interface{}{}), true)
+	rpcCall(t, handler, "a-key", "signer.ListKeys", data(map[string]interface{}{}), true)
+
+	rpcCall(t, handler, "ops-key", "admin.SetAccessRule", data(types.AccessRule{
+		Subject: "api-key:service-a", Roles: []types.Role{types.RoleSigner}, Keys: []string{"0x01"},
+	}), false)
+	rpcCall(t, handler, "ops-key", "admin.SetAccessRule", data(types.AccessRule{
+		Subject: "api-key:service-b", Roles: []types.Role{types.RoleReader}, Keys: []string{types.AllKeys},
+	}), false)
+
+	if hashes := listedKeys(t, rpcCall(t, handler, "a-key", "signer.ListKeys", data(map[string]interface{}{}), false)); len(hashes) != 1 || hashes[0] != "0x01" {
+		t.Fatalf("service-a listed %v", hashes)
+	}
+	if hashes := listedKeys(t, rpcCall(t, handler, "b-key", "signer.ListKeys", data(map[string]interface{}{}), false)); len(hashes) != 2 {
+		t.Fatalf("service-b listed %v", hashes)
+	}
+	rpcCall(t, handler, "a-key", "signer.GetDKG", key("0x01"), false)
+	rpcCall(t, handler, "a-key", "signer.GetDKG", key("0x02"), true)
+	rpcCall(t, handler, "b-key", "signer.GetDKG", key("0x02"), false)
+
+	// Signatures are read with the key that made them; those of older
+	// builds, which name no key, need every key.
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(&nodeKey.PublicKey))
+	for session, keyHash := range map[string]string{"session-1": "0x01", "session-2": "0x02", "session-old": ""} {
+		if err := storeDB.SaveSignerResultData(session, types.RVSignature{R: "01", S: "02", Hash: "0xd1", SessionID: session, KeyHash: keyHash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkSignature := func(session string) map[string]interface{} {
+		return data(types.CheckSignatureRequest{Message: "hello", Pubkey: pubkey, SessionID: session})
+	}
+	rpcCall(t, handler, "a-key", "signer.CheckSignature", checkSignature("session-1"), false)
+	rpcCall(t, handler, "a-key", "signer.CheckSignature", checkSignature("session-2"), true)
+	rpcCall(t, handler, "a-key", "signer.CheckSignature", checkSignature("session-old"), true)
+	rpcCall(t, handler, "b-key", "signer.CheckSignature", checkSignature("session-2"), false)
+	rpcCall(t, handler, "b-key", "signer.CheckSignature", checkSignature("session-old"), false)
+
+	// denied before any peer is contacted
+	rpcCall(t, handler, "a-key", "signer.SignMessage", data(map[string]interface{}{"hash": "0x02", "message": "00"}), true)
+	rpcCall(t, handler, "b-key", "signer.SignMessage", data(map[string]interface{}{"hash": "0x01", "message": "00"}), true)
+	rpcCall(t, handler, "a-key", "signer.RegisterDKG", data(nil), true)
+	rpcCall(t, handler, "a-key", "signer.Reshare", data(map[string]interface{}{"hash": "0x01"}), true)
+	rpcCall(t, handler, "a-key", "admin.ListAccessRules", data(nil), true)
+	rpcCall(t, handler, "a-key", "admin.SetAccessRule", data(types.AccessRule{
+		Subject: "api-key:service-a", Roles: []types.Role{types.RoleAdmin},
+	}), true)
+
+	var rules []types.AccessRule
+	if err := json.Unmarshal(rpcCall(t, handler, "ops-key", "admin.ListAccessRules", data(nil), false), &rules); err != nil || len(rules) != 2 {
+		t.Fatalf("got access rules %+v, %v", rules, err)
+	}
+	rpcCall(t, handler, "ops-key", "admin.DeleteAccessRule", key("api-key:service-a"), false)
+	rpcCall(t, handler, "a-key", "signer.GetDKG", key("0x01"), true)
+
+	req := httptest.NewRequest(http.MethodGet, "/ledger/export", nil)
+	req.Header.Set(auth.HeaderAPIKey, "b-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("got status %d exporting the ledger as a reader", rec.Code)
+	}
+
+	open, err := server.NewRouter(&types.AppConfig{}, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes := listedKeys(t, rpcCall(t, open, "", "signer.ListKeys", data(map[string]interface{}{}), false)); len(hashes) != 2 {
+		t.Fatalf("listed %v without authentication", hashes)
+	}
+}
+
+// TestQualifiedSubjects checks that callers of different authentication
+// methods sharing a subject do not share their roles.
+func TestQualifiedSubjects(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+
+	for _, config := range []types.AppConfig{
+		{Auth: types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}}, Admins: []string{"ops"}}},
+		{Auth: types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}}, Admins: []string{"oauth:ops"}}},
+		{Webhooks: types.WebhookConfig{Clients: []types.ClientWebhookConfig{{Subject: "service-a", URL: "https://example.com/tss", Secret: "secret"}}}},
+	} {
+		if _, err := server.NewRouter(&config, nil, storeDB, nil); err == nil {
+			t.Fatalf("got no error for %+v", config)
+		}
+	}
+
+	handler, err := server.NewRouter(&types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}},
+		JWT:     types.JWTConfig{Secret: "hmac secret"},
+		Admins:  []string{"api-key:ops"},
+	}}, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(header, value, method string, params interface{}) string {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": []interface{}{params}, "id": "1"})
+		req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "ops",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("hmac secret"))
+	asJWT := func(method string, params interface{}) string {
+		t.Helper()
+		return call(auth.HeaderAuthorization, "Bearer "+token, method, params)
+	}
+	asOps := func(method string, params interface{}) string {
+		t.Helper()
+		return call(auth.HeaderAPIKey, "ops-key", method, params)
+	}
+
+	if reply := asJWT("admin.ListAccessRules", map[string]interface{}{"data": nil}); !strings.Contains(reply, "jwt:ops has no access rule") {
+		t.Fatalf("a JWT of subject ops got %s", reply)
+	}
+	if reply := asOps("admin.SetAccessRule", map[string]interface{}{"data": types.AccessRule{
+		Subject: "ops", Roles: []types.Role{types.RoleReader}, Keys: []string{types.AllKeys},
+	}}); !strings.Contains(reply, "-32602") || !strings.Contains(reply, "not qualified") {
+		t.Fatalf("an unqualified access rule got %s", reply)
+	}
+	if reply := asOps("admin.SetAccessRule", map[string]interface{}{"data": types.AccessRule{
+		Subject: "jwt:ops", Roles: []types.Role{types.RoleReader}, Keys: []string{types.AllKeys},
+	}}); strings.Contains(reply, "error\":{") {
+		t.Fatalf("setting the access rule of jwt:ops got %s", reply)
+	}
+	if reply := asJWT("signer.ListKeys", map[string]interface{}{"data": map[string]interface{}{}}); !strings.Contains(reply, "\"keys\"") {
+		t.Fatalf("a JWT of subject ops got %s listing keys", reply)
+	}
+	if reply := asJWT("admin.ListAccessRules", map[string]interface{}{"data": nil}); !strings.Contains(reply, "jwt:ops needs admin") {
+		t.Fatalf("a reader got %s", reply)
+	}
+}
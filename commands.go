@@ -0,0 +1,160 @@
+package main
+
+import (
+	"alice-tss/store"
+	"alice-tss/types"
+	"alice-tss/utils"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/getamis/sirius/log"
+	"github.com/spf13/viper"
+)
+
+// openStore opens the store of the configured node for an offline command.
+// The node itself must not be running.
+func openStore() (store.HandlerData, error) {
+	appConfig, err := readAppConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := utils.GetPrivateKeyFromKeystore(keystoreFile, password)
+	if err != nil {
+		return nil, fmt.Errorf("read keystore: %w", err)
+	}
+	return store.NewStoreHandler(appConfig.Store, privateKey)
+}
+
+// runExport writes every key of the node to an encrypted bundle, so that the
+// node can be moved to another machine or keystore.
+func runExport() error {
+	if bundleFile == "" || bundlePassword == "" {
+		return errors.New("export needs -bundle and -bundle-password")
+	}
+	storeDb, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer storeDb.Defer()
+
+	file, err := os.OpenFile(bundleFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := store.ExportBundle(storeDb, bundlePassword, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(bundleFile)
+		return err
+	}
+	log.Info("Exported keys", "bundle", bundleFile, "keys", n)
+	return nil
+}
+
+// runImport stores the keys of a bundle, re-encrypted under this node's
+// keystore key.
+func runImport() error {
+	if bundleFile == "" || bundlePassword == "" {
+		return errors.New("import needs -bundle and -bundle-password")
+	}
+	storeDb, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer storeDb.Defer()
+
+	file, err := os.Open(bundleFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	n, err := store.ImportBundle(storeDb, bundlePassword, file)
+	if err != nil {
+		return fmt.Errorf("imported %d keys: %w", n, err)
+	}
+	log.Info("Imported keys", "bundle", bundleFile, "keys", n)
+	return nil
+}
+
+// runRestore loads an admin backup into the badger directory of the node,
+// which must not exist yet or be empty.
+func runRestore() error {
+	if backupFile == "" {
+		return errors.New("restore needs -backup")
+	}
+	appConfig, err := readAppConfigFile()
+	if err != nil {
+		return err
+	}
+	if appConfig.Store.Type != types.StoreTypeBadger {
+		return fmt.Errorf("restore needs a badger store, not %q", appConfig.Store.Type)
+	}
+
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := store.RestoreBadger(appConfig.Store.Path, file); err != nil {
+		return err
+	}
+	log.Info("Restored backup", "backup", backupFile, "path", appConfig.Store.Path)
+	return nil
+}
+
+// runRewrap re-seals every share of the node with the key encryption provider
+// configured under store.kek in -to-config. Once it succeeds, the node must
+// run with that provider.
+func runRewrap() error {
+	if toConfigFile == "" {
+		return errors.New("rewrap needs -to-config")
+	}
+	to := viper.New()
+	to.SetConfigFile(toConfigFile)
+	if err := to.ReadInConfig(); err != nil {
+		return err
+	}
+	var toConfig types.AppConfig
+	if err := to.Unmarshal(&toConfig); err != nil {
+		return err
+	}
+
+	appConfig, err := readAppConfigFile()
+	if err != nil {
+		return err
+	}
+	privateKey, err := utils.GetPrivateKeyFromKeystore(keystoreFile, password)
+	if err != nil {
+		return fmt.Errorf("read keystore: %w", err)
+	}
+	storeDb, err := store.NewStoreHandler(appConfig.Store, privateKey)
+	if err != nil {
+		return err
+	}
+	defer storeDb.Defer()
+
+	kek, err := store.NewKeyEncryptionProvider(toConfig.Store.KEK, privateKey)
+	if err != nil {
+		return err
+	}
+	if err := storeDb.Rewrap(kek); err != nil {
+		return err
+	}
+	log.Info("Rewrapped store", "kek", kek.ID(), "config", toConfigFile)
+	return nil
+}
+
+// runKEKGenerate writes a new key for the key-file provider to -kek-file.
+func runKEKGenerate() error {
+	if kekFile == "" {
+		return errors.New("kek-generate needs -kek-file")
+	}
+	if err := store.GenerateKeyFile(kekFile); err != nil {
+		return err
+	}
+	log.Info("Generated key file", "path", kekFile)
+	return nil
+}
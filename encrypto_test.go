@@ -0,0 +1,91 @@
+package main_test
+
+import (
+	"alice-tss/utils"
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	secret := []byte("node secret")
+	aad := []byte("02d890e326fc2ea4f67d8eb6dc451779836fe7a15a2643b901d342f76ba06d7674")
+
+	sealed, err := utils.Seal([]byte("share"), secret, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.IsSealed(sealed) {
+		t.Fatalf("sealed value %q has no version prefix", sealed)
+	}
+	again, _ := utils.Seal([]byte("share"), secret, aad)
+	if again == sealed {
+		t.Fatal("two envelopes of the same plaintext must differ")
+	}
+
+	plain, err := utils.Open(sealed, secret, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "share" {
+		t.Fatalf("got %q, want %q", plain, "share")
+	}
+}
+
+func TestOpenRejectsCorruption(t *testing.T) {
+	secret := []byte("node secret")
+	aad := []byte("pubkey")
+	sealed, err := utils.Seal([]byte("share"), secret, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := []byte(sealed)
+	i := len(flipped) - 4
+	if flipped[i] == 'A' {
+		flipped[i] = 'B'
+	} else {
+		flipped[i] = 'A'
+	}
+
+	cases := map[string]struct {
+		envelope string
+		secret   []byte
+		aad      []byte
+		want     error
+	}{
+		"tampered":  {string(flipped), secret, aad, utils.ErrCorruptedEnvelope},
+		"truncated": {sealed[:len(sealed)-8], secret, aad, utils.ErrCorruptedEnvelope},
+		"wrong key": {sealed, []byte("other secret"), aad, utils.ErrCorruptedEnvelope},
+		"wrong aad": {sealed, secret, []byte("other pubkey"), utils.ErrCorruptedEnvelope},
+		"legacy":    {strings.TrimPrefix(sealed, "v1:"), secret, aad, utils.ErrUnsupportedEnvelope},
+	}
+	for name, c := range cases {
+		if _, err := utils.Open(c.envelope, c.secret, c.aad); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+func TestZero(t *testing.T) {
+	secret := []byte("share")
+	utils.Zero(secret)
+	if !bytes.Equal(secret, make([]byte, 5)) {
+		t.Fatalf("got %q after Zero", secret)
+	}
+
+	share, _ := new(big.Int).SetString("81027363746734659626980593804036585447339663816871029076125049049095054333520", 10)
+	words := share.Bits()
+	utils.ZeroInt(share)
+	if share.Sign() != 0 {
+		t.Fatalf("got %s after ZeroInt", share)
+	}
+	for _, word := range words {
+		if word != 0 {
+			t.Fatal("ZeroInt left share words in memory")
+		}
+	}
+	utils.ZeroInt(nil)
+}
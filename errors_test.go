@@ -0,0 +1,193 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rpcFailure is the error of a JSON-RPC reply.
+type rpcFailure struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    tsserr.Data `json:"data"`
+}
+
+// TestTypedErrors checks that failures reach JSON-RPC, REST and gRPC clients
+// with the code and details of their type.
+func TestTypedErrors(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	key := storetest.NewDKGResult(t)
+	if err := storeDB.SaveDKGResultData("0x01", key); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(key.PublicKey.ToPubKey()))
+	frozen := storetest.NewDKGResult(t)
+	if err := storeDB.SaveDKGResultData("0x02", frozen); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeDB.SetKeyState("0x02", types.KeyStateFrozen); err != nil {
+		t.Fatal(err)
+	}
+	destroyed := storetest.NewDKGResult(t)
+	if err := storeDB.SaveDKGResultData("0x03", destroyed); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeDB.SetKeyState("0x03", types.KeyStateDestroyed); err != nil {
+		t.Fatal(err)
+	}
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "service-a", Key: "a-key"},
+		},
+		Admins: []string{"api-key:ops"},
+	}}
+	host, pid, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	handler, err := server.NewRouter(config, peer.NewPeerManager(pid.String(), host, peer.ProtocolId), storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(apiKey, method string, params interface{}, want tsserr.Code) rpcFailure {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": []interface{}{params}, "id": "1"})
+		req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set(auth.HeaderAPIKey, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var reply struct {
+			Error *rpcFailure `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil || reply.Error == nil {
+			t.Fatalf("%s: got %s", method, rec.Body.String())
+		}
+		if reply.Error.Code != want.RPCCode() || reply.Error.Data.Type != want {
+			t.Fatalf("%s: got %+v, want %s", method, reply.Error, want)
+		}
+		return *reply.Error
+	}
+
+	call("", "signer.GetDKG", map[string]string{"key": "0x01"}, tsserr.Unauthorized)
+	call("a-key", "signer.GetDKG", map[string]string{"key": "0x01"}, tsserr.PermissionDenied)
+	failure := call("ops-key", "signer.GetDKG", map[string]string{"key": "0xmissing"}, tsserr.KeyNotFound)
+	if failure.Code != -32002 || failure.Data.Details["hash"] != "0xmissing" {
+		t.Fatalf("got %+v", failure)
+	}
+	failure = call("ops-key", "signer.GetSignerConfig", map[string]interface{}{"data": map[string]string{"hash": "0x01", "pubkey": "02ab"}}, tsserr.PubkeyMismatch)
+	if failure.Data.Details["pubkey"] != "02ab" {
+		t.Fatalf("got %+v", failure)
+	}
+
+	failure = call("ops-key", "signer.GetJob", map[string]string{"key": "job-missing"}, tsserr.JobNotFound)
+	if failure.Data.Details["job"] != "job-missing" {
+		t.Fatalf("got %+v", failure)
+	}
+	failure = call("ops-key", "admin.GetAccessRule", map[string]string{"key": "api-key:service-b"}, tsserr.AccessRuleNotFound)
+	if failure.Data.Details["subject"] != "api-key:service-b" {
+		t.Fatalf("got %+v", failure)
+	}
+	call("ops-key", "admin.DeleteAccessRule", map[string]string{"key": "api-key:service-b"}, tsserr.AccessRuleNotFound)
+
+	// Key state and share epoch failures of the store keep their type.
+	failure = call("ops-key", "admin.RollbackEpoch", map[string]interface{}{"data": map[string]interface{}{"hash": "0x01", "pubkey": pubkey, "epoch": 7}}, tsserr.EpochMismatch)
+	if failure.Data.Details["hash"] != "0x01" || failure.Data.Details["epoch"] != "1" {
+		t.Fatalf("got %+v", failure)
+	}
+
+	// Jobs keep the type of their failure.
+	for _, tc := range []struct {
+		data map[string]interface{}
+		want tsserr.Code
+	}{
+		{map[string]interface{}{"hash": "0xmissing", "pubkey": "02ab", "message": "hello"}, tsserr.KeyNotFound},
+		{map[string]interface{}{"hash": "0x02", "pubkey": hex.EncodeToString(crypto.CompressPubkey(frozen.PublicKey.ToPubKey())), "message": "hello"}, tsserr.InvalidKeyState},
+		{map[string]interface{}{"hash": "0x01", "pubkey": pubkey, "message": "hello", "epoch": 7}, tsserr.EpochMismatch},
+	} {
+		job := decodeJob(t, rpcCall(t, handler, "ops-key", "signer.SignMessage", map[string]interface{}{"data": tc.data}, false))
+		deadline := time.Now().Add(10 * time.Second)
+		for !job.Status.Finished() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			job = decodeJob(t, rpcCall(t, handler, "ops-key", "signer.GetJob", map[string]string{"key": job.ID}, false))
+		}
+		if job.Status != types.JobStatusFailed || job.ErrorType != string(tc.want) {
+			t.Fatalf("%s: unexpected job %+v", tc.want, job)
+		}
+	}
+
+	// The REST gateway reports the gRPC status, with the type.
+	req := httptest.NewRequest(http.MethodGet, "/v1/keys/0xmissing", nil)
+	req.Header.Set(auth.HeaderAPIKey, "ops-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var gatewayReply struct {
+		Code    codes.Code        `json:"code"`
+		Type    tsserr.Code       `json:"type"`
+		Details map[string]string `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &gatewayReply); err != nil || rec.Code != http.StatusNotFound ||
+		gatewayReply.Code != codes.NotFound || gatewayReply.Type != tsserr.KeyNotFound || gatewayReply.Details["hash"] != "0xmissing" {
+		t.Fatalf("got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// gRPC clients read the type back from the status.
+	_, err = storeDB.GetDKGResultData("0xmissing")
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+	st := status.Convert(tsserr.From(err))
+	typed := tsserr.FromStatus(st)
+	if st.Code() != codes.NotFound || typed == nil || typed.Code != tsserr.KeyNotFound || typed.Details["hash"] != "0xmissing" {
+		t.Fatalf("got %v, %+v", st, typed)
+	}
+	for _, tc := range []struct {
+		err  error
+		want tsserr.Code
+	}{
+		{signerConfigError(storeDB, "0x03", hex.EncodeToString(crypto.CompressPubkey(destroyed.PublicKey.ToPubKey()))), tsserr.InvalidKeyState},
+		{storeDB.RollbackShareEpoch("0x01", 7), tsserr.EpochMismatch},
+		{storeDB.SetKeyState("0x03", types.KeyStateActive), tsserr.InvalidKeyState},
+	} {
+		st := status.Convert(tsserr.From(tc.err))
+		if typed := tsserr.FromStatus(st); st.Code() != codes.FailedPrecondition || typed == nil || typed.Code != tc.want {
+			t.Fatalf("got %v, %+v, want %s", st, typed, tc.want)
+		}
+	}
+	if tsserr.FromStatus(status.New(codes.NotFound, "not typed")) != nil {
+		t.Fatal("an untyped status has a type")
+	}
+}
+
+// signerConfigError returns only the error of loading the signer config.
+func signerConfigError(storeDB store.HandlerData, hash, pubkey string) error {
+	_, err := storeDB.GetSignerConfig(hash, pubkey)
+	return err
+}
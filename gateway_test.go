@@ -0,0 +1,187 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/pb"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TestRESTGateway calls the TssService REST resources of a node without peers,
+// and checks that its OpenAPI document describes them.
+func TestRESTGateway(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	// left running by a previous run of the node
+	if err := storeDB.SaveJob(&types.Job{
+		ID: "job-1", Kind: types.JobKindSign, Status: types.JobStatusRunning, KeyHash: "0x01",
+		Owner: "api-key:service-a", CreatedAt: 1, UpdatedAt: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeDB.SetAccessRule(&types.AccessRule{Subject: "api-key:service-a", Roles: []types.Role{types.RoleSigner}, Keys: []string{"0x02"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "service-a", Key: "a-key"},
+		},
+		Admins: []string{"api-key:ops"},
+	}}
+	handler, err := server.NewRouter(config, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(apiKey, method, path, body string, wantStatus int) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if apiKey != "" {
+			req.Header.Set(auth.HeaderAPIKey, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != wantStatus {
+			t.Fatalf("%s %s: got %d %s, want %d", method, path, rec.Code, rec.Body.String(), wantStatus)
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, rec.Body.String())
+		}
+		return reply
+	}
+
+	job := call("a-key", http.MethodGet, "/v1/jobs/job-1", "", http.StatusOK)
+	if job["status"] != string(types.JobStatusFailed) || job["keyHash"] != "0x01" {
+		t.Fatalf("unexpected job %v", job)
+	}
+	call("a-key", http.MethodPost, "/v1/jobs/job-1/cancel", "", http.StatusPreconditionFailed)
+	if reply := call("a-key", http.MethodGet, "/v1/jobs/job-2", "", http.StatusNotFound); reply["code"] != float64(5) {
+		t.Fatalf("unexpected error %v", reply)
+	}
+	call("", http.MethodGet, "/v1/jobs/job-1", "", http.StatusUnauthorized)
+
+	if keys := call("a-key", http.MethodGet, "/v1/keys?limit=10&state=active", "", http.StatusOK); len(keys["keys"].([]interface{})) != 0 {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	call("a-key", http.MethodGet, "/v1/keys?limit=many", "", http.StatusBadRequest)
+	call("a-key", http.MethodGet, "/v1/keys?owner=me", "", http.StatusBadRequest)
+	call("a-key", http.MethodGet, "/v1/ledger?keyHash=0x01", "", http.StatusOK)
+
+	// the key in the path is the one authorized
+	call("a-key", http.MethodPost, "/v1/keys/0x01/signatures", `{"message": "hello"}`, http.StatusForbidden)
+	call("a-key", http.MethodPost, "/v1/keys/0x02/signatures", `{"message": `, http.StatusBadRequest)
+	call("a-key", http.MethodPost, "/v1/keys", "{}", http.StatusForbidden)
+	call("ops-key", http.MethodPost, "/v1/self/keys", "{}", http.StatusServiceUnavailable)
+
+	document := call("", http.MethodGet, "/v1/openapi.json", "", http.StatusOK)
+	paths := document["paths"].(map[string]interface{})
+	for path, method := range map[string]string{
+		"/v1/keys":                   "post",
+		"/v1/keys/{hash}":            "get",
+		"/v1/keys/{hash}/signatures": "post",
+		"/v1/keys/{hash}/reshare":    "post",
+		"/v1/jobs/{id}/cancel":       "post",
+		"/v1/ledger":                 "get",
+	} {
+		item, ok := paths[path].(map[string]interface{})
+		if !ok || item[method] == nil {
+			t.Fatalf("%s %s is not in the OpenAPI document", method, path)
+		}
+	}
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"SignRequest", "ReshareRequest", "KeyView", "BK", "Job"} {
+		if schemas[name] == nil {
+			t.Fatalf("no schema of %s", name)
+		}
+	}
+}
+
+// TestRESTJobs generates a key and signs with it over REST, on the local three
+// node cluster: both return a job at once, read back with GetJob.
+func TestRESTJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs protocol sessions")
+	}
+	selfService, err := server.NewSelfService()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer selfService.Close()
+
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	handler, err := server.NewRouter(&types.AppConfig{}, nil, storeDB, selfService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(method, path, body string) *pb.Job {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: got %d %s", method, path, rec.Code, rec.Body.String())
+		}
+		job := &pb.Job{}
+		if err := protojson.Unmarshal(rec.Body.Bytes(), job); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, rec.Body.String())
+		}
+		return job
+	}
+	wait := func(job *pb.Job) *pb.Job {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Minute)
+		for !types.JobStatus(job.Status).Finished() {
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s still %s in state %q", job.Id, job.Status, job.State)
+			}
+			time.Sleep(200 * time.Millisecond)
+			job = call(http.MethodGet, "/v1/jobs/"+job.Id, "")
+		}
+		if job.Status != string(types.JobStatusDone) {
+			t.Fatalf("job %s ended %s: %s", job.Id, job.Status, job.Error)
+		}
+		return job
+	}
+
+	job := call(http.MethodPost, "/v1/self/keys", "{}")
+	if job.Id == "" || job.Kind != string(types.JobKindDKG) || job.KeyHash == "" {
+		t.Fatalf("unexpected DKG job %v", job)
+	}
+	job = wait(job)
+	var key struct {
+		Pubkey string `json:"pubkey"`
+	}
+	if err := json.Unmarshal([]byte(job.Result), &key); err != nil || key.Pubkey == "" {
+		t.Fatalf("unexpected DKG result %s (%v)", job.Result, err)
+	}
+
+	job = call(http.MethodPost, "/v1/self/keys/"+job.KeyHash+"/signatures", `{"pubkey": "`+key.Pubkey+`", "message": "68656c6c6f"}`)
+	if job.Kind != string(types.JobKindSign) {
+		t.Fatalf("unexpected signing job %v", job)
+	}
+	job = wait(job)
+	var signature types.RVSignature
+	if err := json.Unmarshal([]byte(job.Result), &signature); err != nil || signature.R == "" || signature.SessionID == "" {
+		t.Fatalf("unexpected signature %s (%v)", job.Result, err)
+	}
+}
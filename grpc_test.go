@@ -0,0 +1,138 @@
+package main_test
+
+import (
+	"alice-tss/pb"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestGRPCServer calls the TSS and admin gRPC services of a node without
+// peers, over an in-memory connection.
+func TestGRPCServer(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	// left running by a previous run of the node
+	if err := storeDB.SaveJob(&types.Job{
+		ID: "job-1", Kind: types.JobKindSign, Status: types.JobStatusRunning, KeyHash: "0x01",
+		Owner: "api-key:service-a", CreatedAt: 1, UpdatedAt: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "service-a", Key: "a-key"},
+		},
+		Admins: []string{"api-key:ops"},
+	}}
+	grpcServer, err := server.NewGRPCServer(config, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tss := pb.NewTssServiceClient(conn)
+	admin := pb.NewAdminServiceClient(conn)
+	as := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+	wantCode := func(err error, code codes.Code) {
+		t.Helper()
+		if status.Code(err) != code {
+			t.Fatalf("got %v, want %s", err, code)
+		}
+	}
+
+	_, err = admin.SetAccessRule(as("a-key"), &pb.AccessRule{Subject: "api-key:service-a", Roles: []string{"admin"}})
+	wantCode(err, codes.PermissionDenied)
+	rule, err := admin.SetAccessRule(as("ops-key"), &pb.AccessRule{Subject: "api-key:service-a", Roles: []string{"signer"}, Keys: []string{"*"}})
+	if err != nil || rule.Subject != "api-key:service-a" || rule.UpdatedAt == 0 {
+		t.Fatalf("got %v, %v", rule, err)
+	}
+	rules, err := admin.ListAccessRules(as("ops-key"), &pb.ListAccessRulesRequest{})
+	if err != nil || len(rules.Rules) != 1 || !slices.Equal(rules.Rules[0].Roles, []string{"signer"}) {
+		t.Fatalf("got %v, %v", rules, err)
+	}
+
+	job, err := tss.GetJob(as("a-key"), &pb.JobRequest{Id: "job-1"})
+	if err != nil || job.Status != string(types.JobStatusFailed) || job.KeyHash != "0x01" {
+		t.Fatalf("got %v, %v", job, err)
+	}
+	_, err = tss.CancelJob(as("a-key"), &pb.JobRequest{Id: "job-1"})
+	wantCode(err, codes.FailedPrecondition)
+	_, err = tss.GetJob(as("a-key"), &pb.JobRequest{Id: "job-2"})
+	wantCode(err, codes.NotFound)
+
+	keys, err := tss.ListKeys(as("a-key"), &pb.ListKeysRequest{})
+	if err != nil || len(keys.Keys) != 0 {
+		t.Fatalf("got %v, %v", keys, err)
+	}
+	ledger, err := tss.QueryLedger(as("a-key"), &pb.LedgerQuery{KeyHash: "0x01"})
+	if err != nil || len(ledger.Entries) != 0 {
+		t.Fatalf("got %v, %v", ledger, err)
+	}
+	_, err = tss.RegisterSelfDKG(as("a-key"), &pb.DKGRequest{})
+	wantCode(err, codes.PermissionDenied)
+	_, err = tss.RegisterSelfDKG(as("ops-key"), &pb.DKGRequest{})
+	wantCode(err, codes.Unavailable)
+	_, err = admin.GetSignerConfig(as("ops-key"), &pb.SignRequest{Hash: "0x01"})
+	wantCode(err, codes.FailedPrecondition)
+
+	// reflection serves both services from the descriptor set
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(as("ops-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, service := range reply.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	if !slices.Contains(services, "pb.TssService") || !slices.Contains(services, "pb.AdminService") {
+		t.Fatalf("reflection lists %v", services)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "pb.AdminService"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err = stream.Recv(); err != nil || len(reply.GetFileDescriptorResponse().GetFileDescriptorProto()) == 0 {
+		t.Fatalf("got %v, %v", reply, err)
+	}
+}
@@ -0,0 +1,142 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	libp2pPeer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TestHealth probes a node holding a key with a peer, before, while and after
+// it is connected to the peer, and reads its status.
+func TestHealth(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	host, pid, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	otherKey, _ := crypto.GenerateKey()
+	other, otherID, err := peer.MakeBasicHost(0, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	result := storetest.NewDKGResult(t)
+	result.Bks[pid.String()], result.Bks[otherID.String()] = result.Bks["peer-a"], result.Bks["peer-b"]
+	delete(result.Bks, "peer-a")
+	delete(result.Bks, "peer-b")
+	if err := storeDB.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}},
+		Admins:  []string{"api-key:ops"},
+	}}
+	handler, err := server.NewRouter(config, peer.NewPeerManager(pid.String(), host, peer.ProtocolId), storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(apiKey, path string, wantStatus int, reply interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if apiKey != "" {
+			req.Header.Set(auth.HeaderAPIKey, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != wantStatus {
+			t.Fatalf("%s: got %d %s, want %d", path, rec.Code, rec.Body.String(), wantStatus)
+		}
+		if reply != nil {
+			if err := json.Unmarshal(rec.Body.Bytes(), reply); err != nil {
+				t.Fatalf("%s: %v in %s", path, err, rec.Body.String())
+			}
+		}
+	}
+	nodeStatus := func() types.NodeStatus {
+		t.Helper()
+		var status types.NodeStatus
+		get("ops-key", "/status", http.StatusOK, &status)
+		if status.PeerID != pid.String() || len(status.ListenAddrs) == 0 {
+			t.Fatalf("unexpected status %+v", status)
+		}
+		return status
+	}
+	peerStatus := func() types.PeerStatus {
+		t.Helper()
+		status := nodeStatus()
+		for _, p := range status.Peers {
+			if p.ID == otherID.String() {
+				return p
+			}
+		}
+		t.Fatalf("%s is not in the status %+v", otherID, status.Peers)
+		return types.PeerStatus{}
+	}
+
+	get("", "/healthz", http.StatusOK, nil)
+	var probe map[string]interface{}
+	get("", "/readyz", http.StatusServiceUnavailable, &probe)
+	if len(probe) != 1 || probe["status"] != "unavailable" {
+		t.Fatalf("unexpected probe %v", probe)
+	}
+	status := nodeStatus()
+	if status.Ready {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	for _, check := range status.Checks {
+		if check.OK != (check.Name != "peers") {
+			t.Fatalf("unexpected checks %+v", status.Checks)
+		}
+		if check.Name == "peers" && !strings.Contains(check.Error, otherID.String()) {
+			t.Fatalf("unexpected peers check %+v", check)
+		}
+	}
+	if p := peerStatus(); p.Connected || p.LastSeen != 0 || len(p.Keys) != 1 || p.Keys[0] != "0x01" {
+		t.Fatalf("unexpected peer before connecting %+v", p)
+	}
+
+	if err := host.Connect(context.Background(), libp2pPeer.AddrInfo{ID: otherID, Addrs: other.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	get("", "/readyz", http.StatusOK, &probe)
+	if probe["status"] != "ready" || !nodeStatus().Ready {
+		t.Fatalf("unexpected probe %v", probe)
+	}
+	if p := peerStatus(); !p.Connected || p.LastSeen == 0 || len(p.Addrs) == 0 {
+		t.Fatalf("unexpected connected peer %+v", p)
+	}
+	get("", "/status", http.StatusUnauthorized, nil)
+
+	if err := host.Network().ClosePeer(otherID); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p := peerStatus(); p.Connected || p.LastSeen == 0; p = peerStatus() {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected disconnected peer %+v", p)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	get("", "/readyz", http.StatusServiceUnavailable, nil)
+}
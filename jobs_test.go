@@ -0,0 +1,387 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/websocket"
+)
+
+func decodeJob(t *testing.T, data json.RawMessage) *types.Job {
+	t.Helper()
+	var job types.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestJobRecovery(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+
+	// left running by a previous run of the node
+	if err := storeDB.SaveJob(&types.Job{
+		ID: "job-1", Kind: types.JobKindSign, Status: types.JobStatusRunning, State: "Init",
+		KeyHash: "0x01", Owner: "api-key:service-a", CreatedAt: 1, UpdatedAt: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "service-a", Key: "a-key"},
+			{Name: "service-b", Key: "b-key"},
+		},
+		Admins: []string{"api-key:ops"},
+	}}
+	handler, err := server.NewRouter(config, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(k string) map[string]interface{} { return map[string]interface{}{"key": k} }
+	data := func(v interface{}) map[string]interface{} { return map[string]interface{}{"data": v} }
+	for _, subject := range []string{"api-key:service-a", "api-key:service-b"} {
+		rpcCall(t, handler, "ops-key", "admin.SetAccessRule", data(types.AccessRule{
+			Subject: subject, Roles: []types.Role{types.RoleSigner}, Keys: []string{types.AllKeys},
+		}), false)
+	}
+
+	job := decodeJob(t, rpcCall(t, handler, "a-key", "signer.GetJob", key("job-1"), false))
+	if job.Status != types.JobStatusFailed || job.Error == "" || job.FinishedAt == 0 || job.State != "Init" {
+		t.Fatalf("unexpected job after restart %+v", job)
+	}
+	rpcCall(t, handler, "ops-key", "signer.GetJob", key("job-1"), false)
+	rpcCall(t, handler, "b-key", "signer.GetJob", key("job-1"), true)
+	rpcCall(t, handler, "b-key", "signer.CancelJob", key("job-1"), true)
+
+	stored, err := storeDB.GetJob("job-1")
+	if err != nil || stored.Status != types.JobStatusFailed {
+		t.Fatalf("got %+v, %v from the store", stored, err)
+	}
+}
+
+// watchJob reads the progress events of a job from the WebSocket endpoint of
+// baseURL until the node closes the stream.
+func watchJob(t *testing.T, baseURL string, id string) []types.ProgressEvent {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(baseURL, "http")+"/tss/progress/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+
+	var events []types.ProgressEvent
+	for {
+		var event types.ProgressEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Fatalf("progress of %s: %v", id, err)
+			}
+			return events
+		}
+		if event.JobID != id {
+			t.Fatalf("got an event of job %s", event.JobID)
+		}
+		events = append(events, event)
+	}
+}
+
+// TestSelfServiceJobs runs a DKG as a job on the local three node cluster,
+// following its progress, then cancels a signing job.
+func TestSelfServiceJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs protocol sessions")
+	}
+	selfService, err := server.NewSelfService()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer selfService.Close()
+
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	handler, err := server.NewRouter(&types.AppConfig{}, nil, storeDB, selfService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	getJob := func(id string) *types.Job {
+		return decodeJob(t, rpcCall(t, handler, "", "signer.GetJob", map[string]interface{}{"key": id}, false))
+	}
+	wait := func(job *types.Job) *types.Job {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Minute)
+		for !job.Status.Finished() {
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s still %s in state %q", job.ID, job.Status, job.State)
+			}
+			time.Sleep(200 * time.Millisecond)
+			job = getJob(job.ID)
+		}
+		return job
+	}
+
+	job := decodeJob(t, rpcCall(t, handler, "", "signer.RegisterSelfDKG", map[string]interface{}{"data": nil}, false))
+	if job.Kind != types.JobKindDKG || job.Status.Finished() || job.KeyHash == "" {
+		t.Fatalf("unexpected DKG job %+v", job)
+	}
+	events := watchJob(t, httpServer.URL, job.ID)
+	if len(events) == 0 || (events[0].Type != types.ProgressJob && events[0].Type != types.ProgressResult) {
+		t.Fatalf("unexpected progress %+v", events)
+	}
+	last := events[len(events)-1]
+	if last.Type != types.ProgressResult || last.Job == nil || last.Job.Status != types.JobStatusDone {
+		t.Fatalf("unexpected last progress event %+v", last)
+	}
+	for _, event := range events[1:] {
+		if event.Type == types.ProgressMessage && (event.Peer == "" || event.Message == "") {
+			t.Fatalf("unexpected message event %+v", event)
+		}
+	}
+	if events := watchJob(t, httpServer.URL, job.ID); len(events) != 1 || events[0].Type != types.ProgressResult {
+		t.Fatalf("unexpected progress of a finished job %+v", events)
+	}
+	job = wait(job)
+	if job.Status != types.JobStatusDone || job.State != "Done" {
+		t.Fatalf("DKG job ended %s in state %q: %s", job.Status, job.State, job.Error)
+	}
+	var key struct {
+		Pubkey string `json:"pubkey"`
+		Hash   string `json:"hash"`
+	}
+	if err := json.Unmarshal(job.Result, &key); err != nil || key.Pubkey == "" || key.Hash != job.KeyHash {
+		t.Fatalf("unexpected DKG result %s (%v)", job.Result, err)
+	}
+	samples := scrapeMetrics(t, handler)
+	if samples[`tss_sessions_total{kind="dkg",outcome="done"}`] == 0 {
+		t.Fatal("the DKG session is not in the metrics")
+	}
+	rounds := 0
+	for name := range samples {
+		if strings.HasPrefix(name, `tss_round_duration_seconds_count{kind="dkg"`) {
+			rounds++
+		}
+	}
+	if rounds == 0 {
+		t.Fatal("the DKG rounds are not in the metrics")
+	}
+
+	job = decodeJob(t, rpcCall(t, handler, "", "signer.SelfSignMessage", map[string]interface{}{"data": map[string]interface{}{
+		"hash": job.KeyHash, "pubkey": key.Pubkey, "message": "68656c6c6f",
+	}}, false))
+	for job.Status == types.JobStatusPending {
+		time.Sleep(100 * time.Millisecond)
+		job = getJob(job.ID)
+	}
+	time.Sleep(2 * time.Second)
+	rpcCall(t, handler, "", "signer.CancelJob", map[string]interface{}{"key": job.ID}, false)
+	job = wait(job)
+	// the session may have won the race
+	if job.Status != types.JobStatusCancelled && job.Status != types.JobStatusDone {
+		t.Fatalf("signing job ended %s: %s", job.Status, job.Error)
+	}
+}
+
+// TestIdempotentJobs checks that a request repeating the idempotency key of
+// an earlier one of the same client returns the job of the earlier one.
+func TestIdempotentJobs(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	config := &types.AppConfig{Auth: types.AuthConfig{
+		APIKeys: []types.APIKeyConfig{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "ops-2", Key: "ops-2-key"},
+		},
+		Admins: []string{"api-key:ops", "api-key:ops-2"},
+	}}
+	host, pid, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	handler, err := server.NewRouter(config, peer.NewPeerManager(pid.String(), host, peer.ProtocolId), storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(apiKey, hash, message string) json.RawMessage {
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "signer.SignMessage", "id": "1",
+			"params": []interface{}{map[string]interface{}{"data": map[string]string{
+				"hash": hash, "pubkey": "02ab", "message": message, "idempotencyKey": "retry-1",
+			}}}})
+		req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.Bytes()
+	}
+	jobOf := func(reply json.RawMessage) *types.Job {
+		t.Helper()
+		var r rpcReply
+		if err := json.Unmarshal(reply, &r); err != nil || len(r.Error) > 0 && string(r.Error) != "null" {
+			t.Fatalf("got %s", reply)
+		}
+		return decodeJob(t, r.Result.Data)
+	}
+
+	first := jobOf(sign("ops-key", "0x01", "68656c6c6f"))
+	if first.Fingerprint == "" {
+		t.Fatalf("job %+v has no fingerprint", first)
+	}
+	if retry := jobOf(sign("ops-key", "0x01", "68656c6c6f")); retry.ID != first.ID {
+		t.Fatalf("a retry started job %s, want %s", retry.ID, first.ID)
+	}
+	if other := jobOf(sign("ops-2-key", "0x01", "68656c6c6f")); other.ID == first.ID {
+		t.Fatal("the idempotency keys of two clients share a job")
+	}
+	// REST retries reach the job of the JSON-RPC request.
+	req := httptest.NewRequest(http.MethodPost, "/v1/keys/0x01/signatures",
+		strings.NewReader(`{"pubkey": "02ab", "message": "68656c6c6f", "idempotencyKey": "retry-1"}`))
+	req.Header.Set(auth.HeaderAPIKey, "ops-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var restJob struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &restJob); err != nil || rec.Code != http.StatusOK || restJob.ID != first.ID {
+		t.Fatalf("a REST retry got %d %s, want job %s", rec.Code, rec.Body.String(), first.ID)
+	}
+	for _, request := range []struct{ hash, message string }{
+		{"0x02", "68656c6c6f"}, // another key
+		{"0x01", "776f726c64"}, // another message on the same key
+	} {
+		var reply struct {
+			Error *rpcFailure `json:"error"`
+		}
+		if err := json.Unmarshal(sign("ops-key", request.hash, request.message), &reply); err != nil || reply.Error == nil ||
+			reply.Error.Code != tsserr.IdempotencyConflict.RPCCode() || reply.Error.Data.Type != tsserr.IdempotencyConflict ||
+			reply.Error.Data.Details["job"] != first.ID {
+			t.Fatalf("reusing an idempotency key for %+v got %+v", request, reply.Error)
+		}
+	}
+	jobs, err := storeDB.ListJobs("")
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("got %d jobs, %v", len(jobs), err)
+	}
+}
+
+// TestSignSessions signs the same message twice at once on the local three
+// node cluster: each signing runs its own session, whose signature is kept.
+func TestSignSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs protocol sessions")
+	}
+	selfService, err := server.NewSelfService()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer selfService.Close()
+
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	handler, err := server.NewRouter(&types.AppConfig{}, nil, storeDB, selfService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func(job *types.Job) *types.Job {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Minute)
+		for !job.Status.Finished() {
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s still %s in state %q", job.ID, job.Status, job.State)
+			}
+			time.Sleep(200 * time.Millisecond)
+			job = decodeJob(t, rpcCall(t, handler, "", "signer.GetJob", map[string]interface{}{"key": job.ID}, false))
+		}
+		if job.Status != types.JobStatusDone {
+			t.Fatalf("%s job ended %s: %s", job.Kind, job.Status, job.Error)
+		}
+		return job
+	}
+
+	job := wait(decodeJob(t, rpcCall(t, handler, "", "signer.RegisterSelfDKG", map[string]interface{}{"data": nil}, false)))
+	var key struct {
+		Pubkey string `json:"pubkey"`
+	}
+	if err := json.Unmarshal(job.Result, &key); err != nil {
+		t.Fatal(err)
+	}
+	pubkey, err := crypto.DecompressPubkey(common.FromHex(key.Pubkey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const message = "68656c6c6f"
+	var jobs [2]*types.Job
+	for i := range jobs {
+		jobs[i] = decodeJob(t, rpcCall(t, handler, "", "signer.SelfSignMessage", map[string]interface{}{"data": map[string]interface{}{
+			"hash": job.KeyHash, "pubkey": key.Pubkey, "message": message,
+		}}, false))
+	}
+	var signatures [2]types.RVSignature
+	for i := range jobs {
+		if err := json.Unmarshal(wait(jobs[i]).Result, &signatures[i]); err != nil {
+			t.Fatal(err)
+		}
+		if signatures[i].SessionID == "" {
+			t.Fatalf("signature %d has no session", i)
+		}
+		r := new(big.Int).SetBytes(common.FromHex(signatures[i].R))
+		s := new(big.Int).SetBytes(common.FromHex(signatures[i].S))
+		if !ecdsa.Verify(pubkey, common.FromHex(message), r, s) {
+			t.Fatalf("invalid signature %+v", signatures[i])
+		}
+		stored, err := storeDB.GetSignerResultData(signatures[i].SessionID)
+		if err != nil || stored.R != signatures[i].R || stored.S != signatures[i].S {
+			t.Fatalf("got %+v, %v for session %d", stored, err, i)
+		}
+		rpcCall(t, handler, "", "signer.CheckSignature", map[string]interface{}{"data": map[string]string{
+			"message": message, "pubkey": key.Pubkey, "sessionId": signatures[i].SessionID,
+		}}, false)
+	}
+	if signatures[0].SessionID == signatures[1].SessionID {
+		t.Fatal("two signings share a session")
+	}
+	// Without a session, the latest signature of the message is checked.
+	rpcCall(t, handler, "", "signer.CheckSignature", map[string]interface{}{"data": map[string]string{
+		"message": message, "pubkey": key.Pubkey,
+	}}, false)
+}
@@ -0,0 +1,91 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// slowLedger takes delay to read each ledger entry.
+type slowLedger struct {
+	store.HandlerData
+	delay time.Duration
+}
+
+func (s slowLedger) ScanLedger(query types.LedgerQuery, fn func(entry *types.LedgerEntry) error) error {
+	return s.HandlerData.ScanLedger(query, func(entry *types.LedgerEntry) error {
+		time.Sleep(s.delay)
+		return fn(entry)
+	})
+}
+
+// TestLedgerExport exports a ledger that takes several query timeouts to read,
+// which must be streamed whole.
+func TestLedgerExport(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	const entries = 50
+	for i := 0; i < entries; i++ {
+		entry := &types.LedgerEntry{ID: "entry-" + strconv.Itoa(i), KeyHash: "0x01", Digest: "0xd1", StartedAt: int64(100 + i), Outcome: types.LedgerOutcomeSigned}
+		if err := storeDB.AppendLedgerEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler, err := server.NewRouter(&types.AppConfig{
+		Auth:     types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}}, Admins: []string{"api-key:ops"}},
+		Timeouts: types.TimeoutConfig{Query: 50 * time.Millisecond},
+	}, nil, slowLedger{HandlerData: storeDB, delay: 5 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ledger/export", nil)
+	req.Header.Set(auth.HeaderAPIKey, "ops-key")
+	started := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	exported := 0
+	for lines.Scan() {
+		var entry types.LedgerEntry
+		if err := json.Unmarshal(lines.Bytes(), &entry); err != nil {
+			t.Fatalf("%v in %s", err, lines.Text())
+		}
+		if entry.ID != "entry-"+strconv.Itoa(exported) {
+			t.Fatalf("got entry %s at line %d", entry.ID, exported)
+		}
+		exported++
+	}
+	if err := lines.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if exported != entries {
+		t.Fatalf("exported %d entries, want %d", exported, entries)
+	}
+	if elapsed := time.Since(started); elapsed < 2*50*time.Millisecond {
+		t.Fatalf("the export took %s, not longer than the query timeout", elapsed)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"alice-tss/server"
 	"alice-tss/store"
 	"alice-tss/types"
+	"context"
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/getamis/sirius/log"
-	gorpc "github.com/libp2p/go-libp2p-gorpc"
 	"github.com/spf13/viper"
 
 	"alice-tss/peer"
@@ -19,75 +24,129 @@ var keystoreFile string
 var password string
 var port int
 var selfHost bool
+var bundleFile string
+var bundlePassword string
+var backupFile string
+var toConfigFile string
+var kekFile string
+
+// main runs the command named by the first argument, or the TSS node when
+// there is none. Flags follow the command name.
+func main() {
+	command := ""
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	err := flag.CommandLine.Parse(args)
+	if err != nil {
+		log.Crit("Failed to parse flags", "err", err)
+	}
+
+	switch command {
+	case "":
+		err = runNode()
+	case "export":
+		err = runExport()
+	case "import":
+		err = runImport()
+	case "restore":
+		err = runRestore()
+	case "rewrap":
+		err = runRewrap()
+	case "kek-generate":
+		err = runKEKGenerate()
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		log.Crit("Command failed", "command", command, "err", err)
+	}
+}
 
-// main initializes and starts the TSS (Threshold Signature Scheme) service.
+// runNode initializes and starts the TSS (Threshold Signature Scheme) service.
 // It sets up peer-to-peer networking, storage, and RPC servers for distributed
 // cryptographic operations including DKG, signing, and key resharing.
-func main() {
-	flag.Parse()
-
+// On SIGINT or SIGTERM, the node stops taking work, lets the sessions in
+// flight finish within timeouts.shutdown, then closes its hosts and store.
+func runNode() error {
 	log.Info("load config file", "configFile", configFile)
 	appConfig, err := readAppConfigFile()
 	if err != nil {
-		log.Crit("Failed to read config file", "configFile", configFile, "err", err)
+		return fmt.Errorf("read config file %s: %w", configFile, err)
 	}
 
 	privateKey, err := utils.GetPrivateKeyFromKeystore(keystoreFile, password)
 	if err != nil {
-		log.Crit("GetPrivateKeyFromKeystore", "err", err)
+		return fmt.Errorf("read keystore: %w", err)
+	}
+
+	storeDb, err := store.NewStoreHandler(appConfig.Store, privateKey)
+	if err != nil {
+		return fmt.Errorf("create a store handler: %w", err)
 	}
+	defer storeDb.Defer()
 
 	// Make a host that listens on the given multiaddress.
 	host, pid, err := peer.MakeBasicHost(appConfig.Port, privateKey)
 	if err != nil {
-		log.Crit("Failed to create a basic host", "err", err)
+		return fmt.Errorf("create a basic host: %w", err)
 	}
+	defer func() {
+		if closeErr := host.Close(); closeErr != nil {
+			log.Error("Failed to close host", "err", closeErr)
+		}
+	}()
 	log.Info("peer host", "pid", pid)
 
 	// Create a new peer manager.
 	pm := peer.NewPeerManager(pid.String(), host, peer.ProtocolId)
 
-	storeDb, err := store.NewStoreHandler(appConfig.Store, privateKey)
-	if err != nil {
-		log.Crit("Failed to create a store handler", "err", err)
-	}
-	defer storeDb.Defer()
-
 	var selfService *server.SelfService = nil
 	if selfHost {
 		selfService, err = server.NewSelfService()
 		if err != nil {
-			log.Crit("Failed to create self service", "err", err)
+			return fmt.Errorf("create self service: %w", err)
 		}
 		defer func() {
-			if selfService != nil {
-				if closeErr := selfService.Close(); closeErr != nil {
-					log.Error("Failed to close self service", "err", closeErr)
-				}
+			if closeErr := selfService.Close(); closeErr != nil {
+				log.Error("Failed to close self service", "err", closeErr)
 			}
 		}()
 	} else {
 		// setup local mDNS discovery
 		if err := peer.SetupDiscovery(pm); err != nil {
-			log.Crit("Failed to setup discovery", "err", err)
+			return fmt.Errorf("setup discovery: %w", err)
 		}
 	}
 
-	rpcServer := server.NewRpcServer(pm, storeDb)
-	rpcHost := gorpc.NewServer(host, peer.ProtocolId)
-
-	if err := rpcHost.Register(rpcServer); err != nil {
-		log.Crit("Failed to register rpc server", "err", err)
-	}
-
 	if port != 0 {
 		appConfig.RPC = port
 	}
 
-	//go server.StartGRPC(rpcPort+1000, pm, storeDb)
-	if err := server.InitRouter(appConfig, pm, storeDb, selfService); err != nil {
-		log.Crit("init router", "err", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	apiServer, err := server.InitRouter(appConfig, pm, storeDb, selfService)
+	if err != nil {
+		return fmt.Errorf("init router: %w", err)
+	}
+	select {
+	case <-ctx.Done():
+		log.Info("Shutting down")
+	case err = <-apiServer.Err():
+		log.Error("API server failed", "err", err)
+	}
+	// A second signal kills the node.
+	stop()
+
+	// The hosts, then the store, close deferred once the APIs and the
+	// sessions are over.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout(appConfig.Timeouts))
+	defer cancel()
+	if shutdownErr := apiServer.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Warn("APIs did not stop cleanly", "err", shutdownErr)
 	}
+	return err
 }
 
 func init() {
@@ -96,6 +155,11 @@ func init() {
 	flag.StringVar(&password, "password", "", "password for keystore file")
 	flag.IntVar(&port, "port", 0, "port server")
 	flag.BoolVar(&selfHost, "self-host", false, "run self host")
+	flag.StringVar(&bundleFile, "bundle", "", "key bundle file for export and import")
+	flag.StringVar(&bundlePassword, "bundle-password", "", "password of the key bundle")
+	flag.StringVar(&backupFile, "backup", "", "backup file for restore")
+	flag.StringVar(&toConfigFile, "to-config", "", "config file holding the store.kek to rewrap shares with")
+	flag.StringVar(&kekFile, "kek-file", "", "key file to write for kek-generate")
 }
 
 // readAppConfigFile reads and parses the application configuration file.
@@ -114,7 +178,10 @@ func readAppConfigFile() (*types.AppConfig, error) {
 		log.Error("Cannot unmarshal configuration", "error", err)
 		return nil, err
 	}
-	log.Info("config", "config", c)
+	// The configuration holds API keys, JWT, webhook and PKCS#11 secrets: only
+	// what locates the node is logged.
+	log.Info("config", "port", c.Port, "rpc", c.RPC, "grpc", c.GRPC, "store", c.Store.Type, "path", c.Store.Path,
+		"kek", c.Store.KEK.Type)
 
 	return &c, nil
 }
@@ -0,0 +1,127 @@
+// Package metrics collects the Prometheus metrics of a node: its TSS
+// sessions, its P2P traffic and its store. They are registered on the default
+// registry, alongside the Go runtime and process collectors, and served by
+// Handler.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tss"
+
+// Outcomes of a session.
+const (
+	OutcomeDone      = "done"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+)
+
+var (
+	sessions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sessions_total",
+		Help:      "TSS sessions run by the node, by kind and outcome.",
+	}, []string{"kind", "outcome"})
+	sessionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "session_duration_seconds",
+		Help:      "Duration of the TSS sessions, by kind and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"kind", "outcome"})
+	roundDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "round_duration_seconds",
+		Help:      "Duration of the rounds of the TSS sessions, by kind and round.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"kind", "round"})
+
+	messagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "messages_sent_total",
+		Help:      "Session messages sent, by peer.",
+	}, []string{"peer"})
+	sendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "send_failures_total",
+		Help:      "Session messages that could not be sent, by peer.",
+	}, []string{"peer"})
+	messagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "messages_received_total",
+		Help:      "Session messages received, by peer.",
+	}, []string{"peer"})
+	streamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "stream_retries_total",
+		Help:      "Failed attempts to open a stream to a peer.",
+	}, []string{"peer"})
+	connectedPeers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "connected_peers",
+		Help:      "Peers the libp2p host of the node is connected to.",
+	})
+
+	storeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the store operations, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 9),
+	}, []string{"op"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// SessionEnded records a session of kind that ran for d.
+func SessionEnded(kind, outcome string, d time.Duration) {
+	sessions.WithLabelValues(kind, outcome).Inc()
+	sessionDuration.WithLabelValues(kind, outcome).Observe(d.Seconds())
+}
+
+// RoundEnded records a round of a session of kind that ran for d.
+func RoundEnded(kind, round string, d time.Duration) {
+	roundDuration.WithLabelValues(kind, round).Observe(d.Seconds())
+}
+
+// MessageSent records a message sent to peer, or that failed to be with err.
+func MessageSent(peer string, err error) {
+	if err != nil {
+		sendFailures.WithLabelValues(peer).Inc()
+		return
+	}
+	messagesSent.WithLabelValues(peer).Inc()
+}
+
+// MessageReceived records a message received from peer.
+func MessageReceived(peer string) {
+	messagesReceived.WithLabelValues(peer).Inc()
+}
+
+// StreamRetried records a failed attempt to open a stream to peer.
+func StreamRetried(peer string) {
+	streamRetries.WithLabelValues(peer).Inc()
+}
+
+// SetConnectedPeers sets the number of peers the node is connected to.
+func SetConnectedPeers(n int) {
+	connectedPeers.Set(float64(n))
+}
+
+// StoreOp records a store operation op that started at start.
+func StoreOp(op string, start time.Time) {
+	storeDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
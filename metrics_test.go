@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/types"
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	libp2pPeer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// scrapeMetrics returns the samples of the metrics served by handler, by
+// name and labels.
+func scrapeMetrics(t *testing.T, handler http.Handler) map[string]float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s", rec.Code, rec.Body.String())
+	}
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.LastIndexByte(line, ' ')
+		if strings.HasPrefix(line, "#") || i < 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+// TestMetrics scrapes the metrics of a node after store operations, and after
+// it connected and sent a message to a peer.
+func TestMetrics(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	host, pid, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	otherKey, _ := crypto.GenerateKey()
+	other, otherID, err := peer.MakeBasicHost(0, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	received := make(chan struct{}, 1)
+	other.SetStreamHandler(peer.ProtocolId, func(s network.Stream) {
+		_, _ = io.ReadAll(s)
+		_ = s.Close()
+		received <- struct{}{}
+	})
+
+	storeDB, err := store.NewStoreHandler(types.StoreConfig{Type: types.StoreTypeMemory}, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	if err := storeDB.SaveDKGResultData("0x01", storetest.NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := peer.NewPeerManager(pid.String(), host, peer.ProtocolId)
+	handler, err := server.NewRouter(&types.AppConfig{}, pm, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := scrapeMetrics(t, handler)
+	if samples[`tss_store_operation_duration_seconds_count{op="SaveDKGResultData"}`] == 0 {
+		t.Fatal("store operations are not timed")
+	}
+	if samples["tss_p2p_connected_peers"] != 0 {
+		t.Fatalf("%v connected peers before connecting", samples["tss_p2p_connected_peers"])
+	}
+
+	if err := host.Connect(context.Background(), libp2pPeer.AddrInfo{ID: otherID, Addrs: other.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	pm.AddPeerID(otherID, other.Addrs()[0].String())
+	pm.MustSend(otherID.String(), map[string]string{"hello": "peer"})
+	select {
+	case <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the peer did not receive the message")
+	}
+
+	samples = scrapeMetrics(t, handler)
+	if got := samples["tss_p2p_connected_peers"]; got != 1 {
+		t.Fatalf("%v connected peers", got)
+	}
+	sent := `tss_p2p_messages_sent_total{peer="` + otherID.String() + `"}`
+	if got := samples[sent]; got != 1 {
+		t.Fatalf("%s is %v", sent, got)
+	}
+	if retries := samples[`tss_p2p_stream_retries_total{peer="`+otherID.String()+`"}`]; retries != 0 {
+		t.Fatalf("%v stream retries to a connected peer", retries)
+	}
+}
@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var updateOpenRPC = flag.Bool("update-openrpc", false, "rewrite openrpc.json from rpc.discover")
+
+// TestOpenRPCDocument checks that rpc.discover returns the committed
+// openrpc.json, so that changes of the JSON-RPC contract show up in review,
+// and that the document has every method the README calls.
+func TestOpenRPCDocument(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	handler, err := server.NewRouter(&types.AppConfig{}, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader([]byte(`{"jsonrpc": "2.0", "method": "rpc.discover", "id": "1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil || len(reply.Result) == 0 {
+		t.Fatalf("rpc.discover: %v in %s", err, rec.Body.String())
+	}
+	if *updateOpenRPC {
+		var out bytes.Buffer
+		if err := json.Indent(&out, reply.Result, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		out.WriteByte('\n')
+		if err := os.WriteFile("openrpc.json", out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var served, committed struct {
+		Methods []struct {
+			Name   string `json:"name"`
+			Params []struct {
+				Name   string                 `json:"name"`
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"params"`
+		} `json:"methods"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(reply.Result, &served); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("openrpc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &committed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(served, committed) {
+		t.Fatal("the JSON-RPC API changed: review it, and run go test -run TestOpenRPCDocument -update-openrpc to update openrpc.json")
+	}
+
+	methods := map[string]int{}
+	for i, method := range served.Methods {
+		methods[method.Name] = i
+	}
+	readme, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, match := range regexp.MustCompile(`"method": "([^"]+)"`).FindAllStringSubmatch(string(readme), -1) {
+		if _, ok := methods[match[1]]; !ok {
+			t.Errorf("README calls %s, which is not in the OpenRPC document", match[1])
+		}
+	}
+
+	sign := served.Methods[methods["signer.SignMessage"]]
+	if len(sign.Params) != 1 || sign.Params[0].Name != "data" || sign.Params[0].Schema["$ref"] != "#/components/schemas/SignRequest" {
+		t.Fatalf("unexpected params of signer.SignMessage %+v", sign.Params)
+	}
+	properties := served.Components.Schemas["SignRequest"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, name := range []string{"hash", "pubkey", "message", "epoch", "callbackUrl"} {
+		if properties[name] == nil {
+			t.Fatalf("SignRequest has no %s: %v", name, properties)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package pb
+
+import (
+	_ "embed"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorSet is the descriptor set of the proto files, generated with the
+// Go code of this package.
+//
+//go:embed descriptor.pb
+var DescriptorSet []byte
+
+// Files returns the files of DescriptorSet.
+func Files() (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(DescriptorSet, &set); err != nil {
+		return nil, err
+	}
+	return protodesc.NewFiles(&set)
+}
@@ -22,9 +22,24 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TssServiceClient interface {
-	SignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*RVSignatureReply, error)
-	RegisterDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*DkgReply, error)
-	Reshare(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (*ServiceReply, error)
+	GetSignerConfig(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*KeyView, error)
+	SignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*Job, error)
+	SelfSignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*Job, error)
+	RegisterDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*Job, error)
+	RegisterSelfDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*Job, error)
+	Reshare(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (*Job, error)
+	GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	GetDKG(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyView, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysReply, error)
+	QueryLedger(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*LedgerReply, error)
+	CheckSignature(ctx context.Context, in *CheckSignatureByPubkeyRequest, opts ...grpc.CallOption) (*CheckSignatureReply, error)
+	// The streaming variants start the same jobs, and stream their progress
+	// until the last event, which carries the finished job and its result. The
+	// job keeps running if the client goes away.
+	SignMessageStream(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (TssService_SignMessageStreamClient, error)
+	RegisterDKGStream(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (TssService_RegisterDKGStreamClient, error)
+	ReshareStream(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (TssService_ReshareStreamClient, error)
 }
 
 type tssServiceClient struct {
@@ -35,8 +50,17 @@ func NewTssServiceClient(cc grpc.ClientConnInterface) TssServiceClient {
 	return &tssServiceClient{cc}
 }
 
-func (c *tssServiceClient) SignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*RVSignatureReply, error) {
-	out := new(RVSignatureReply)
+func (c *tssServiceClient) GetSignerConfig(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*KeyView, error) {
+	out := new(KeyView)
+	err := c.cc.Invoke(ctx, "/pb.TssService/GetSignerConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) SignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
 	err := c.cc.Invoke(ctx, "/pb.TssService/SignMessage", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -44,8 +68,17 @@ func (c *tssServiceClient) SignMessage(ctx context.Context, in *SignRequest, opt
 	return out, nil
 }
 
-func (c *tssServiceClient) RegisterDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*DkgReply, error) {
-	out := new(DkgReply)
+func (c *tssServiceClient) SelfSignMessage(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, "/pb.TssService/SelfSignMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) RegisterDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
 	err := c.cc.Invoke(ctx, "/pb.TssService/RegisterDKG", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -53,8 +86,17 @@ func (c *tssServiceClient) RegisterDKG(ctx context.Context, in *DKGRequest, opts
 	return out, nil
 }
 
-func (c *tssServiceClient) Reshare(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (*ServiceReply, error) {
-	out := new(ServiceReply)
+func (c *tssServiceClient) RegisterSelfDKG(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, "/pb.TssService/RegisterSelfDKG", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) Reshare(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
 	err := c.cc.Invoke(ctx, "/pb.TssService/Reshare", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -62,13 +104,178 @@ func (c *tssServiceClient) Reshare(ctx context.Context, in *ReshareRequest, opts
 	return out, nil
 }
 
+func (c *tssServiceClient) GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, "/pb.TssService/GetJob", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, "/pb.TssService/CancelJob", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) GetDKG(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyView, error) {
+	out := new(KeyView)
+	err := c.cc.Invoke(ctx, "/pb.TssService/GetDKG", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysReply, error) {
+	out := new(ListKeysReply)
+	err := c.cc.Invoke(ctx, "/pb.TssService/ListKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) QueryLedger(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*LedgerReply, error) {
+	out := new(LedgerReply)
+	err := c.cc.Invoke(ctx, "/pb.TssService/QueryLedger", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) CheckSignature(ctx context.Context, in *CheckSignatureByPubkeyRequest, opts ...grpc.CallOption) (*CheckSignatureReply, error) {
+	out := new(CheckSignatureReply)
+	err := c.cc.Invoke(ctx, "/pb.TssService/CheckSignature", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tssServiceClient) SignMessageStream(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (TssService_SignMessageStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TssService_ServiceDesc.Streams[0], "/pb.TssService/SignMessageStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tssServiceSignMessageStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TssService_SignMessageStreamClient interface {
+	Recv() (*ProgressEvent, error)
+	grpc.ClientStream
+}
+
+type tssServiceSignMessageStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *tssServiceSignMessageStreamClient) Recv() (*ProgressEvent, error) {
+	m := new(ProgressEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tssServiceClient) RegisterDKGStream(ctx context.Context, in *DKGRequest, opts ...grpc.CallOption) (TssService_RegisterDKGStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TssService_ServiceDesc.Streams[1], "/pb.TssService/RegisterDKGStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tssServiceRegisterDKGStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TssService_RegisterDKGStreamClient interface {
+	Recv() (*ProgressEvent, error)
+	grpc.ClientStream
+}
+
+type tssServiceRegisterDKGStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *tssServiceRegisterDKGStreamClient) Recv() (*ProgressEvent, error) {
+	m := new(ProgressEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tssServiceClient) ReshareStream(ctx context.Context, in *ReshareRequest, opts ...grpc.CallOption) (TssService_ReshareStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TssService_ServiceDesc.Streams[2], "/pb.TssService/ReshareStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tssServiceReshareStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TssService_ReshareStreamClient interface {
+	Recv() (*ProgressEvent, error)
+	grpc.ClientStream
+}
+
+type tssServiceReshareStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *tssServiceReshareStreamClient) Recv() (*ProgressEvent, error) {
+	m := new(ProgressEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TssServiceServer is the server API for TssService service.
 // All implementations must embed UnimplementedTssServiceServer
 // for forward compatibility
 type TssServiceServer interface {
-	SignMessage(context.Context, *SignRequest) (*RVSignatureReply, error)
-	RegisterDKG(context.Context, *DKGRequest) (*DkgReply, error)
-	Reshare(context.Context, *ReshareRequest) (*ServiceReply, error)
+	GetSignerConfig(context.Context, *SignRequest) (*KeyView, error)
+	SignMessage(context.Context, *SignRequest) (*Job, error)
+	SelfSignMessage(context.Context, *SignRequest) (*Job, error)
+	RegisterDKG(context.Context, *DKGRequest) (*Job, error)
+	RegisterSelfDKG(context.Context, *DKGRequest) (*Job, error)
+	Reshare(context.Context, *ReshareRequest) (*Job, error)
+	GetJob(context.Context, *JobRequest) (*Job, error)
+	CancelJob(context.Context, *JobRequest) (*Job, error)
+	GetDKG(context.Context, *KeyRequest) (*KeyView, error)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysReply, error)
+	QueryLedger(context.Context, *LedgerQuery) (*LedgerReply, error)
+	CheckSignature(context.Context, *CheckSignatureByPubkeyRequest) (*CheckSignatureReply, error)
+	// The streaming variants start the same jobs, and stream their progress
+	// until the last event, which carries the finished job and its result. The
+	// job keeps running if the client goes away.
+	SignMessageStream(*SignRequest, TssService_SignMessageStreamServer) error
+	RegisterDKGStream(*DKGRequest, TssService_RegisterDKGStreamServer) error
+	ReshareStream(*ReshareRequest, TssService_ReshareStreamServer) error
 	mustEmbedUnimplementedTssServiceServer()
 }
 
@@ -76,15 +283,51 @@ type TssServiceServer interface {
 type UnimplementedTssServiceServer struct {
 }
 
-func (UnimplementedTssServiceServer) SignMessage(context.Context, *SignRequest) (*RVSignatureReply, error) {
+func (UnimplementedTssServiceServer) GetSignerConfig(context.Context, *SignRequest) (*KeyView, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignerConfig not implemented")
+}
+func (UnimplementedTssServiceServer) SignMessage(context.Context, *SignRequest) (*Job, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SignMessage not implemented")
 }
-func (UnimplementedTssServiceServer) RegisterDKG(context.Context, *DKGRequest) (*DkgReply, error) {
+func (UnimplementedTssServiceServer) SelfSignMessage(context.Context, *SignRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SelfSignMessage not implemented")
+}
+func (UnimplementedTssServiceServer) RegisterDKG(context.Context, *DKGRequest) (*Job, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RegisterDKG not implemented")
 }
-func (UnimplementedTssServiceServer) Reshare(context.Context, *ReshareRequest) (*ServiceReply, error) {
+func (UnimplementedTssServiceServer) RegisterSelfDKG(context.Context, *DKGRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSelfDKG not implemented")
+}
+func (UnimplementedTssServiceServer) Reshare(context.Context, *ReshareRequest) (*Job, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Reshare not implemented")
 }
+func (UnimplementedTssServiceServer) GetJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedTssServiceServer) CancelJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedTssServiceServer) GetDKG(context.Context, *KeyRequest) (*KeyView, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDKG not implemented")
+}
+func (UnimplementedTssServiceServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedTssServiceServer) QueryLedger(context.Context, *LedgerQuery) (*LedgerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryLedger not implemented")
+}
+func (UnimplementedTssServiceServer) CheckSignature(context.Context, *CheckSignatureByPubkeyRequest) (*CheckSignatureReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckSignature not implemented")
+}
+func (UnimplementedTssServiceServer) SignMessageStream(*SignRequest, TssService_SignMessageStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SignMessageStream not implemented")
+}
+func (UnimplementedTssServiceServer) RegisterDKGStream(*DKGRequest, TssService_RegisterDKGStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method RegisterDKGStream not implemented")
+}
+func (UnimplementedTssServiceServer) ReshareStream(*ReshareRequest, TssService_ReshareStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReshareStream not implemented")
+}
 func (UnimplementedTssServiceServer) mustEmbedUnimplementedTssServiceServer() {}
 
 // UnsafeTssServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -98,6 +341,24 @@ func RegisterTssServiceServer(s grpc.ServiceRegistrar, srv TssServiceServer) {
 	s.RegisterService(&TssService_ServiceDesc, srv)
 }
 
+func _TssService_GetSignerConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).GetSignerConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/GetSignerConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).GetSignerConfig(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TssService_SignMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SignRequest)
 	if err := dec(in); err != nil {
@@ -116,6 +377,24 @@ func _TssService_SignMessage_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TssService_SelfSignMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).SelfSignMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/SelfSignMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).SelfSignMessage(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TssService_RegisterDKG_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DKGRequest)
 	if err := dec(in); err != nil {
@@ -134,6 +413,24 @@ func _TssService_RegisterDKG_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TssService_RegisterSelfDKG_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DKGRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).RegisterSelfDKG(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/RegisterSelfDKG",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).RegisterSelfDKG(ctx, req.(*DKGRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TssService_Reshare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReshareRequest)
 	if err := dec(in); err != nil {
@@ -152,24 +449,657 @@ func _TssService_Reshare_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
-// TssService_ServiceDesc is the grpc.ServiceDesc for TssService service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var TssService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "pb.TssService",
-	HandlerType: (*TssServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "SignMessage",
-			Handler:    _TssService_SignMessage_Handler,
-		},
-		{
-			MethodName: "RegisterDKG",
-			Handler:    _TssService_RegisterDKG_Handler,
-		},
-		{
-			MethodName: "Reshare",
-			Handler:    _TssService_Reshare_Handler,
+func _TssService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/GetJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).GetJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/CancelJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).CancelJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_GetDKG_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).GetDKG(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/GetDKG",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).GetDKG(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/ListKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_QueryLedger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).QueryLedger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/QueryLedger",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).QueryLedger(ctx, req.(*LedgerQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_CheckSignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckSignatureByPubkeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TssServiceServer).CheckSignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TssService/CheckSignature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TssServiceServer).CheckSignature(ctx, req.(*CheckSignatureByPubkeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TssService_SignMessageStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SignRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TssServiceServer).SignMessageStream(m, &tssServiceSignMessageStreamServer{stream})
+}
+
+type TssService_SignMessageStreamServer interface {
+	Send(*ProgressEvent) error
+	grpc.ServerStream
+}
+
+type tssServiceSignMessageStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *tssServiceSignMessageStreamServer) Send(m *ProgressEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TssService_RegisterDKGStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DKGRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TssServiceServer).RegisterDKGStream(m, &tssServiceRegisterDKGStreamServer{stream})
+}
+
+type TssService_RegisterDKGStreamServer interface {
+	Send(*ProgressEvent) error
+	grpc.ServerStream
+}
+
+type tssServiceRegisterDKGStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *tssServiceRegisterDKGStreamServer) Send(m *ProgressEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TssService_ReshareStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReshareRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TssServiceServer).ReshareStream(m, &tssServiceReshareStreamServer{stream})
+}
+
+type TssService_ReshareStreamServer interface {
+	Send(*ProgressEvent) error
+	grpc.ServerStream
+}
+
+type tssServiceReshareStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *tssServiceReshareStreamServer) Send(m *ProgressEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TssService_ServiceDesc is the grpc.ServiceDesc for TssService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TssService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.TssService",
+	HandlerType: (*TssServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSignerConfig",
+			Handler:    _TssService_GetSignerConfig_Handler,
+		},
+		{
+			MethodName: "SignMessage",
+			Handler:    _TssService_SignMessage_Handler,
+		},
+		{
+			MethodName: "SelfSignMessage",
+			Handler:    _TssService_SelfSignMessage_Handler,
+		},
+		{
+			MethodName: "RegisterDKG",
+			Handler:    _TssService_RegisterDKG_Handler,
+		},
+		{
+			MethodName: "RegisterSelfDKG",
+			Handler:    _TssService_RegisterSelfDKG_Handler,
+		},
+		{
+			MethodName: "Reshare",
+			Handler:    _TssService_Reshare_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _TssService_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _TssService_CancelJob_Handler,
+		},
+		{
+			MethodName: "GetDKG",
+			Handler:    _TssService_GetDKG_Handler,
+		},
+		{
+			MethodName: "ListKeys",
+			Handler:    _TssService_ListKeys_Handler,
+		},
+		{
+			MethodName: "QueryLedger",
+			Handler:    _TssService_QueryLedger_Handler,
+		},
+		{
+			MethodName: "CheckSignature",
+			Handler:    _TssService_CheckSignature_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SignMessageStream",
+			Handler:       _TssService_SignMessageStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RegisterDKGStream",
+			Handler:       _TssService_RegisterDKGStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReshareStream",
+			Handler:       _TssService_ReshareStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tss.proto",
+}
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	GetSignerConfig(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignerConfig, error)
+	RollbackEpoch(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*ServiceReply, error)
+	SetKeyState(ctx context.Context, in *KeyStateRequest, opts ...grpc.CallOption) (*ServiceReply, error)
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupStatusReply, error)
+	BackupStatus(ctx context.Context, in *BackupStatusRequest, opts ...grpc.CallOption) (*BackupStatusReply, error)
+	SetAccessRule(ctx context.Context, in *AccessRule, opts ...grpc.CallOption) (*AccessRule, error)
+	GetAccessRule(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*AccessRule, error)
+	DeleteAccessRule(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*ServiceReply, error)
+	ListAccessRules(ctx context.Context, in *ListAccessRulesRequest, opts ...grpc.CallOption) (*ListAccessRulesReply, error)
+	ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesReply, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) GetSignerConfig(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignerConfig, error) {
+	out := new(SignerConfig)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/GetSignerConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RollbackEpoch(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*ServiceReply, error) {
+	out := new(ServiceReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/RollbackEpoch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetKeyState(ctx context.Context, in *KeyStateRequest, opts ...grpc.CallOption) (*ServiceReply, error) {
+	out := new(ServiceReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/SetKeyState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupStatusReply, error) {
+	out := new(BackupStatusReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/Backup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) BackupStatus(ctx context.Context, in *BackupStatusRequest, opts ...grpc.CallOption) (*BackupStatusReply, error) {
+	out := new(BackupStatusReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/BackupStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetAccessRule(ctx context.Context, in *AccessRule, opts ...grpc.CallOption) (*AccessRule, error) {
+	out := new(AccessRule)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/SetAccessRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetAccessRule(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*AccessRule, error) {
+	out := new(AccessRule)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/GetAccessRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DeleteAccessRule(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*ServiceReply, error) {
+	out := new(ServiceReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/DeleteAccessRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAccessRules(ctx context.Context, in *ListAccessRulesRequest, opts ...grpc.CallOption) (*ListAccessRulesReply, error) {
+	out := new(ListAccessRulesReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/ListAccessRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesReply, error) {
+	out := new(ListWebhookDeliveriesReply)
+	err := c.cc.Invoke(ctx, "/pb.AdminService/ListWebhookDeliveries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	GetSignerConfig(context.Context, *SignRequest) (*SignerConfig, error)
+	RollbackEpoch(context.Context, *RollbackRequest) (*ServiceReply, error)
+	SetKeyState(context.Context, *KeyStateRequest) (*ServiceReply, error)
+	Backup(context.Context, *BackupRequest) (*BackupStatusReply, error)
+	BackupStatus(context.Context, *BackupStatusRequest) (*BackupStatusReply, error)
+	SetAccessRule(context.Context, *AccessRule) (*AccessRule, error)
+	GetAccessRule(context.Context, *SubjectRequest) (*AccessRule, error)
+	DeleteAccessRule(context.Context, *SubjectRequest) (*ServiceReply, error)
+	ListAccessRules(context.Context, *ListAccessRulesRequest) (*ListAccessRulesReply, error)
+	ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesReply, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) GetSignerConfig(context.Context, *SignRequest) (*SignerConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignerConfig not implemented")
+}
+func (UnimplementedAdminServiceServer) RollbackEpoch(context.Context, *RollbackRequest) (*ServiceReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RollbackEpoch not implemented")
+}
+func (UnimplementedAdminServiceServer) SetKeyState(context.Context, *KeyStateRequest) (*ServiceReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetKeyState not implemented")
+}
+func (UnimplementedAdminServiceServer) Backup(context.Context, *BackupRequest) (*BackupStatusReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedAdminServiceServer) BackupStatus(context.Context, *BackupStatusRequest) (*BackupStatusReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BackupStatus not implemented")
+}
+func (UnimplementedAdminServiceServer) SetAccessRule(context.Context, *AccessRule) (*AccessRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAccessRule not implemented")
+}
+func (UnimplementedAdminServiceServer) GetAccessRule(context.Context, *SubjectRequest) (*AccessRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccessRule not implemented")
+}
+func (UnimplementedAdminServiceServer) DeleteAccessRule(context.Context, *SubjectRequest) (*ServiceReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccessRule not implemented")
+}
+func (UnimplementedAdminServiceServer) ListAccessRules(context.Context, *ListAccessRulesRequest) (*ListAccessRulesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAccessRules not implemented")
+}
+func (UnimplementedAdminServiceServer) ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeliveries not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_GetSignerConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetSignerConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/GetSignerConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetSignerConfig(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RollbackEpoch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RollbackEpoch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/RollbackEpoch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RollbackEpoch(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetKeyState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetKeyState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/SetKeyState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetKeyState(ctx, req.(*KeyStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Backup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BackupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Backup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/Backup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Backup(ctx, req.(*BackupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_BackupStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BackupStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).BackupStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/BackupStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).BackupStatus(ctx, req.(*BackupStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetAccessRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccessRule)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetAccessRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/SetAccessRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetAccessRule(ctx, req.(*AccessRule))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetAccessRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetAccessRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/GetAccessRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetAccessRule(ctx, req.(*SubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DeleteAccessRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DeleteAccessRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/DeleteAccessRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DeleteAccessRule(ctx, req.(*SubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListAccessRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAccessRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAccessRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/ListAccessRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAccessRules(ctx, req.(*ListAccessRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdminService/ListWebhookDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListWebhookDeliveries(ctx, req.(*ListWebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSignerConfig",
+			Handler:    _AdminService_GetSignerConfig_Handler,
+		},
+		{
+			MethodName: "RollbackEpoch",
+			Handler:    _AdminService_RollbackEpoch_Handler,
+		},
+		{
+			MethodName: "SetKeyState",
+			Handler:    _AdminService_SetKeyState_Handler,
+		},
+		{
+			MethodName: "Backup",
+			Handler:    _AdminService_Backup_Handler,
+		},
+		{
+			MethodName: "BackupStatus",
+			Handler:    _AdminService_BackupStatus_Handler,
+		},
+		{
+			MethodName: "SetAccessRule",
+			Handler:    _AdminService_SetAccessRule_Handler,
+		},
+		{
+			MethodName: "GetAccessRule",
+			Handler:    _AdminService_GetAccessRule_Handler,
+		},
+		{
+			MethodName: "DeleteAccessRule",
+			Handler:    _AdminService_DeleteAccessRule_Handler,
+		},
+		{
+			MethodName: "ListAccessRules",
+			Handler:    _AdminService_ListAccessRules_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeliveries",
+			Handler:    _AdminService_ListWebhookDeliveries_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
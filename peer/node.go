@@ -1,6 +1,7 @@
 package peer
 
 import (
+	"alice-tss/metrics"
 	"alice-tss/utils"
 	"context"
 	"crypto/ecdsa"
@@ -120,10 +121,12 @@ func send(ctx context.Context, host host.Host, target string, data interface{},
 		log.Info("NewStream", "id", info.ID, "protocol", protocol, "addr", info.Addrs, "info", info.String())
 
 		s, err = host.NewStream(ctx, info.ID, protocol)
-		if err != nil {
-			log.Warn("Try create a new stream", "after", fmt.Sprintf("%d miliseconds", delayRetryCreateStream), "to", target, "err", err)
-			time.Sleep(delayRetryCreateStream)
+		if err == nil {
+			break
 		}
+		metrics.StreamRetried(info.ID.String())
+		log.Warn("Try create a new stream", "after", fmt.Sprintf("%d miliseconds", delayRetryCreateStream), "to", target, "err", err)
+		time.Sleep(delayRetryCreateStream)
 	}
 	if s == nil {
 		log.Error("Cannot create a new stream", "from", host.ID(), "to", target, "err", err)
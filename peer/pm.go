@@ -1,6 +1,7 @@
 package peer
 
 import (
+	"alice-tss/metrics"
 	"context"
 	"fmt"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/getamis/sirius/log"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
@@ -17,7 +19,16 @@ type P2PManager struct {
 	id       string
 	Host     host.Host
 	protocol protocol.ID
+	book     *peerBook
+}
+
+// peerBook is what the clones of a P2PManager share: the peers and when they
+// were last seen.
+type peerBook struct {
+	mu       sync.RWMutex
 	peers    map[string]string
+	lastSeen map[string]time.Time
+	tracking bool
 }
 
 func NewPeerManager(id string, host host.Host, protocol protocol.ID) *P2PManager {
@@ -27,7 +38,10 @@ func NewPeerManager(id string, host host.Host, protocol protocol.ID) *P2PManager
 		id:       id,
 		Host:     host,
 		protocol: protocol,
-		peers:    make(map[string]string),
+		book: &peerBook{
+			peers:    make(map[string]string),
+			lastSeen: make(map[string]time.Time),
+		},
 	}
 }
 
@@ -39,7 +53,9 @@ func (p *P2PManager) ClonePeerManager(protocol protocol.ID) *P2PManager {
 }
 
 func (p *P2PManager) NumPeers() uint32 {
-	return uint32(len(p.peers))
+	p.book.mu.RLock()
+	defer p.book.mu.RUnlock()
+	return uint32(len(p.book.peers))
 }
 
 func (p *P2PManager) SelfID() string {
@@ -47,9 +63,11 @@ func (p *P2PManager) SelfID() string {
 }
 
 func (p *P2PManager) PeerIDs() []string {
-	ids := make([]string, len(p.peers))
+	p.book.mu.RLock()
+	defer p.book.mu.RUnlock()
+	ids := make([]string, len(p.book.peers))
 	i := 0
-	for id := range p.peers {
+	for id := range p.book.peers {
 		log.Debug("Peer ID", "id", id)
 		ids[i] = id
 		i++
@@ -57,8 +75,68 @@ func (p *P2PManager) PeerIDs() []string {
 	return ids
 }
 
+// Peers returns the addresses of the peers, by ID.
 func (p *P2PManager) Peers() map[string]string {
-	return p.peers
+	p.book.mu.RLock()
+	defer p.book.mu.RUnlock()
+	peers := make(map[string]string, len(p.book.peers))
+	for id, addr := range p.book.peers {
+		peers[id] = addr
+	}
+	return peers
+}
+
+// TrackPeers records when the host was last connected to each peer, for
+// LastSeen, and the number of connected peers in the metrics. Tracking lasts as long as the host; it starts once however often
+// it is called.
+func (p *P2PManager) TrackPeers() {
+	p.book.mu.Lock()
+	defer p.book.mu.Unlock()
+	if p.book.tracking {
+		return
+	}
+	p.book.tracking = true
+	seen := func(n network.Network, conn network.Conn) {
+		p.book.mu.Lock()
+		p.book.lastSeen[conn.RemotePeer().String()] = time.Now()
+		p.book.mu.Unlock()
+		metrics.SetConnectedPeers(len(n.Peers()))
+	}
+	metrics.SetConnectedPeers(len(p.Host.Network().Peers()))
+	p.Host.Network().Notify(&network.NotifyBundle{ConnectedF: seen, DisconnectedF: seen})
+}
+
+// Connected reports whether the host is connected to peerID.
+func (p *P2PManager) Connected(peerID string) bool {
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return false
+	}
+	return p.Host.Network().Connectedness(id) == network.Connected
+}
+
+// LastSeen returns when the host was last connected to peerID: now when it
+// is, and zero when it was not since TrackPeers.
+func (p *P2PManager) LastSeen(peerID string) time.Time {
+	if p.Connected(peerID) {
+		return time.Now()
+	}
+	p.book.mu.RLock()
+	defer p.book.mu.RUnlock()
+	return p.book.lastSeen[peerID]
+}
+
+// Addrs returns the known addresses of peerID.
+func (p *P2PManager) Addrs(peerID string) []string {
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return nil
+	}
+	var addrs []string
+	for _, addr := range p.Host.Peerstore().Addrs(id) {
+		addrs = append(addrs, addr.String())
+	}
+	return addrs
 }
 
 func (p *P2PManager) SetProtocol(id protocol.ID) {
@@ -66,10 +144,13 @@ func (p *P2PManager) SetProtocol(id protocol.ID) {
 }
 
 func (p *P2PManager) MustSend(peerID string, message interface{}) {
-	target := p.peers[peerID]
+	p.book.mu.RLock()
+	target := p.book.peers[peerID]
+	p.book.mu.RUnlock()
 
 	log.Info("P2PManager MustSend", "peerID", peerID, "protocol", p.protocol, "target", target)
 	err := send(context.Background(), p.Host, target, message, p.protocol)
+	metrics.MessageSent(peerID, err)
 	if err != nil {
 		log.Error("MustSend", "err", err, "protocol", p.protocol)
 		return
@@ -80,7 +161,7 @@ func (p *P2PManager) MustSend(peerID string, message interface{}) {
 func (p *P2PManager) EnsureAllConnected() {
 	log.Info("P2PManager", "call", "EnsureAllConnected", "num peers", p.NumPeers())
 	var wg sync.WaitGroup
-	for _, peerAddr := range p.peers {
+	for _, peerAddr := range p.Peers() {
 		wg.Add(1)
 		go connectToPeer(p.Host, peerAddr, &wg)
 	}
@@ -92,7 +173,9 @@ func (p *P2PManager) AddPeerID(peerID peer.ID, addr string) {
 	log.Info("P2PManager AddPeerID", "id", p.Host.ID(), "peerID", peerID, "addr", addr)
 	peerAddr := fmt.Sprintf("%s/p2p/%s", addr, peerID)
 	log.Info("P2PManager", "action", "peer added", "addr", peerAddr)
-	p.peers[peerID.String()] = peerAddr
+	p.book.mu.Lock()
+	p.book.peers[peerID.String()] = peerAddr
+	p.book.mu.Unlock()
 	log.Info("P2PManager", "num peers", p.NumPeers())
 	return
 }
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"alice-tss/pb"
+	"alice-tss/peer"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+)
+
+// ErrSharesNotExposed is returned by admin.GetSignerConfig when the node is
+// not configured to expose shares.
+var ErrSharesNotExposed = errors.New("shares are not exposed, set admin.exposeShares to enable")
+
+// AdminService exposes cluster administration over JSON-RPC under the "admin"
+// service name, separate from the signing API of RpcService.
+type AdminService struct {
+	pm        *peer.P2PManager
+	tssCaller *TssCaller
+	backups   *backupRunner
+	// exposeShares enables GetSignerConfig, see types.AdminConfig.
+	exposeShares bool
+	authz        *authorizer
+}
+
+// GetSignerConfig returns the signer configuration of a key with its
+// decrypted share. It is disabled unless admin.exposeShares is set.
+func (h *AdminService) GetSignerConfig(r *http.Request, args *types.RpcArgs[types.KeyRequest], reply *types.RpcReply[*types.SignerConfig]) error {
+	log.Info("RPC admin GetSignerConfig called")
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+	if !h.exposeShares {
+		log.Warn("Refused to expose a share", "reason", "admin.exposeShares is not set")
+		return ErrSharesNotExposed
+	}
+
+	result, err := h.tssCaller.GetSignerConfig(&pb.SignRequest{Hash: args.Data.Hash, Pubkey: args.Data.Pubkey})
+	if err != nil {
+		log.Error("Failed to get signer config", "error", err)
+		return err
+	}
+	log.Warn("Exposed a share over admin RPC", "hash", args.Data.Hash)
+
+	reply.Data = result
+	return nil
+}
+
+// RollbackEpoch rolls a key back to a previous share epoch on every holder,
+// provided that all of them still hold it.
+func (h *AdminService) RollbackEpoch(r *http.Request, args *types.RpcArgs[types.RollbackRequest], reply *types.RpcReply[types.RollbackReply]) error {
+	log.Info("RPC admin RollbackEpoch called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	rollbackRequest := &pb.RollbackRequest{Hash: args.Data.Hash, Pubkey: args.Data.Pubkey, Epoch: args.Data.Epoch}
+	if err := h.tssCaller.RollbackEpoch(r.Context(), h.pm, rollbackRequest); err != nil {
+		log.Error("Failed to roll back share epoch", "hash", rollbackRequest.Hash, "error", err)
+		return err
+	}
+
+	reply.Data = types.RollbackReply{Hash: rollbackRequest.Hash, Epoch: rollbackRequest.Epoch}
+	return nil
+}
+
+// SetKeyState moves a key to another lifecycle state (active, frozen, retired
+// or destroyed) on every holder. Destroying a key erases its shares for good.
+func (h *AdminService) SetKeyState(r *http.Request, args *types.RpcArgs[types.KeyStateRequest], reply *types.RpcReply[types.KeyStateReply]) error {
+	log.Info("RPC admin SetKeyState called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	keyStateRequest := &pb.KeyStateRequest{Hash: args.Data.Hash, Pubkey: args.Data.Pubkey, State: string(args.Data.State)}
+	if err := h.tssCaller.SetKeyState(r.Context(), h.pm, keyStateRequest); err != nil {
+		log.Error("Failed to set key state", "hash", keyStateRequest.Hash, "error", err)
+		return err
+	}
+
+	reply.Data = types.KeyStateReply{Hash: args.Data.Hash, State: args.Data.State}
+	return nil
+}
+
+// Backup starts streaming a consistent snapshot of the store to a file in the
+// backup directory while the node keeps serving. It returns at once; poll
+// BackupStatus for the outcome.
+func (h *AdminService) Backup(r *http.Request, args *types.RpcArgs[types.BackupRequest], reply *types.RpcReply[types.BackupStatus]) error {
+	log.Info("RPC admin Backup called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	status, err := h.backups.Start(args.Data.Name)
+	if err != nil {
+		log.Error("Failed to start backup", "error", err)
+		return err
+	}
+	reply.Data = status
+	return nil
+}
+
+// BackupStatus reports the progress or outcome of the last backup.
+func (h *AdminService) BackupStatus(r *http.Request, _ *types.RpcNoneArgs, reply *types.RpcReply[types.BackupStatus]) error {
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+	reply.Data = h.backups.Status()
+	return nil
+}
+
+// SetAccessRule creates or replaces the access rule of an API caller: its
+// roles and the keys it may use.
+func (h *AdminService) SetAccessRule(r *http.Request, args *types.RpcArgs[types.AccessRule], reply *types.RpcReply[types.AccessRule]) error {
+	log.Info("RPC admin SetAccessRule called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	rule := args.Data
+	if err := checkSubject(rule.Subject); err != nil {
+		return err
+	}
+	if err := h.tssCaller.StoreDB.SetAccessRule(&rule); err != nil {
+		log.Error("Failed to set access rule", "subject", rule.Subject, "error", err)
+		return err
+	}
+	reply.Data = rule
+	return nil
+}
+
+// GetAccessRule returns the access rule of the API caller named by key.
+func (h *AdminService) GetAccessRule(r *http.Request, args *types.RpcKeyArgs, reply *types.RpcReply[*types.AccessRule]) error {
+	log.Info("RPC admin GetAccessRule called", "subject", args.Key)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	rule, err := h.tssCaller.StoreDB.GetAccessRule(args.Key)
+	if err != nil {
+		log.Error("Failed to get access rule", "subject", args.Key, "error", err)
+		return err
+	}
+	reply.Data = rule
+	return nil
+}
+
+// DeleteAccessRule removes the access rule of the API caller named by key,
+// who can then no longer call the node.
+func (h *AdminService) DeleteAccessRule(r *http.Request, args *types.RpcKeyArgs, reply *types.RpcReply[string]) error {
+	log.Info("RPC admin DeleteAccessRule called", "subject", args.Key)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	if err := h.tssCaller.StoreDB.DeleteAccessRule(args.Key); err != nil {
+		log.Error("Failed to delete access rule", "subject", args.Key, "error", err)
+		return err
+	}
+	reply.Data = args.Key
+	return nil
+}
+
+// ListAccessRules returns every access rule.
+func (h *AdminService) ListAccessRules(r *http.Request, _ *types.RpcNoneArgs, reply *types.RpcReply[[]types.AccessRule]) error {
+	log.Info("RPC admin ListAccessRules called")
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	rules, err := h.tssCaller.StoreDB.ListAccessRules()
+	if err != nil {
+		log.Error("Failed to list access rules", "error", err)
+		return err
+	}
+	reply.Data = rules
+	return nil
+}
+
+// ListWebhookDeliveries returns the webhook deliveries of finished jobs, all
+// of them or only those with the status given in the data.
+func (h *AdminService) ListWebhookDeliveries(r *http.Request, args *types.RpcArgs[types.WebhookQuery], reply *types.RpcReply[[]types.WebhookDelivery]) error {
+	log.Info("RPC admin ListWebhookDeliveries called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+
+	deliveries, err := h.tssCaller.StoreDB.ListWebhookDeliveries(args.Data.Status)
+	if err != nil {
+		log.Error("Failed to list webhook deliveries", "error", err)
+		return err
+	}
+	reply.Data = deliveries
+	return nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"alice-tss/auth"
+	"alice-tss/peer"
+	"alice-tss/store"
+	"alice-tss/types"
+)
+
+// nodeAPI is what the JSON-RPC and gRPC APIs of a node share: how callers are
+// authenticated, authorized and bounded in time, and the jobs and backups
+// they start.
+type nodeAPI struct {
+	config      *types.AppConfig
+	pm          *peer.P2PManager
+	storeDB     store.HandlerData
+	selfService *SelfService
+	tssCaller   *TssCaller
+	// peerCaller runs the sessions that peers ask for.
+	peerCaller    *TssCaller
+	authenticator *auth.Authenticator
+	authz         *authorizer
+	jobs          *jobRunner
+	backups       *backupRunner
+	timeouts      callTimeouts
+	holders       holdersCache
+	// stopWebhooks stops delivering webhooks.
+	stopWebhooks func()
+}
+
+func newNodeAPI(config *types.AppConfig, pm *peer.P2PManager, storeDB store.HandlerData, selfService *SelfService) (*nodeAPI, error) {
+	authenticator, err := newAuthenticator(config)
+	if err != nil {
+		return nil, err
+	}
+	if pm != nil {
+		pm.TrackPeers()
+	}
+	webhooks, err := newWebhookDispatcher(storeDB, config.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: %w", err)
+	}
+	jobs, err := newJobRunner(storeDB, webhooks)
+	if err != nil {
+		return nil, err
+	}
+	stopWebhooks := func() {}
+	if webhooks != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			webhooks.Run(ctx)
+			close(done)
+		}()
+		stopWebhooks = func() {
+			cancel()
+			<-done
+		}
+	}
+	return &nodeAPI{
+		config:        config,
+		pm:            pm,
+		storeDB:       storeDB,
+		selfService:   selfService,
+		tssCaller:     &TssCaller{StoreDB: storeDB},
+		peerCaller:    &TssCaller{StoreDB: storeDB},
+		authenticator: authenticator,
+		authz:         newAuthorizer(storeDB, config.Auth),
+		jobs:          jobs,
+		backups:       newBackupRunner(storeDB, config.Store.BackupDir),
+		timeouts:      newCallTimeouts(config.Timeouts),
+		stopWebhooks:  stopWebhooks,
+	}, nil
+}
+
+// close stops the background work of the APIs that is not a job.
+func (a *nodeAPI) close() {
+	a.stopWebhooks()
+}
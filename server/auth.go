@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"alice-tss/auth"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcpeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRequestBody bounds the JSON-RPC bodies read for authentication.
+const maxRequestBody = 4 << 20
+
+// AuthHandler authenticates every request before passing it to next, with the
+// caller in its context. Rejected callers get an UNAUTHORIZED JSON-RPC error
+// with HTTP status 401. A nil authenticator lets every request through.
+func AuthHandler(authenticator *auth.Authenticator, next http.Handler) http.Handler {
+	if authenticator == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var call struct {
+			Method string          `json:"method"`
+			ID     json.RawMessage `json:"id"`
+		}
+		method := r.URL.Path
+		if json.Unmarshal(body, &call) == nil && call.Method != "" {
+			method = call.Method
+		}
+		req := &auth.Request{
+			Method: method,
+			Header: r.Header.Get,
+			Body:   body,
+		}
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			req.Certificates = r.TLS.VerifiedChains[0]
+		}
+
+		principal, err := authenticator.Authenticate(req)
+		if err != nil {
+			log.Warn("Rejected RPC caller", "method", method, "remote", r.RemoteAddr, "err", err)
+			writeJSONRPCError(w, http.StatusUnauthorized, call.ID, tsserr.New(tsserr.Unauthorized, "%w", err))
+			return
+		}
+		log.Debug("Authenticated RPC caller", "method", method, "principal", principal)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func writeJSONRPCError(w http.ResponseWriter, httpStatus int, id json.RawMessage, err *tsserr.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	_, _ = w.Write(jsonRPCError(id, err))
+}
+
+// jsonRPCError returns the JSON-RPC response of the call id failing with err.
+func jsonRPCError(id json.RawMessage, err *tsserr.Error) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"error": map[string]interface{}{
+			"code":    err.Code.RPCCode(),
+			"message": err.Message,
+			"data":    err.Data(),
+		},
+		"id": id,
+	})
+	return append(body, '\n')
+}
+
+// grpcAuthRequest builds the auth request of a gRPC call. body is nil for
+// streaming calls, whose messages are not known yet.
+func grpcAuthRequest(ctx context.Context, method string, body []byte) *auth.Request {
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &auth.Request{
+		Method: method,
+		Header: func(name string) string {
+			values := md.Get(strings.ToLower(name))
+			if len(values) == 0 {
+				return ""
+			}
+			return values[0]
+		},
+		Body: body,
+	}
+	if p, ok := grpcpeer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			req.Certificates = info.State.VerifiedChains[0]
+		}
+	}
+	return req
+}
+
+// UnaryAuthInterceptor authenticates unary gRPC calls like AuthHandler does
+// JSON-RPC calls. A nil authenticator lets every call through.
+func UnaryAuthInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if authenticator == nil {
+			return handler(ctx, req)
+		}
+		var body []byte
+		if msg, ok := req.(proto.Message); ok {
+			var err error
+			if body, err = (proto.MarshalOptions{Deterministic: true}).Marshal(msg); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		principal, err := authenticator.Authenticate(grpcAuthRequest(ctx, info.FullMethod, body))
+		if err != nil {
+			log.Warn("Rejected gRPC caller", "method", info.FullMethod, "err", err)
+			return nil, tsserr.New(tsserr.Unauthorized, "%w", err)
+		}
+		return handler(auth.WithPrincipal(ctx, principal), req)
+	}
+}
+
+// StreamAuthInterceptor authenticates streaming gRPC calls. Signed requests
+// sign an empty body, since the messages of a stream come later.
+func StreamAuthInterceptor(authenticator *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if authenticator == nil {
+			return handler(srv, ss)
+		}
+		principal, err := authenticator.Authenticate(grpcAuthRequest(ss.Context(), info.FullMethod, nil))
+		if err != nil {
+			log.Warn("Rejected gRPC caller", "method", info.FullMethod, "err", err)
+			return tsserr.New(tsserr.Unauthorized, "%w", err)
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: auth.WithPrincipal(ss.Context(), principal)})
+	}
+}
+
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+// serverTLSConfig returns the TLS configuration of the APIs, or nil when TLS
+// is not configured. Client certificates are verified against ClientCAFile
+// when given, but not required, so that callers can use other credentials.
+func serverTLSConfig(config types.TLSConfig) (*tls.Config, error) {
+	if config.CertFile == "" && config.KeyFile == "" {
+		if config.ClientCAFile != "" {
+			return nil, errors.New("tls.clientCAFile needs tls.certFile and tls.keyFile")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if config.ClientCAFile != "" {
+		data, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate in %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// newAuthenticator builds the authenticator of config, checking that mTLS
+// has client certificates to work with.
+func newAuthenticator(config *types.AppConfig) (*auth.Authenticator, error) {
+	if config.Auth.MTLS && config.TLS.ClientCAFile == "" {
+		return nil, errors.New("auth.mtls needs tls.clientCAFile")
+	}
+	for _, admin := range config.Auth.Admins {
+		if err := auth.CheckSubject(admin); err != nil {
+			return nil, fmt.Errorf("auth.admins: %w", err)
+		}
+	}
+	authenticator, err := auth.New(config.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if authenticator == nil {
+		log.Warn("API authentication is disabled, anyone reaching the RPC port can use the node")
+	}
+	return authenticator, nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"alice-tss/auth"
+	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+)
+
+// ErrPermissionDenied is returned when the access rule of a caller does not
+// allow a call.
+var ErrPermissionDenied = tsserr.New(tsserr.PermissionDenied, "permission denied")
+
+// authorizer checks callers against the access rules in the store. Calls
+// without a caller, when authentication is disabled, are all allowed.
+type authorizer struct {
+	storeDB store.HandlerData
+	admins  []string
+}
+
+func newAuthorizer(storeDB store.HandlerData, config types.AuthConfig) *authorizer {
+	return &authorizer{storeDB: storeDB, admins: config.Admins}
+}
+
+// rule returns the access rule of the caller of ctx, nil when authentication
+// is disabled.
+func (a *authorizer) rule(ctx context.Context) (*types.AccessRule, error) {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return nil, nil
+	}
+	subject := principal.String()
+	if slices.Contains(a.admins, subject) {
+		return &types.AccessRule{Subject: subject, Roles: []types.Role{types.RoleAdmin}}, nil
+	}
+	rule, err := a.storeDB.GetAccessRule(subject)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s has no access rule", ErrPermissionDenied, principal)
+	}
+	return rule, err
+}
+
+// checkSubject checks that the subject of an access rule is qualified by the
+// authentication method of the caller, e.g. "jwt:alice".
+func checkSubject(subject string) error {
+	if err := auth.CheckSubject(subject); err != nil {
+		return tsserr.New(tsserr.InvalidInput, "%v", err).With("subject", subject)
+	}
+	return nil
+}
+
+// authorize checks that the caller of ctx has permission, on the key hash
+// when it is not empty.
+func (a *authorizer) authorize(ctx context.Context, permission types.Permission, hash string) error {
+	rule, err := a.rule(ctx)
+	if err != nil || rule == nil {
+		return err
+	}
+	if !rule.Allows(permission, hash) {
+		log.Warn("Denied call", "subject", rule.Subject, "permission", permission, "key", hash)
+		if hash == "" {
+			return fmt.Errorf("%w: %s needs %s", ErrPermissionDenied, rule.Subject, permission)
+		}
+		return fmt.Errorf("%w: %s needs %s on key %s", ErrPermissionDenied, rule.Subject, permission, hash)
+	}
+	return nil
+}
+
+// keyFilter checks that the caller of ctx has permission, and returns which
+// keys it may see.
+func (a *authorizer) keyFilter(ctx context.Context, permission types.Permission) (func(hash string) bool, error) {
+	if err := a.authorize(ctx, permission, ""); err != nil {
+		return nil, err
+	}
+	rule, err := a.rule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return func(string) bool { return true }, nil
+	}
+	return rule.CanUse, nil
+}
+
+// authorizeJob checks that the caller of ctx may see and cancel job: admins
+// may see every job, other callers the jobs they started.
+func (a *authorizer) authorizeJob(ctx context.Context, job *types.Job) error {
+	rule, err := a.rule(ctx)
+	if err != nil || rule == nil {
+		return err
+	}
+	if !rule.IsAdmin() && rule.Subject != job.Owner {
+		log.Warn("Denied job access", "subject", rule.Subject, "job", job.ID)
+		return fmt.Errorf("%w: job %s belongs to another caller", ErrPermissionDenied, job.ID)
+	}
+	return nil
+}
+
+// Handler only passes requests from callers with permission to next.
+func (a *authorizer) Handler(permission types.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.authorize(r.Context(), permission, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+)
+
+// backupRunner writes online store backups into a directory, one at a time.
+// Backups run in the background, so a large store is not cut short by the RPC
+// timeout; their outcome is read back with Status.
+type backupRunner struct {
+	storeDB store.HandlerData
+	dir     string
+
+	mu   sync.Mutex
+	last types.BackupStatus
+	// done is closed when the last backup is over.
+	done chan struct{}
+}
+
+func newBackupRunner(storeDB store.HandlerData, dir string) *backupRunner {
+	return &backupRunner{storeDB: storeDB, dir: dir}
+}
+
+// Start begins a backup to name inside the backup directory and returns its
+// initial status.
+func (b *backupRunner) Start(name string) (types.BackupStatus, error) {
+	if b.dir == "" {
+		return types.BackupStatus{}, errors.New("backups are disabled, set store.backupDir")
+	}
+	if name == "" {
+		name = fmt.Sprintf("backup-%s.bak", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return types.BackupStatus{}, tsserr.New(tsserr.InvalidInput, "invalid backup name %q", name).With("name", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.Running {
+		return b.last, fmt.Errorf("backup to %s is still running", b.last.Path)
+	}
+	path := filepath.Join(b.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return types.BackupStatus{}, fmt.Errorf("backup %s already exists", path)
+	}
+	b.last = types.BackupStatus{Path: path, Running: true, StartedAt: time.Now().Unix()}
+	b.done = make(chan struct{})
+	go b.run(path, b.done)
+	return b.last, nil
+}
+
+// Status returns the status of the last backup.
+func (b *backupRunner) Status() types.BackupStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// Wait waits for the running backup, if any, until ctx is done. Backups
+// cannot be cancelled: one still running when the store closes fails, and
+// leaves no file.
+func (b *backupRunner) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	done := b.done
+	b.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("backup still running: %w", ctx.Err())
+	}
+}
+
+func (b *backupRunner) run(path string, done chan struct{}) {
+	log.Info("Backup started", "path", path)
+	written, err := b.write(path)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer close(done)
+	b.last.Running = false
+	b.last.Bytes = written
+	b.last.FinishedAt = time.Now().Unix()
+	if err != nil {
+		log.Error("Backup failed", "path", path, "err", err)
+		b.last.Error = err.Error()
+		return
+	}
+	log.Info("Backup done", "path", path, "bytes", written)
+}
+
+// write streams the backup into a temporary file and renames it into place
+// once it is complete and synced, so path never holds a partial backup.
+func (b *backupRunner) write(path string) (int64, error) {
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(b.dir, ".backup-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	counter := &countingWriter{w: tmp}
+	err = store.Backup(b.storeDB, counter)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return counter.n, err
+	}
+	return counter.n, os.Rename(tmp.Name(), path)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
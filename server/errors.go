@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+
+	"alice-tss/tsserr"
+
+	rpcjson "github.com/gorilla/rpc/v2/json2"
+	"google.golang.org/grpc"
+)
+
+// rpcError reports the typed errors of the JSON-RPC services with their
+// JSON-RPC code and data. Other errors keep the generic server error code.
+func rpcError(err error) error {
+	typed := tsserr.From(err)
+	if typed == nil {
+		return err
+	}
+	return &rpcjson.Error{
+		Code:    rpcjson.ErrorCode(typed.Code.RPCCode()),
+		Message: typed.Message,
+		Data:    typed.Data(),
+	}
+}
+
+// grpcError reports the typed errors of the gRPC services with their status.
+// Other errors are left as they are.
+func grpcError(err error) error {
+	if typed := tsserr.From(err); typed != nil {
+		return typed
+	}
+	return err
+}
+
+// unaryErrorInterceptor reports the typed errors of unary gRPC calls with
+// their status.
+func unaryErrorInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	reply, err := handler(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return reply, nil
+}
+
+// streamErrorInterceptor reports the typed errors of streaming gRPC calls
+// with their status.
+func streamErrorInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return grpcError(handler(srv, ss))
+}
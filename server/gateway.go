@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"alice-tss/pb"
+	"alice-tss/tsserr"
+
+	"github.com/getamis/sirius/log"
+	"github.com/gorilla/mux"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gatewayPrefix is where the REST gateway serves the HTTP annotations of
+// TssService.
+const gatewayPrefix = "/v1/"
+
+// pathVariable matches the variables of the path template of an HTTP rule.
+var pathVariable = regexp.MustCompile(`{([a-z_]+)}`)
+
+// methodHandler is the handler of a unary method in a grpc.ServiceDesc.
+type methodHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+// gatewayRoute is a method of a gRPC service, as its HTTP rule maps it.
+type gatewayRoute struct {
+	method     protoreflect.MethodDescriptor
+	handler    methodHandler
+	httpMethod string
+	path       string
+	// body is the field of the request read from the body, "*" for all the
+	// fields that are not in the path, and empty when there is no body.
+	body string
+}
+
+// pathParams returns the request fields set from the path.
+func (route *gatewayRoute) pathParams() []string {
+	var params []string
+	for _, match := range pathVariable.FindAllStringSubmatch(route.path, -1) {
+		params = append(params, match[1])
+	}
+	return params
+}
+
+// gatewayRoutes returns the routes of the unary methods of desc that have an
+// HTTP rule, in the order of the service.
+func gatewayRoutes(desc *grpc.ServiceDesc, service protoreflect.ServiceDescriptor) ([]*gatewayRoute, error) {
+	handlers := make(map[string]methodHandler, len(desc.Methods))
+	for _, method := range desc.Methods {
+		handlers[method.MethodName] = method.Handler
+	}
+
+	var routes []*gatewayRoute
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		rule, ok := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		handler, ok := handlers[string(method.Name())]
+		if !ok {
+			return nil, fmt.Errorf("%s has an HTTP rule but is not unary", method.FullName())
+		}
+		route := &gatewayRoute{method: method, handler: handler, body: rule.Body}
+		switch pattern := rule.Pattern.(type) {
+		case *annotations.HttpRule_Get:
+			route.httpMethod, route.path = http.MethodGet, pattern.Get
+		case *annotations.HttpRule_Put:
+			route.httpMethod, route.path = http.MethodPut, pattern.Put
+		case *annotations.HttpRule_Post:
+			route.httpMethod, route.path = http.MethodPost, pattern.Post
+		case *annotations.HttpRule_Delete:
+			route.httpMethod, route.path = http.MethodDelete, pattern.Delete
+		case *annotations.HttpRule_Patch:
+			route.httpMethod, route.path = http.MethodPatch, pattern.Patch
+		default:
+			return nil, fmt.Errorf("%s: unsupported HTTP rule %v", method.FullName(), rule)
+		}
+		for _, param := range route.pathParams() {
+			if method.Input().Fields().ByName(protoreflect.Name(param)) == nil {
+				return nil, fmt.Errorf("%s: %s is not a field of %s", method.FullName(), param, method.Input().FullName())
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// tssGatewayRoutes returns the routes of the HTTP annotations of TssService.
+func tssGatewayRoutes() ([]*gatewayRoute, error) {
+	return gatewayRoutes(&pb.TssService_ServiceDesc, pb.File_tss_proto.Services().ByName("TssService"))
+}
+
+// gatewayHandler serves a route by calling its method on srv, the gRPC
+// service implementation, with the request decoded from the HTTP request.
+// The caller is the one authenticated in the context of the HTTP request, and
+// the call goes through interceptor, like over gRPC.
+func gatewayHandler(srv interface{}, route *gatewayRoute, interceptor grpc.UnaryServerInterceptor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decode := func(in interface{}) error {
+			return decodeGatewayRequest(r, route, in.(proto.Message))
+		}
+		reply, err := route.handler(srv, r.Context(), decode, interceptor)
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+		data, err := (protojson.MarshalOptions{EmitUnpopulated: true}).Marshal(reply.(proto.Message))
+		if err != nil {
+			log.Error("Failed to marshal gateway reply", "method", route.method.FullName(), "err", err)
+			writeGatewayError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// decodeGatewayRequest sets the fields of in, the request of route, from the
+// body, the path variables and the query of r.
+func decodeGatewayRequest(r *http.Request, route *gatewayRoute, in proto.Message) error {
+	msg := in.ProtoReflect()
+	if route.body != "" {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			target := in
+			if route.body != "*" {
+				field := msg.Descriptor().Fields().ByName(protoreflect.Name(route.body))
+				if field == nil || field.Message() == nil {
+					return status.Errorf(codes.Internal, "bad body field %q", route.body)
+				}
+				target = msg.Mutable(field).Message().Interface()
+			}
+			if err := protojson.Unmarshal(body, target); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+	}
+	for name, value := range mux.Vars(r) {
+		if err := setGatewayField(msg, name, value); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if route.body == "*" {
+		return nil
+	}
+	for name, values := range r.URL.Query() {
+		for _, value := range values {
+			if err := setGatewayField(msg, name, value); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// setGatewayField sets the scalar field name of msg, by its proto or JSON
+// name, from value. Values are appended to repeated fields.
+func setGatewayField(msg protoreflect.Message, name string, value string) error {
+	fields := msg.Descriptor().Fields()
+	field := fields.ByName(protoreflect.Name(name))
+	if field == nil {
+		field = fields.ByJSONName(name)
+	}
+	if field == nil || field.IsMap() || field.Message() != nil {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+
+	var v protoreflect.Value
+	var err error
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(value)
+	case protoreflect.BytesKind:
+		v = protoreflect.ValueOfBytes([]byte(value))
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 64)
+		v = protoreflect.ValueOfUint64(n)
+	default:
+		return fmt.Errorf("parameter %q has unsupported type %s", name, field.Kind())
+	}
+	if err != nil {
+		return fmt.Errorf("parameter %q: %w", name, err)
+	}
+	if field.IsList() {
+		msg.Mutable(field).List().Append(v)
+	} else {
+		msg.Set(field, v)
+	}
+	return nil
+}
+
+// gatewayError is the JSON body of the errors of the REST gateway. Typed
+// errors also have their type and details.
+type gatewayError struct {
+	Code    codes.Code        `json:"code"`
+	Message string            `json:"message"`
+	Type    tsserr.Code       `json:"type,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// writeGatewayError writes err with the HTTP status of its gRPC code.
+// Errors without a code are internal.
+func writeGatewayError(w http.ResponseWriter, err error) {
+	err = grpcError(err)
+	st, _ := status.FromError(err)
+	reply := gatewayError{Code: st.Code(), Message: st.Message()}
+	if typed, ok := err.(*tsserr.Error); ok {
+		reply.Type, reply.Details = typed.Code, typed.Details
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFromCode(st.Code()))
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// httpStatusFromCode maps gRPC codes to HTTP statuses, like the gRPC HTTP
+// mapping of google.rpc.Code does.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// registerGateway serves the routes on r with handlers wrapped by wrap, which
+// call srv through interceptor. The OpenAPI document of the routes is served
+// on gatewayPrefix + "openapi.json".
+func registerGateway(r *mux.Router, srv interface{}, routes []*gatewayRoute, interceptor grpc.UnaryServerInterceptor, wrap func(http.Handler) http.Handler) error {
+	document, err := json.MarshalIndent(openAPIDocument(routes), "", "  ")
+	if err != nil {
+		return fmt.Errorf("openapi document: %w", err)
+	}
+	r.HandleFunc(gatewayPrefix+"openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	}).Methods(http.MethodGet)
+	for _, route := range routes {
+		r.Handle(route.path, wrap(gatewayHandler(srv, route, interceptor))).Methods(route.httpMethod)
+	}
+	return nil
+}
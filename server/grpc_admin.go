@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+
+	"alice-tss/pb"
+	"alice-tss/peer"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcAdminServer implements pb.AdminServiceServer, the gRPC counterpart of
+// AdminService.
+type grpcAdminServer struct {
+	pb.UnimplementedAdminServiceServer
+
+	pm           *peer.P2PManager
+	tssCaller    *TssCaller
+	backups      *backupRunner
+	exposeShares bool
+	authz        *authorizer
+}
+
+func (s *grpcAdminServer) authorize(ctx context.Context) error {
+	return grpcDenied(s.authz.authorize(ctx, types.PermissionAdmin, ""))
+}
+
+func (s *grpcAdminServer) GetSignerConfig(ctx context.Context, signRequest *pb.SignRequest) (*pb.SignerConfig, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if !s.exposeShares {
+		log.Warn("Refused to expose a share", "reason", "admin.exposeShares is not set")
+		return nil, status.Error(codes.FailedPrecondition, ErrSharesNotExposed.Error())
+	}
+
+	config, err := s.tssCaller.GetSignerConfig(signRequest)
+	if err != nil {
+		return nil, err
+	}
+	log.Warn("Exposed a share over admin gRPC", "hash", signRequest.Hash)
+	return &pb.SignerConfig{
+		Share:  config.Share,
+		Pubkey: &pb.Point{X: config.Pubkey.X, Y: config.Pubkey.Y},
+		Bks:    bksReply(config.BKs),
+		Epoch:  config.Epoch,
+		State:  string(config.State),
+	}, nil
+}
+
+func (s *grpcAdminServer) RollbackEpoch(ctx context.Context, rollbackRequest *pb.RollbackRequest) (*pb.ServiceReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.tssCaller.RollbackEpoch(ctx, s.pm, rollbackRequest); err != nil {
+		log.Error("RollbackEpoch", "hash", rollbackRequest.Hash, "err", err)
+		return nil, err
+	}
+	return &pb.ServiceReply{}, nil
+}
+
+func (s *grpcAdminServer) SetKeyState(ctx context.Context, keyStateRequest *pb.KeyStateRequest) (*pb.ServiceReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.tssCaller.SetKeyState(ctx, s.pm, keyStateRequest); err != nil {
+		log.Error("SetKeyState", "hash", keyStateRequest.Hash, "err", err)
+		return nil, err
+	}
+	return &pb.ServiceReply{}, nil
+}
+
+func (s *grpcAdminServer) Backup(ctx context.Context, backupRequest *pb.BackupRequest) (*pb.BackupStatusReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	backupStatus, err := s.backups.Start(backupRequest.Name)
+	if err != nil {
+		log.Error("Backup", "err", err)
+		return nil, err
+	}
+	return backupStatusReply(backupStatus), nil
+}
+
+func (s *grpcAdminServer) BackupStatus(ctx context.Context, _ *pb.BackupStatusRequest) (*pb.BackupStatusReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return backupStatusReply(s.backups.Status()), nil
+}
+
+func (s *grpcAdminServer) SetAccessRule(ctx context.Context, ruleRequest *pb.AccessRule) (*pb.AccessRule, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	rule := types.AccessRule{Subject: ruleRequest.Subject, Keys: ruleRequest.Keys}
+	for _, role := range ruleRequest.Roles {
+		rule.Roles = append(rule.Roles, types.Role(role))
+	}
+	if err := checkSubject(rule.Subject); err != nil {
+		return nil, err
+	}
+	if err := s.tssCaller.StoreDB.SetAccessRule(&rule); err != nil {
+		log.Error("SetAccessRule", "subject", rule.Subject, "err", err)
+		return nil, err
+	}
+	return accessRuleReply(&rule), nil
+}
+
+func (s *grpcAdminServer) GetAccessRule(ctx context.Context, subjectRequest *pb.SubjectRequest) (*pb.AccessRule, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	rule, err := s.tssCaller.StoreDB.GetAccessRule(subjectRequest.Subject)
+	if err != nil {
+		log.Error("GetAccessRule", "subject", subjectRequest.Subject, "err", err)
+		return nil, err
+	}
+	return accessRuleReply(rule), nil
+}
+
+func (s *grpcAdminServer) DeleteAccessRule(ctx context.Context, subjectRequest *pb.SubjectRequest) (*pb.ServiceReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.tssCaller.StoreDB.DeleteAccessRule(subjectRequest.Subject); err != nil {
+		log.Error("DeleteAccessRule", "subject", subjectRequest.Subject, "err", err)
+		return nil, err
+	}
+	return &pb.ServiceReply{}, nil
+}
+
+func (s *grpcAdminServer) ListAccessRules(ctx context.Context, _ *pb.ListAccessRulesRequest) (*pb.ListAccessRulesReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	rules, err := s.tssCaller.StoreDB.ListAccessRules()
+	if err != nil {
+		log.Error("ListAccessRules", "err", err)
+		return nil, err
+	}
+	reply := &pb.ListAccessRulesReply{}
+	for i := range rules {
+		reply.Rules = append(reply.Rules, accessRuleReply(&rules[i]))
+	}
+	return reply, nil
+}
+
+func (s *grpcAdminServer) ListWebhookDeliveries(ctx context.Context, listRequest *pb.ListWebhookDeliveriesRequest) (*pb.ListWebhookDeliveriesReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.tssCaller.StoreDB.ListWebhookDeliveries(types.WebhookStatus(listRequest.Status))
+	if err != nil {
+		log.Error("ListWebhookDeliveries", "err", err)
+		return nil, err
+	}
+	reply := &pb.ListWebhookDeliveriesReply{}
+	for _, delivery := range deliveries {
+		reply.Deliveries = append(reply.Deliveries, &pb.WebhookDelivery{
+			Id:            delivery.ID,
+			JobId:         delivery.JobID,
+			Url:           delivery.URL,
+			Client:        delivery.Client,
+			Payload:       string(delivery.Payload),
+			Status:        string(delivery.Status),
+			Attempts:      int32(delivery.Attempts),
+			NextAttemptAt: delivery.NextAttemptAt,
+			LastError:     delivery.LastError,
+			CreatedAt:     delivery.CreatedAt,
+			UpdatedAt:     delivery.UpdatedAt,
+			DeliveredAt:   delivery.DeliveredAt,
+		})
+	}
+	return reply, nil
+}
+
+func backupStatusReply(backupStatus types.BackupStatus) *pb.BackupStatusReply {
+	return &pb.BackupStatusReply{
+		Path:       backupStatus.Path,
+		Running:    backupStatus.Running,
+		Bytes:      backupStatus.Bytes,
+		StartedAt:  backupStatus.StartedAt,
+		FinishedAt: backupStatus.FinishedAt,
+		Error:      backupStatus.Error,
+	}
+}
+
+func accessRuleReply(rule *types.AccessRule) *pb.AccessRule {
+	reply := &pb.AccessRule{Subject: rule.Subject, Keys: rule.Keys, UpdatedAt: rule.UpdatedAt}
+	for _, role := range rule.Roles {
+		reply.Roles = append(reply.Roles, string(role))
+	}
+	return reply
+}
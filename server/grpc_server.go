@@ -4,98 +4,527 @@ import (
 	"alice-tss/pb"
 	"alice-tss/peer"
 	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
 	"alice-tss/utils"
 	"context"
-	"encoding/hex"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/getamis/sirius/log"
-	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
-	"net"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
 )
 
-// grpcServer is used to implement proto.GreeterServer.
+// grpcServer implements pb.TssServiceServer, the gRPC counterpart of the
+// JSON-RPC signer service.
 type grpcServer struct {
-	pb.TssServiceServer
+	pb.UnimplementedTssServiceServer
 
-	pm        *peer.P2PManager
-	badgerFsm *store.FSM
-	tssCaller *TssCaller
+	selfService *SelfService
+	tssCaller   *TssCaller
+	authz       *authorizer
+	jobs        *jobRunner
+	ops         *operations
 }
 
-func (s *grpcServer) SignMessage(_ context.Context, signRequest *pb.SignRequest) (*pb.RVSignatureReply, error) {
-	hash := utils.ToHexHash([]byte(signRequest.Message))
-	pm := s.pm.ClonePeerManager(peer.GetProtocol(hash))
+// authorize checks the caller of ctx like RpcService does, reporting denials
+// as PERMISSION_DENIED.
+func (s *grpcServer) authorize(ctx context.Context, permission types.Permission, hash string) error {
+	return grpcDenied(s.authz.authorize(ctx, permission, hash))
+}
 
-	bs, err := proto.Marshal(signRequest)
+// grpcDenied reports authorization denials as PERMISSION_DENIED.
+func grpcDenied(err error) error {
+	if errors.Is(err, ErrPermissionDenied) {
+		return tsserr.From(err)
+	}
+	return err
+}
+
+func (s *grpcServer) GetSignerConfig(ctx context.Context, signRequest *pb.SignRequest) (*pb.KeyView, error) {
+	if err := s.authorize(ctx, types.PermissionRead, signRequest.Hash); err != nil {
+		return nil, err
+	}
+	view, err := s.tssCaller.GetKeyView(signRequest.Hash, signRequest.Pubkey)
 	if err != nil {
-		log.Warn("Cannot proto marshal message", "err", err)
+		log.Error("GetSignerConfig", "err", err)
 		return nil, err
 	}
+	return keyViewReply(view), nil
+}
 
-	result, err := s.tssCaller.SignMessage(pm, signRequest, RpcToPeer(pm, "TssPeerService", "SignMessage", bs))
+// SignMessage starts signing a message, and returns its job. The signature
+// is the result of the job.
+func (s *grpcServer) SignMessage(ctx context.Context, signRequest *pb.SignRequest) (*pb.Job, error) {
+	if err := s.authorize(ctx, types.PermissionSign, signRequest.Hash); err != nil {
+		return nil, err
+	}
+	job, err := s.ops.startSign(ctx, signRequestData(signRequest))
+	if err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
 
-	if err == nil {
-		signature := &pb.RVSignatureReply{
-			R:    hex.EncodeToString(result.R.Bytes()),
-			S:    hex.EncodeToString(result.S.Bytes()),
-			Hash: hash,
-		}
-		return signature, nil
+// RegisterDKG starts generating a key across the connected peers, and returns
+// its job.
+func (s *grpcServer) RegisterDKG(ctx context.Context, dkgRequest *pb.DKGRequest) (*pb.Job, error) {
+	if err := s.authorize(ctx, types.PermissionAdmin, ""); err != nil {
+		return nil, err
+	}
+	job, err := s.ops.startDKG(ctx, dkgRequestData(dkgRequest))
+	if err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
+
+func (s *grpcServer) SelfSignMessage(ctx context.Context, signRequest *pb.SignRequest) (*pb.Job, error) {
+	if s.selfService == nil {
+		return nil, status.Error(codes.Unavailable, "self service is not available")
+	}
+	if err := s.authorize(ctx, types.PermissionSign, signRequest.Hash); err != nil {
+		return nil, err
+	}
+	job, err := s.ops.startSelfSign(ctx, signRequestData(signRequest))
+	if err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
+
+func (s *grpcServer) RegisterSelfDKG(ctx context.Context, dkgRequest *pb.DKGRequest) (*pb.Job, error) {
+	if err := s.authorize(ctx, types.PermissionAdmin, ""); err != nil {
+		return nil, err
+	}
+	if s.selfService == nil {
+		return nil, status.Error(codes.Unavailable, "self service is not available")
+	}
+	job, err := s.ops.startSelfDKG(ctx, dkgRequestData(dkgRequest))
+	if err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
+
+// Reshare starts resharing a key, and returns its job. The result of the job
+// is the key after resharing.
+func (s *grpcServer) Reshare(ctx context.Context, reshareRequest *pb.ReshareRequest) (*pb.Job, error) {
+	if err := s.authorize(ctx, types.PermissionAdmin, reshareRequest.Hash); err != nil {
+		return nil, err
 	}
+	job, err := s.ops.startReshare(ctx, reshareRequestData(reshareRequest))
+	if err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
+
+// signRequestData returns the JSON-RPC data of a sign request, so that jobs
+// started over gRPC and JSON-RPC have the same fingerprint and idempotency. The initiator and
+// session of the request are set by this node.
+func signRequestData(signRequest *pb.SignRequest) types.SignRequest {
+	return types.SignRequest{
+		Hash:    signRequest.Hash,
+		Pubkey:  signRequest.Pubkey,
+		Message: signRequest.Message,
+		Epoch:   signRequest.Epoch,
+		JobOptions: types.JobOptions{
+			IdempotencyKey: signRequest.IdempotencyKey,
+		},
+	}
+}
 
-	return nil, err
+func dkgRequestData(dkgRequest *pb.DKGRequest) types.DKGRequest {
+	return types.DKGRequest{JobOptions: types.JobOptions{IdempotencyKey: dkgRequest.IdempotencyKey}}
 }
 
-func (s *grpcServer) RegisterDKG(_ context.Context, _ *pb.DKGRequest) (*pb.DkgReply, error) {
-	hash := utils.RandomHash()
-	pm := s.pm.ClonePeerManager(peer.GetProtocol(hash))
+func reshareRequestData(reshareRequest *pb.ReshareRequest) types.ReshareRequest {
+	return types.ReshareRequest{
+		Hash:   reshareRequest.Hash,
+		Pubkey: reshareRequest.Pubkey,
+		Epoch:  reshareRequest.Epoch,
+		JobOptions: types.JobOptions{
+			IdempotencyKey: reshareRequest.IdempotencyKey,
+		},
+	}
+}
 
-	result, err := s.tssCaller.RegisterDKG(pm, hash, RpcToPeer(pm, "TssPeerService", "RegisterDKG", []byte(hash)))
-	log.Info("RegisterDKG", "result", result, "err", err)
-	if err == nil {
-		pubkey := crypto.CompressPubkey(result.PublicKey.ToPubKey())
-		dkgReply := &pb.DkgReply{
-			X:       hex.EncodeToString(result.PublicKey.GetX().Bytes()),
-			Y:       hex.EncodeToString(result.PublicKey.GetY().Bytes()),
-			Address: crypto.PubkeyToAddress(*result.PublicKey.ToPubKey()).String(),
-			Pubkey:  hex.EncodeToString(pubkey),
+// GetJob returns a job.
+func (s *grpcServer) GetJob(ctx context.Context, jobRequest *pb.JobRequest) (*pb.Job, error) {
+	job, err := s.jobs.Get(jobRequest.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := grpcDenied(s.authz.authorizeJob(ctx, job)); err != nil {
+		return nil, err
+	}
+	return jobReply(job), nil
+}
+
+// CancelJob stops the session of a running job on this node.
+func (s *grpcServer) CancelJob(ctx context.Context, jobRequest *pb.JobRequest) (*pb.Job, error) {
+	job, err := s.jobs.Get(jobRequest.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := grpcDenied(s.authz.authorizeJob(ctx, job)); err != nil {
+		return nil, err
+	}
+	if job, err = s.jobs.Cancel(jobRequest.Id); err != nil {
+		if errors.Is(err, ErrJobFinished) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
 		}
-		return dkgReply, nil
+		return nil, err
 	}
+	return jobReply(job), nil
+}
 
-	return nil, err
+func (s *grpcServer) GetDKG(ctx context.Context, keyRequest *pb.KeyRequest) (*pb.KeyView, error) {
+	if err := s.authorize(ctx, types.PermissionRead, keyRequest.Hash); err != nil {
+		return nil, err
+	}
+	data, err := s.tssCaller.StoreDB.GetDKGResultData(keyRequest.Hash)
+	if err != nil {
+		log.Error("GetDKG", "hash", keyRequest.Hash, "err", err)
+		return nil, err
+	}
+	return keyViewReply(data.View(keyRequest.Hash)), nil
 }
 
-func (s *grpcServer) Reshare(_ context.Context, reshareRequest *pb.ReshareRequest) (*pb.ServiceReply, error) {
-	bs, err := proto.Marshal(reshareRequest)
+func (s *grpcServer) ListKeys(ctx context.Context, listRequest *pb.ListKeysRequest) (*pb.ListKeysReply, error) {
+	if err := s.authorize(ctx, types.PermissionRead, ""); err != nil {
+		return nil, err
+	}
+	canUse, err := s.authz.keyFilter(ctx, types.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.tssCaller.StoreDB.ListKeys(types.KeyFilter{
+		Address:       listRequest.Address,
+		Pubkey:        listRequest.Pubkey,
+		CreatedAfter:  listRequest.CreatedAfter,
+		CreatedBefore: listRequest.CreatedBefore,
+		State:         types.KeyState(listRequest.State),
+		Cursor:        listRequest.Cursor,
+		Limit:         int(listRequest.Limit),
+	})
 	if err != nil {
-		log.Warn("Cannot proto marshal message", "err", err)
+		log.Error("ListKeys", "err", err)
 		return nil, err
 	}
-	pm := s.pm.ClonePeerManager(peer.GetProtocol(reshareRequest.Hash))
 
-	if err := s.tssCaller.Reshare(pm, reshareRequest, RpcToPeer(pm, "TssPeerService", "Reshare", bs)); err != nil {
+	reply := &pb.ListKeysReply{NextCursor: page.NextCursor}
+	for _, key := range page.Keys {
+		if !canUse(key.Hash) {
+			continue
+		}
+		reply.Keys = append(reply.Keys, &pb.KeySummary{
+			Hash:      key.Hash,
+			PublicKey: key.PublicKey,
+			Address:   key.Address.String(),
+			CreatedAt: key.CreatedAt,
+			State:     string(key.State),
+		})
+	}
+	return reply, nil
+}
+
+func (s *grpcServer) QueryLedger(ctx context.Context, query *pb.LedgerQuery) (*pb.LedgerReply, error) {
+	canUse, err := s.authz.keyFilter(ctx, types.PermissionRead)
+	if err != nil {
+		return nil, grpcDenied(err)
+	}
+	page, err := store.QueryLedger(s.tssCaller.StoreDB, types.LedgerQuery{
+		KeyHash: query.KeyHash,
+		Digest:  query.Digest,
+		From:    query.From,
+		To:      query.To,
+		Cursor:  query.Cursor,
+		Limit:   int(query.Limit),
+	})
+	if err != nil {
+		log.Error("QueryLedger", "err", err)
+		return nil, err
+	}
+
+	reply := &pb.LedgerReply{NextCursor: page.NextCursor}
+	for _, entry := range page.Entries {
+		if !canUse(entry.KeyHash) {
+			continue
+		}
+		reply.Entries = append(reply.Entries, &pb.LedgerEntry{
+			Id:           entry.ID,
+			KeyHash:      entry.KeyHash,
+			Pubkey:       entry.Pubkey,
+			Digest:       entry.Digest,
+			R:            entry.R,
+			S:            entry.S,
+			Participants: entry.Participants,
+			Initiator:    entry.Initiator,
+			StartedAt:    entry.StartedAt,
+			FinishedAt:   entry.FinishedAt,
+			Outcome:      entry.Outcome,
+			Error:        entry.Error,
+		})
+	}
+	return reply, nil
+}
+
+func (s *grpcServer) CheckSignature(ctx context.Context, checkRequest *pb.CheckSignatureByPubkeyRequest) (*pb.CheckSignatureReply, error) {
+	if err := s.authorize(ctx, types.PermissionRead, ""); err != nil {
+		return nil, err
+	}
+	rvSignature, err := s.tssCaller.GetSignature(checkRequest.Message, checkRequest.Pubkey, checkRequest.SessionId)
+	if err != nil {
+		log.Error("CheckSignature", "session", checkRequest.SessionId, "err", err)
+		return nil, err
+	}
+	if err := s.authorize(ctx, types.PermissionRead, signatureKey(rvSignature)); err != nil {
+		return nil, err
+	}
+	checked, err := utils.CheckSignatureECDSA(checkRequest.Message, *rvSignature, checkRequest.Pubkey)
+	if err != nil {
+		log.Error("CheckSignature", "err", err)
 		return nil, err
 	}
+	return &pb.CheckSignatureReply{
+		IsValid:     checked.IsValid,
+		Message:     checked.Message,
+		HashMessage: checked.HashMessage,
+		Address:     checked.Address,
+	}, nil
+}
+
+func (s *grpcServer) SignMessageStream(signRequest *pb.SignRequest, stream pb.TssService_SignMessageStreamServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, types.PermissionSign, signRequest.Hash); err != nil {
+		return err
+	}
+	job, err := s.ops.startSign(ctx, signRequestData(signRequest))
+	if err != nil {
+		return err
+	}
+	return s.streamJob(ctx, stream.Send, job.ID)
+}
 
-	return &pb.ServiceReply{}, nil
+func (s *grpcServer) RegisterDKGStream(dkgRequest *pb.DKGRequest, stream pb.TssService_RegisterDKGStreamServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, types.PermissionAdmin, ""); err != nil {
+		return err
+	}
+	job, err := s.ops.startDKG(ctx, dkgRequestData(dkgRequest))
+	if err != nil {
+		return err
+	}
+	return s.streamJob(ctx, stream.Send, job.ID)
 }
 
-func StartGRPC(port int, pm *peer.P2PManager, storeDB store.HandlerData) {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+func (s *grpcServer) ReshareStream(reshareRequest *pb.ReshareRequest, stream pb.TssService_ReshareStreamServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx, types.PermissionAdmin, reshareRequest.Hash); err != nil {
+		return err
+	}
+	job, err := s.ops.startReshare(ctx, reshareRequestData(reshareRequest))
 	if err != nil {
-		log.Crit("failed to listen: %v", err)
+		return err
+	}
+	return s.streamJob(ctx, stream.Send, job.ID)
+}
+
+// streamJob sends the progress of the job id to send, as the WebSocket of the
+// job does: the job first, then the steps of its session, and the result
+// last. A job that did not succeed ends the stream with its error after the
+// result. The job keeps running when the client goes away.
+func (s *grpcServer) streamJob(ctx context.Context, send func(*pb.ProgressEvent) error, id string) error {
+	events, job, err := s.jobs.Watch(id)
+	if err != nil {
+		log.Error("Cannot watch job", "job", id, "err", err)
+		return err
+	}
+	defer s.jobs.Unwatch(id, events)
+	if events == nil {
+		return sendJobResult(send, job)
+	}
+
+	first := newProgressEvent(types.ProgressJob, id)
+	first.Job = job
+	if err := send(progressReply(first)); err != nil {
+		return err
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return status.Errorf(codes.ResourceExhausted, "progress of job %s fell behind", id)
+			}
+			if event.Type == types.ProgressResult {
+				return sendJobResult(send, event.Job)
+			}
+			if err := send(progressReply(event)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	s := grpc.NewServer()
-	pb.RegisterTssServiceServer(s, &grpcServer{
-		pm:        pm,
-		tssCaller: &TssCaller{StoreDB: storeDB},
+}
+
+// sendJobResult sends the result event of a finished job, with the signature
+// or the key it made, then returns the error of the job.
+func sendJobResult(send func(*pb.ProgressEvent) error, job *types.Job) error {
+	event := progressReply(newProgressEvent(types.ProgressResult, job.ID))
+	event.Job = jobReply(job)
+	if job.Status == types.JobStatusDone {
+		switch job.Kind {
+		case types.JobKindSign:
+			var signature types.RVSignature
+			if err := json.Unmarshal(job.Result, &signature); err != nil {
+				return err
+			}
+			event.Signature = &pb.RVSignatureReply{R: signature.R, S: signature.S, Hash: signature.Hash, SessionId: signature.SessionID}
+		case types.JobKindDKG:
+			event.Key = &pb.DkgReply{}
+			if err := json.Unmarshal(job.Result, event.Key); err != nil {
+				return err
+			}
+		}
+	}
+	if err := send(event); err != nil {
+		return err
+	}
+	return jobError(job)
+}
+
+// jobError returns the error of a job that did not succeed, typed like the
+// error the session failed with, or nil.
+func jobError(job *types.Job) error {
+	switch {
+	case job.Status == types.JobStatusDone:
+		return nil
+	case job.Status == types.JobStatusCancelled:
+		return status.Errorf(codes.Canceled, "job %s cancelled: %s", job.ID, job.Error)
+	case job.ErrorType != "":
+		return tsserr.New(tsserr.Code(job.ErrorType), "job %s failed: %s", job.ID, job.Error).With("job", job.ID)
+	}
+	return fmt.Errorf("job %s failed: %s", job.ID, job.Error)
+}
+
+func progressReply(event types.ProgressEvent) *pb.ProgressEvent {
+	reply := &pb.ProgressEvent{
+		Type:     string(event.Type),
+		Time:     event.Time,
+		OldState: event.OldState,
+		State:    event.State,
+		Peer:     event.Peer,
+		Message:  event.Message,
+	}
+	if event.Job != nil {
+		reply.Job = jobReply(event.Job)
+	}
+	return reply
+}
+
+func keyViewReply(view *types.KeyView) *pb.KeyView {
+	return &pb.KeyView{
+		Hash:      view.Hash,
+		PublicKey: view.PublicKey,
+		Pubkey:    &pb.Point{X: view.Pubkey.X, Y: view.Pubkey.Y},
+		Address:   view.Address.String(),
+		Bks:       bksReply(view.BKs),
+		Epoch:     view.Epoch,
+		CreatedAt: view.CreatedAt,
+		State:     string(view.State),
+	}
+}
+
+func bksReply(bks map[string]types.BK) map[string]*pb.BK {
+	reply := make(map[string]*pb.BK, len(bks))
+	for id, bk := range bks {
+		reply[id] = &pb.BK{X: bk.X, Rank: bk.Rank}
+	}
+	return reply
+}
+
+func jobReply(job *types.Job) *pb.Job {
+	return &pb.Job{
+		Id:          job.ID,
+		Kind:        string(job.Kind),
+		Status:      string(job.Status),
+		State:       job.State,
+		KeyHash:     job.KeyHash,
+		Owner:       job.Owner,
+		CallbackUrl: job.CallbackURL,
+		Result:      string(job.Result),
+		Error:       job.Error,
+		ErrorType:   job.ErrorType,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		FinishedAt:  job.FinishedAt,
+	}
+}
+
+// NewGRPCServer returns the gRPC server of the node APIs, with the TLS and
+// authentication of config. It is not listening yet.
+func NewGRPCServer(config *types.AppConfig, pm *peer.P2PManager, storeDB store.HandlerData, selfService *SelfService) (*grpc.Server, error) {
+	tlsConfig, err := serverTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	api, err := newNodeAPI(config, pm, storeDB, selfService)
+	if err != nil {
+		return nil, err
+	}
+	return api.grpcServer(tlsConfig)
+}
+
+// tssServer returns the implementation of TssService, which the gRPC server
+// and the REST gateway serve.
+func (a *nodeAPI) tssServer() *grpcServer {
+	return &grpcServer{
+		selfService: a.selfService,
+		tssCaller:   a.tssCaller,
+		authz:       a.authz,
+		jobs:        a.jobs,
+		ops:         a.operations(),
+	}
+}
+
+// grpcServer returns a gRPC server of the TSS and admin services, which also
+// serves reflection from the descriptor set generated with pb. Unary calls
+// are bounded by the timeout of their method, and typed errors are reported
+// with their status.
+func (a *nodeAPI) grpcServer(tlsConfig *tls.Config) (*grpc.Server, error) {
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryErrorInterceptor, UnaryAuthInterceptor(a.authenticator), a.timeouts.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(streamErrorInterceptor, StreamAuthInterceptor(a.authenticator)),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(options...)
+	pb.RegisterTssServiceServer(s, a.tssServer())
+	pb.RegisterAdminServiceServer(s, &grpcAdminServer{
+		pm:           a.pm,
+		tssCaller:    a.tssCaller,
+		backups:      a.backups,
+		exposeShares: a.config.Admin.ExposeShares,
+		authz:        a.authz,
 	})
 
-	log.Info("server listening", "addr", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Crit("failed to serve: %v", err)
+	descriptors, err := pb.Files()
+	if err != nil {
+		return nil, fmt.Errorf("load descriptor set: %w", err)
 	}
+	reflectionpb.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{
+		Services:           s,
+		DescriptorResolver: descriptors,
+	}))
+	return s, nil
 }
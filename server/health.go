@@ -0,0 +1,236 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+)
+
+// activeSessions are the protocol sessions running on a node.
+type activeSessions struct {
+	mu       sync.Mutex
+	next     uint64
+	sessions map[uint64]types.SessionStatus
+}
+
+// add records session as running from now, until the returned func is
+// called.
+func (a *activeSessions) add(session types.SessionStatus) func() {
+	session.StartedAt = time.Now().Unix()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sessions == nil {
+		a.sessions = make(map[uint64]types.SessionStatus)
+	}
+	id := a.next
+	a.next++
+	a.sessions[id] = session
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.sessions, id)
+	}
+}
+
+func (a *activeSessions) list() []types.SessionStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sessions := make([]types.SessionStatus, 0, len(a.sessions))
+	for _, session := range a.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// keyHoldersTTL is how long readiness probes reuse the key holders read from
+// the store.
+const keyHoldersTTL = 30 * time.Second
+
+// holdersCache keeps the key holders read by the last readiness probe.
+type holdersCache struct {
+	mu      sync.Mutex
+	holders map[string][]string
+	readAt  time.Time
+}
+
+// cachedKeyHolders returns the key holders of the node, read from the store at
+// most once per keyHoldersTTL.
+func (a *nodeAPI) cachedKeyHolders() (map[string][]string, error) {
+	a.holders.mu.Lock()
+	defer a.holders.mu.Unlock()
+	if a.holders.holders != nil && time.Since(a.holders.readAt) < keyHoldersTTL {
+		return a.holders.holders, nil
+	}
+	holders, err := a.keyHolders()
+	if err != nil {
+		return nil, err
+	}
+	a.holders.holders, a.holders.readAt = holders, time.Now()
+	return holders, nil
+}
+
+// keyHolders returns the active keys of the store by the peers holding them,
+// this node included.
+func (a *nodeAPI) keyHolders() (map[string][]string, error) {
+	holders := map[string][]string{}
+	filter := types.KeyFilter{State: types.KeyStateActive}
+	for {
+		page, err := a.storeDB.ListKeys(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page.Keys {
+			record, err := a.storeDB.GetDKGResultData(key.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key.Hash, err)
+			}
+			for id := range record.BKs {
+				holders[id] = append(holders[id], key.Hash)
+			}
+		}
+		if page.NextCursor == "" {
+			return holders, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// localPeers returns the peer IDs of the hosts of this node.
+func (a *nodeAPI) localPeers() map[string]bool {
+	local := map[string]bool{}
+	if a.pm != nil {
+		local[a.pm.SelfID()] = true
+	}
+	if a.selfService != nil {
+		for _, id := range a.selfService.PeerIDs() {
+			local[id] = true
+		}
+	}
+	return local
+}
+
+// status returns the status of the node, and whether it is ready: the store
+// answers, the libp2p host listens, and the node is connected to every other
+// holder of its active keys, which signing needs. Probes read the key holders
+// from the cache, which may be up to keyHoldersTTL old.
+func (a *nodeAPI) status(probe bool) *types.NodeStatus {
+	status := &types.NodeStatus{
+		Peers:    []types.PeerStatus{},
+		Sessions: append(a.tssCaller.Sessions(), a.peerCaller.Sessions()...),
+		Jobs:     a.jobs.Running(),
+	}
+	check := func(name string, err error) {
+		c := types.HealthCheck{Name: name, OK: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		status.Checks = append(status.Checks, c)
+	}
+
+	readHolders := a.keyHolders
+	if probe {
+		readHolders = a.cachedKeyHolders
+	}
+	holders, err := readHolders()
+	check("store", err)
+
+	if a.pm == nil {
+		check("p2p", fmt.Errorf("no libp2p host"))
+	} else {
+		status.PeerID = a.pm.SelfID()
+		for _, addr := range a.pm.Host.Network().ListenAddresses() {
+			status.ListenAddrs = append(status.ListenAddrs, addr.String())
+		}
+		if len(status.ListenAddrs) == 0 {
+			check("p2p", fmt.Errorf("host %s is not listening", status.PeerID))
+		} else {
+			check("p2p", nil)
+		}
+	}
+
+	ids := map[string]bool{}
+	for id := range holders {
+		ids[id] = true
+	}
+	if a.pm != nil {
+		for id := range a.pm.Peers() {
+			ids[id] = true
+		}
+		for _, id := range a.pm.Host.Network().Peers() {
+			ids[id.String()] = true
+		}
+	}
+	local := a.localPeers()
+	var missing []string
+	for id := range ids {
+		if id == status.PeerID {
+			continue
+		}
+		peer := types.PeerStatus{ID: id, Addrs: []string{}, Local: local[id], Keys: holders[id]}
+		switch {
+		case peer.Local:
+			peer.Connected = true
+			peer.LastSeen = time.Now().Unix()
+		case a.pm != nil:
+			peer.Addrs = append(peer.Addrs, a.pm.Addrs(id)...)
+			peer.Connected = a.pm.Connected(id)
+			if lastSeen := a.pm.LastSeen(id); !lastSeen.IsZero() {
+				peer.LastSeen = lastSeen.Unix()
+			}
+		}
+		if !peer.Connected && len(peer.Keys) > 0 {
+			missing = append(missing, id)
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+	sort.Slice(status.Peers, func(i, j int) bool { return status.Peers[i].ID < status.Peers[j].ID })
+	sort.Slice(status.Sessions, func(i, j int) bool { return status.Sessions[i].StartedAt < status.Sessions[j].StartedAt })
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		check("peers", fmt.Errorf("not connected to key holders %s", strings.Join(missing, ", ")))
+	} else {
+		check("peers", nil)
+	}
+
+	status.Ready = true
+	for _, c := range status.Checks {
+		status.Ready = status.Ready && c.OK
+	}
+	return status
+}
+
+// healthHandler answers liveness probes: the node serves HTTP.
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyHandler answers readiness probes, with 503 when a check of the node
+// fails. Probes are not authenticated: the checks are only on /status.
+func (a *nodeAPI) readyHandler(w http.ResponseWriter, _ *http.Request) {
+	if !a.status(true).Ready {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// statusHandler returns the detailed status of the node.
+func (a *nodeAPI) statusHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, a.status(false))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to write reply", "err", err)
+	}
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"alice-tss/auth"
+	tssService "alice-tss/service"
+	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"alice-tss/utils"
+
+	aliceTypes "github.com/getamis/alice/types"
+	"github.com/getamis/sirius/log"
+)
+
+// ErrJobFinished is returned when cancelling a job that is already over.
+var ErrJobFinished = errors.New("job already finished")
+
+// errJobInterrupted is the error of the jobs that were running when the node
+// stopped: their sessions died with it.
+var errJobInterrupted = errors.New("interrupted by a node restart")
+
+// jobRunner runs protocol operations in the background, so that API calls
+// return a job ID at once instead of waiting for a session. Every change of a
+// job is written to the store, where GetJob reads it back, also after a
+// restart.
+type jobRunner struct {
+	storeDB  store.HandlerData
+	webhooks *webhookDispatcher
+	progress *progressHub
+	sessions sessionGroup
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+	// starting serializes the starts of jobs, so that two requests with the
+	// same idempotency key start one job.
+	starting sync.Mutex
+}
+
+// newJobRunner returns the job runner of storeDB, after failing the jobs that
+// a previous run of the node left unfinished. Finished jobs are posted to
+// webhooks, which may be nil.
+func newJobRunner(storeDB store.HandlerData, webhooks *webhookDispatcher) (*jobRunner, error) {
+	j := &jobRunner{
+		storeDB:  storeDB,
+		webhooks: webhooks,
+		progress: newProgressHub(),
+		running:  make(map[string]*runningJob),
+	}
+	if err := j.recover(); err != nil {
+		return nil, fmt.Errorf("recover jobs: %w", err)
+	}
+	return j, nil
+}
+
+func (j *jobRunner) recover() error {
+	for _, status := range []types.JobStatus{types.JobStatusPending, types.JobStatusRunning} {
+		jobs, err := j.storeDB.ListJobs(status)
+		if err != nil {
+			return err
+		}
+		for i := range jobs {
+			job := &jobs[i]
+			now := time.Now().Unix()
+			job.Status = types.JobStatusFailed
+			job.Error = errJobInterrupted.Error()
+			job.UpdatedAt = now
+			job.FinishedAt = now
+			if err := j.storeDB.SaveJob(job); err != nil {
+				return err
+			}
+			log.Warn("Job interrupted", "id", job.ID, "kind", job.Kind, "key", job.KeyHash)
+			j.webhooks.Enqueue(job)
+		}
+	}
+	return nil
+}
+
+// Start records a job of kind on the key hash, owned by the caller of ctx, and
+// runs it in the background. run gets a context that is cancelled by Cancel,
+// and whose sessions report their state to the job; its result is stored as
+// JSON. The finished job is posted to the callback URL of request, if any.
+// With an idempotency key the caller already used for the same request, Start
+// returns the job of that key instead of running another. No job starts once
+// Drain is called.
+func (j *jobRunner) Start(ctx context.Context, kind types.JobKind, hash string, request types.JobRequest, run func(ctx context.Context) (interface{}, error)) (*types.Job, error) {
+	if j.sessions.Stopping() {
+		return nil, ErrShuttingDown
+	}
+	opts := request.Options()
+	callbackURL := opts.CallbackURL
+	if callbackURL != "" {
+		if err := j.webhooks.CheckCallbackURL(callbackURL); err != nil {
+			log.Error("Invalid callback", "url", callbackURL, "err", err)
+			return nil, err
+		}
+	}
+	var owner string
+	if principal := auth.FromContext(ctx); principal != nil {
+		owner = principal.String()
+	}
+	id := utils.RandomHash()
+	var fingerprint string
+	if opts.IdempotencyKey != "" {
+		var err error
+		if fingerprint, err = requestFingerprint(kind, request); err != nil {
+			return nil, err
+		}
+		j.starting.Lock()
+		defer j.starting.Unlock()
+		id = idempotentJobID(owner, opts.IdempotencyKey)
+		existing, err := j.storeDB.GetJob(id)
+		if err == nil {
+			return sameJob(existing, fingerprint)
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Error("Cannot get job", "id", id, "err", err)
+			return nil, err
+		}
+	}
+
+	now := time.Now().Unix()
+	job := types.Job{
+		ID:          id,
+		Kind:        kind,
+		Status:      types.JobStatusPending,
+		KeyHash:     hash,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Owner:       owner,
+		Fingerprint: fingerprint,
+	}
+	if err := j.storeDB.SaveJob(&job); err != nil {
+		log.Error("Cannot save job", "id", job.ID, "err", err)
+		return nil, err
+	}
+
+	// The job outlives the API call that started it.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r := &runningJob{
+		storeDB:  j.storeDB,
+		progress: &progressObserver{id: job.ID, publish: j.progress.publish},
+		job:      job,
+		cancel:   cancel,
+	}
+	j.mu.Lock()
+	j.running[job.ID] = r
+	j.mu.Unlock()
+
+	log.Info("Job started", "id", job.ID, "kind", kind, "key", hash)
+	err := j.sessions.Go(runCtx, func(runCtx context.Context) {
+		r.update(func(job *types.Job) { job.Status = types.JobStatusRunning })
+		result, err := run(tssService.WithObserver(runCtx, r))
+		r.finish(runCtx, result, err)
+		j.done(r)
+	})
+	if err != nil {
+		// The node started stopping since the check above.
+		r.finish(runCtx, nil, err)
+		j.done(r)
+		return nil, err
+	}
+	return &job, nil
+}
+
+// idempotentJobID returns the ID of the job that owner starts with the
+// idempotency key. Keys are scoped to their owner.
+func idempotentJobID(owner, key string) string {
+	return utils.ToHexHash([]byte("job/" + owner + "/" + key))
+}
+
+// requestFingerprint returns the fingerprint of a request starting a job of
+// kind: the hash of its data without the job options, in canonical JSON.
+func requestFingerprint(kind types.JobKind, request types.JobRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	delete(fields, "callbackUrl")
+	delete(fields, "idempotencyKey")
+	// Maps are marshalled with sorted keys.
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return utils.ToHexHash(append([]byte(kind+"/"), canonical...)), nil
+}
+
+// sameJob returns job, the job of an idempotency key, to a retry of the
+// request of fingerprint, or an error if the key was used for another request.
+func sameJob(job *types.Job, fingerprint string) (*types.Job, error) {
+	if job.Fingerprint != fingerprint {
+		return nil, tsserr.New(tsserr.IdempotencyConflict, "idempotency key already used by %s job %s for another request", job.Kind, job.ID).
+			With("job", job.ID)
+	}
+	log.Info("Job already started", "id", job.ID, "kind", job.Kind, "key", job.KeyHash)
+	return job, nil
+}
+
+func (j *jobRunner) done(r *runningJob) {
+	finished := r.snapshot()
+	j.mu.Lock()
+	delete(j.running, finished.ID)
+	j.progress.finish(finished)
+	j.mu.Unlock()
+	j.webhooks.Enqueue(finished)
+}
+
+// Drain stops starting jobs, and waits for the running ones until ctx is
+// done. Those still running are then cancelled.
+func (j *jobRunner) Drain(ctx context.Context) error {
+	return j.sessions.Drain(ctx)
+}
+
+// Get returns the job id, or a JOB_NOT_FOUND error.
+func (j *jobRunner) Get(id string) (*types.Job, error) {
+	return j.storeDB.GetJob(id)
+}
+
+// Cancel stops the session of a running job on this node. The job becomes
+// cancelled once its session has stopped.
+func (j *jobRunner) Cancel(id string) (*types.Job, error) {
+	j.mu.Lock()
+	r, ok := j.running[id]
+	j.mu.Unlock()
+	if !ok {
+		job, err := j.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		return job, fmt.Errorf("%w: %s is %s", ErrJobFinished, id, job.Status)
+	}
+
+	log.Info("Cancelling job", "id", id)
+	r.cancel()
+	return r.snapshot(), nil
+}
+
+// Running returns the jobs running on this node.
+func (j *jobRunner) Running() []types.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	jobs := make([]types.Job, 0, len(j.running))
+	for _, r := range j.running {
+		jobs = append(jobs, *r.snapshot())
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].CreatedAt < jobs[b].CreatedAt })
+	return jobs
+}
+
+// Watch subscribes to the progress of the job id. It returns the events of the
+// job and the job as it is at subscription, or no events and the job when it
+// is over already. The events must be released with Unwatch.
+func (j *jobRunner) Watch(id string) (chan types.ProgressEvent, *types.Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if r, ok := j.running[id]; ok {
+		return j.progress.subscribe(id), r.snapshot(), nil
+	}
+	// Finished jobs leave running and publish their result under mu.
+	job, err := j.Get(id)
+	return nil, job, err
+}
+
+// Unwatch releases the events returned by Watch.
+func (j *jobRunner) Unwatch(id string, events chan types.ProgressEvent) {
+	if events != nil {
+		j.progress.unsubscribe(id, events)
+	}
+}
+
+// runningJob is a job whose session runs on this node. It observes the
+// session, and writes every change of the job to the store.
+type runningJob struct {
+	storeDB  store.HandlerData
+	progress *progressObserver
+	cancel   context.CancelFunc
+
+	mu  sync.Mutex
+	job types.Job
+}
+
+func (r *runningJob) snapshot() *types.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.job
+	return &job
+}
+
+func (r *runningJob) update(fn func(job *types.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.job)
+	r.job.UpdatedAt = time.Now().Unix()
+	if err := r.storeDB.SaveJob(&r.job); err != nil {
+		log.Error("Cannot save job", "id", r.job.ID, "err", err)
+	}
+}
+
+// StateChanged records the protocol state of the session of the job, and
+// publishes it to the subscribers of the job.
+func (r *runningJob) StateChanged(oldState aliceTypes.MainState, newState aliceTypes.MainState) {
+	r.update(func(job *types.Job) {
+		if !job.Status.Finished() {
+			job.State = newState.String()
+		}
+	})
+	r.progress.StateChanged(oldState, newState)
+}
+
+// MessageReceived publishes a message of a peer to the subscribers of the job.
+func (r *runningJob) MessageReceived(from string, msgType string) {
+	r.progress.MessageReceived(from, msgType)
+}
+
+func (r *runningJob) finish(ctx context.Context, result interface{}, err error) {
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(result)
+	}
+	r.update(func(job *types.Job) {
+		job.FinishedAt = time.Now().Unix()
+		switch {
+		case err == nil:
+			job.Status = types.JobStatusDone
+			job.Result = data
+		case ctx.Err() != nil:
+			job.Status = types.JobStatusCancelled
+			job.Error = ctx.Err().Error()
+			job.ErrorType = string(tsserr.CodeOf(ctx.Err()))
+		default:
+			job.Status = types.JobStatusFailed
+			job.Error = err.Error()
+			job.ErrorType = string(tsserr.CodeOf(err))
+		}
+	})
+	if err != nil {
+		log.Warn("Job ended", "id", r.job.ID, "err", err)
+		return
+	}
+	log.Info("Job done", "id", r.job.ID)
+}
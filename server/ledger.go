@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alice-tss/store"
+	"alice-tss/types"
+
+	"github.com/getamis/sirius/log"
+)
+
+// LedgerExportHandler streams the signature ledger as JSON lines. The optional
+// query parameters keyHash, digest, from and to (unix seconds) select entries
+// the same way as signer.QueryLedger. The export lasts as long as the ledger
+// takes to write, until the client goes away: only each write to the client is
+// bounded, by writeTimeout.
+func LedgerExportHandler(storeDB store.HandlerData, writeTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseLedgerQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="ledger.jsonl"`)
+		writer := &deadlineWriter{w: w, rc: http.NewResponseController(w), timeout: writeTimeout}
+		if err := store.ExportLedger(r.Context(), storeDB, query, writer); err != nil {
+			log.Error("Failed to export ledger", "error", err)
+		}
+	}
+}
+
+// deadlineWriter gives each write of a streamed response its own deadline, so
+// that a stalled client is dropped while a long stream goes on.
+type deadlineWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if err := d.rc.SetWriteDeadline(time.Now().Add(d.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+	return d.w.Write(p)
+}
+
+func parseLedgerQuery(r *http.Request) (types.LedgerQuery, error) {
+	values := r.URL.Query()
+	query := types.LedgerQuery{
+		KeyHash: values.Get("keyHash"),
+		Digest:  values.Get("digest"),
+	}
+	for name, target := range map[string]*int64{"from": &query.From, "to": &query.To} {
+		if v := values.Get(name); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+	return query, nil
+}
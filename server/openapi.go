@@ -0,0 +1,177 @@
+package server
+
+import (
+	"strings"
+
+	"alice-tss/tsserr"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// openAPIVersion is the version of the API in its OpenAPI document.
+const openAPIVersion = "v1"
+
+// openAPIDocument returns the OpenAPI 3 document of the REST gateway routes,
+// with the schemas of their messages in their protojson form.
+func openAPIDocument(routes []*gatewayRoute) map[string]interface{} {
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"code":    map[string]interface{}{"type": "integer", "format": "int32", "description": "gRPC status code"},
+				"message": map[string]interface{}{"type": "string"},
+				"type":    map[string]interface{}{"type": "string", "enum": tsserr.Codes(), "description": "type of a typed error"},
+				"details": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			},
+		},
+	}
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		item, ok := paths[route.path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[route.path] = item
+		}
+		item[strings.ToLower(route.httpMethod)] = openAPIOperation(route, schemas)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "alice-tss",
+			"version": openAPIVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+func openAPIOperation(route *gatewayRoute, schemas map[string]interface{}) map[string]interface{} {
+	service := route.method.Parent().Name()
+	input := route.method.Input()
+	inPath := map[string]bool{}
+	var parameters []interface{}
+	for _, param := range route.pathParams() {
+		inPath[param] = true
+		parameters = append(parameters, map[string]interface{}{
+			"name":     param,
+			"in":       "path",
+			"required": true,
+			"schema":   openAPIFieldSchema(input.Fields().ByName(protoreflect.Name(param)), schemas),
+		})
+	}
+
+	operation := map[string]interface{}{
+		"operationId": string(service) + "_" + string(route.method.Name()),
+		"tags":        []string{string(service)},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "A successful response.",
+				"content":     openAPIContent(openAPIMessageRef(route.method.Output(), schemas)),
+			},
+			"default": map[string]interface{}{
+				"description": "An error response.",
+				"content":     openAPIContent(map[string]interface{}{"$ref": "#/components/schemas/Error"}),
+			},
+		},
+	}
+	switch route.body {
+	case "":
+		fields := input.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			if inPath[string(field.Name())] || field.IsMap() || field.Message() != nil {
+				continue
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":   field.JSONName(),
+				"in":     "query",
+				"schema": openAPIFieldSchema(field, schemas),
+			})
+		}
+	case "*":
+		operation["requestBody"] = map[string]interface{}{
+			"content": openAPIContent(openAPIMessageRef(input, schemas)),
+		}
+	default:
+		field := input.Fields().ByName(protoreflect.Name(route.body))
+		operation["requestBody"] = map[string]interface{}{
+			"content": openAPIContent(openAPIFieldSchema(field, schemas)),
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+func openAPIContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// openAPIMessageRef adds the schema of message, and of the messages of its
+// fields, to schemas, and returns a reference to it.
+func openAPIMessageRef(message protoreflect.MessageDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	name := string(message.Name())
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+	properties := map[string]interface{}{}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	schemas[name] = schema
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		properties[field.JSONName()] = openAPIFieldSchema(field, schemas)
+	}
+	return ref
+}
+
+// openAPIFieldSchema returns the schema of field in protojson, where 64-bit
+// integers are strings.
+func openAPIFieldSchema(field protoreflect.FieldDescriptor, schemas map[string]interface{}) map[string]interface{} {
+	if field.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": openAPIFieldSchema(field.MapValue(), schemas),
+		}
+	}
+	var schema map[string]interface{}
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		schema = openAPIMessageRef(field.Message(), schemas)
+	case protoreflect.BoolKind:
+		schema = map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		schema = map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		schema = map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		schema = map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		schema = map[string]interface{}{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		schema = map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		schema = map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		schema = map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var values []string
+		enumValues := field.Enum().Values()
+		for i := 0; i < enumValues.Len(); i++ {
+			values = append(values, string(enumValues.Get(i).Name()))
+		}
+		schema = map[string]interface{}{"type": "string", "enum": values}
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+	if field.IsList() {
+		return map[string]interface{}{"type": "array", "items": schema}
+	}
+	return schema
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// discoverMethod is the OpenRPC service discovery method.
+const discoverMethod = "rpc.discover"
+
+// metaSchema is the schema of the OpenRPC documents.
+const metaSchema = "https://raw.githubusercontent.com/open-rpc/meta-schema/master/schema.json"
+
+const openRPCSchemaRefPrefix = "#/components/schemas/"
+
+var (
+	typeOfRequest       = reflect.TypeOf((*http.Request)(nil))
+	typeOfError         = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfRawMessage    = reflect.TypeOf(json.RawMessage(nil))
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// DiscoverService serves the OpenRPC document of the JSON-RPC API as
+// rpc.discover.
+type DiscoverService struct {
+	document json.RawMessage
+}
+
+// Discover returns the OpenRPC document. discoverCodec routes rpc.discover
+// here, since JSON-RPC method names are those of the Go methods.
+func (s *DiscoverService) Discover(_ *http.Request, _ *types.RpcNoneArgs, reply *json.RawMessage) error {
+	*reply = s.document
+	return nil
+}
+
+// discoverCodec is a codec that calls rpc.Discover for rpc.discover.
+type discoverCodec struct {
+	rpc.Codec
+}
+
+func (c discoverCodec) NewRequest(r *http.Request) rpc.CodecRequest {
+	return discoverCodecRequest{c.Codec.NewRequest(r)}
+}
+
+type discoverCodecRequest struct {
+	rpc.CodecRequest
+}
+
+func (r discoverCodecRequest) Method() (string, error) {
+	method, err := r.CodecRequest.Method()
+	if method == discoverMethod {
+		method = "rpc.Discover"
+	}
+	return method, err
+}
+
+// apiOpenRPCDocument returns the OpenRPC document of the JSON-RPC services of
+// the router, generated from the types of the params and results of their
+// methods.
+func apiOpenRPCDocument() ([]byte, error) {
+	return openRPCDocument(map[string]interface{}{
+		"signer": &RpcService{},
+		"admin":  &AdminService{},
+	})
+}
+
+// openRPCDocument returns the OpenRPC document of services, by name. Like
+// gorilla/rpc, it takes the exported methods with a request, args and reply,
+// returning an error. The params are the fields of the args: gorilla/rpc
+// reads them by name, or as the only element of an array. The typed errors
+// are components.
+func openRPCDocument(services map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schemas := &openRPCSchemas{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	var methods []interface{}
+	for _, name := range names {
+		rcvrType := reflect.TypeOf(services[name])
+		for i := 0; i < rcvrType.NumMethod(); i++ {
+			method := rcvrType.Method(i)
+			mtype := method.Type
+			if method.PkgPath != "" || mtype.NumIn() != 4 || mtype.NumOut() != 1 ||
+				mtype.In(1) != typeOfRequest || mtype.In(2).Kind() != reflect.Ptr ||
+				mtype.In(3).Kind() != reflect.Ptr || mtype.Out(0) != typeOfError {
+				continue
+			}
+			methods = append(methods, map[string]interface{}{
+				"name":           name + "." + method.Name,
+				"paramStructure": "by-name",
+				"params":         schemas.params(mtype.In(2).Elem()),
+				"result": map[string]interface{}{
+					"name":   "reply",
+					"schema": schemas.schema(mtype.In(3).Elem()),
+				},
+			})
+		}
+	}
+	methods = append(methods, map[string]interface{}{
+		"name":        discoverMethod,
+		"description": "Returns the OpenRPC document of the API.",
+		"params":      []interface{}{},
+		"result": map[string]interface{}{
+			"name":   "OpenRPC Schema",
+			"schema": map[string]interface{}{"$ref": metaSchema},
+		},
+	})
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openrpc": "1.2.6",
+		"info": map[string]interface{}{
+			"title":   "alice-tss",
+			"version": "1.0.0",
+		},
+		"methods": methods,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+			"errors":  openRPCErrors(),
+		},
+	}, "", "  ")
+}
+
+// openRPCErrors returns the typed errors of the API by code. Their data is a
+// tsserr.Data, with the same type.
+func openRPCErrors() map[string]interface{} {
+	typed := map[string]interface{}{}
+	for _, code := range tsserr.Codes() {
+		typed[string(code)] = map[string]interface{}{
+			"code":    code.RPCCode(),
+			"message": string(code),
+			"data":    tsserr.Data{Type: code},
+		}
+	}
+	return typed
+}
+
+// openRPCSchemas builds the JSON schemas of Go types, as encoding/json
+// encodes them. Named structs are components, referred to by name.
+type openRPCSchemas struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+// params returns the content descriptors of the fields of args.
+func (s *openRPCSchemas) params(args reflect.Type) []interface{} {
+	params := []interface{}{}
+	for _, field := range jsonFields(args) {
+		params = append(params, map[string]interface{}{
+			"name":   field.name,
+			"schema": s.schema(field.typ),
+		})
+	}
+	return params
+}
+
+func (s *openRPCSchemas) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeOfRawMessage:
+		return map[string]interface{}{}
+	case t.Implements(typeOfJSONMarshaler) || reflect.PointerTo(t).Implements(typeOfJSONMarshaler):
+		return map[string]interface{}{}
+	case t.Implements(typeOfTextMarshaler) || reflect.PointerTo(t).Implements(typeOfTextMarshaler):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.structSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the schema of a struct: a reference to its component
+// when it is named, and not an instance of a generic type.
+func (s *openRPCSchemas) structSchema(t reflect.Type) map[string]interface{} {
+	named := t.Name() != "" && !strings.Contains(t.Name(), "[")
+	if named {
+		if name, ok := s.names[t]; ok {
+			return map[string]interface{}{"$ref": openRPCSchemaRefPrefix + name}
+		}
+		name := t.Name()
+		if _, taken := s.schemas[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		s.names[t] = name
+		// Set before the fields, which may refer to it.
+		s.schemas[name] = map[string]interface{}{}
+		s.schemas[name] = s.objectSchema(t)
+		return map[string]interface{}{"$ref": openRPCSchemaRefPrefix + name}
+	}
+	return s.objectSchema(t)
+}
+
+func (s *openRPCSchemas) objectSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, field := range jsonFields(t) {
+		properties[field.name] = s.schema(field.typ)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields returns the fields of the struct t that encoding/json encodes,
+// with those of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: field.Type})
+	}
+	return fields
+}
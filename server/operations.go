@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+
+	"alice-tss/pb"
+	"alice-tss/peer"
+	"alice-tss/types"
+	"alice-tss/utils"
+
+	"github.com/getamis/sirius/log"
+)
+
+// operations starts the protocol sessions of the node as jobs, for the
+// JSON-RPC, gRPC and REST APIs alike. Callers are authorized by the API.
+type operations struct {
+	pm          *peer.P2PManager
+	selfService *SelfService
+	tssCaller   *TssCaller
+	jobs        *jobRunner
+}
+
+func (a *nodeAPI) operations() *operations {
+	return &operations{
+		pm:          a.pm,
+		selfService: a.selfService,
+		tssCaller:   a.tssCaller,
+		jobs:        a.jobs,
+	}
+}
+
+// startSign starts signing the message of data with its key across the
+// connected peers. The result of the job is a types.RVSignature.
+func (o *operations) startSign(ctx context.Context, data types.SignRequest) (*types.Job, error) {
+	dataRequestSign := signRequestProto(&data)
+	hash := utils.ToHexHash([]byte(dataRequestSign.Message))
+	sessionID := newSignSession(o.pm.SelfID(), dataRequestSign)
+	pm := o.pm.ClonePeerManager(peer.GetProtocol(sessionID))
+
+	return o.jobs.Start(ctx, types.JobKindSign, dataRequestSign.Hash, data, func(ctx context.Context) (interface{}, error) {
+		result, err := o.tssCaller.SignMessage(ctx, pm, dataRequestSign, RequestToPeer(pm, "TssPeerService", "SignMessage", dataRequestSign))
+		if err != nil {
+			log.Error("Failed to sign message", "error", err)
+			return nil, err
+		}
+		return types.RVSignature{
+			R:         hex.EncodeToString(result.R.Bytes()),
+			S:         hex.EncodeToString(result.S.Bytes()),
+			Hash:      hash,
+			SessionID: sessionID,
+			KeyHash:   dataRequestSign.Hash,
+		}, nil
+	})
+}
+
+// startSelfSign starts signing the message of data with the self-service
+// cluster, which must be available.
+func (o *operations) startSelfSign(ctx context.Context, data types.SignRequest) (*types.Job, error) {
+	dataRequestSign := signRequestProto(&data)
+	return o.jobs.Start(ctx, types.JobKindSign, dataRequestSign.Hash, data, func(ctx context.Context) (interface{}, error) {
+		result, err := o.selfService.SignMessage(ctx, o.tssCaller, dataRequestSign)
+		if err != nil {
+			log.Error("Failed to sign message with self service", "error", err)
+			return nil, err
+		}
+		return types.RVSignature{
+			R:         hex.EncodeToString(result.R.Bytes()),
+			S:         hex.EncodeToString(result.S.Bytes()),
+			Hash:      utils.ToHexHash([]byte(dataRequestSign.Message)),
+			SessionID: dataRequestSign.SessionId,
+			KeyHash:   dataRequestSign.Hash,
+		}, nil
+	})
+}
+
+// startDKG starts generating a key across the connected peers. The hash of
+// the new key is the key hash of the job, and its result a pb.DkgReply.
+func (o *operations) startDKG(ctx context.Context, data types.DKGRequest) (*types.Job, error) {
+	hash := utils.RandomHash()
+	pm := o.pm.ClonePeerManager(peer.GetProtocol(hash))
+
+	return o.jobs.Start(ctx, types.JobKindDKG, hash, data, func(ctx context.Context) (interface{}, error) {
+		result, err := o.tssCaller.RegisterDKG(ctx, pm, hash, RpcToPeer(pm, "TssPeerService", "RegisterDKG", []byte(hash)))
+		if err != nil {
+			log.Error("Failed to register DKG", "error", err)
+			return nil, err
+		}
+		return dkgReply(hash, result), nil
+	})
+}
+
+// startSelfDKG starts generating a key with the self-service cluster, which
+// must be available.
+func (o *operations) startSelfDKG(ctx context.Context, data types.DKGRequest) (*types.Job, error) {
+	hash := utils.RandomHash()
+	return o.jobs.Start(ctx, types.JobKindDKG, hash, data, func(ctx context.Context) (interface{}, error) {
+		dkgResult, err := o.selfService.RegisterDKG(ctx, o.tssCaller, hash)
+		if err != nil {
+			return nil, err
+		}
+		return dkgReply(hash, dkgResult), nil
+	})
+}
+
+// startReshare starts resharing the key of data. The result of the job is the
+// types.KeyView of the key after resharing.
+func (o *operations) startReshare(ctx context.Context, data types.ReshareRequest) (*types.Job, error) {
+	dataShare := &pb.ReshareRequest{Hash: data.Hash, Pubkey: data.Pubkey, Epoch: data.Epoch}
+	pm := o.pm.ClonePeerManager(peer.GetProtocol(dataShare.Hash))
+
+	return o.jobs.Start(ctx, types.JobKindReshare, dataShare.Hash, data, func(ctx context.Context) (interface{}, error) {
+		if err := o.tssCaller.Reshare(ctx, pm, dataShare, RequestToPeer(pm, "TssPeerService", "Reshare", dataShare)); err != nil {
+			log.Error("Failed to reshare", "error", err)
+			return nil, err
+		}
+		return o.tssCaller.GetKeyView(dataShare.Hash, dataShare.Pubkey)
+	})
+}
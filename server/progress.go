@@ -0,0 +1,203 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"alice-tss/types"
+
+	aliceTypes "github.com/getamis/alice/types"
+	"github.com/getamis/sirius/log"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// progressPath is the WebSocket endpoint streaming the progress of a job.
+	progressPath = "/tss/progress/{id}"
+	// progressBuffer is how many events a slow subscriber may lag behind
+	// before it is dropped.
+	progressBuffer       = 256
+	progressWriteTimeout = 10 * time.Second
+)
+
+// newProgressEvent returns an event of type typ about the job id, timed now.
+func newProgressEvent(typ types.ProgressEventType, id string) types.ProgressEvent {
+	return types.ProgressEvent{Type: typ, JobID: id, Time: time.Now().UnixMilli()}
+}
+
+// progressHub fans the progress events of the running jobs out to their
+// subscribers.
+type progressHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan types.ProgressEvent]struct{}
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{subs: make(map[string]map[chan types.ProgressEvent]struct{})}
+}
+
+// subscribe returns a channel receiving the events of the job id, closed after
+// its result or when the subscriber falls too far behind.
+func (h *progressHub) subscribe(id string) chan types.ProgressEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan types.ProgressEvent, progressBuffer)
+	if h.subs[id] == nil {
+		h.subs[id] = make(map[chan types.ProgressEvent]struct{})
+	}
+	h.subs[id][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe stops the events of ch, if they have not stopped yet.
+func (h *progressHub) unsubscribe(id string, ch chan types.ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[id][ch]; ok {
+		delete(h.subs[id], ch)
+		close(ch)
+	}
+}
+
+// publish sends event to the subscribers of its job. It never blocks: the
+// subscribers that are full are dropped.
+func (h *progressHub) publish(event types.ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+			log.Warn("Dropping slow progress subscriber", "job", event.JobID)
+			delete(h.subs[event.JobID], ch)
+			close(ch)
+		}
+	}
+	if len(h.subs[event.JobID]) == 0 {
+		delete(h.subs, event.JobID)
+	}
+}
+
+// finish sends the result event of a job and closes its subscribers.
+func (h *progressHub) finish(job *types.Job) {
+	event := newProgressEvent(types.ProgressResult, job.ID)
+	event.Job = job
+	h.publish(event)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[job.ID] {
+		close(ch)
+	}
+	delete(h.subs, job.ID)
+}
+
+// progressObserver reports the progress of a session to publish.
+type progressObserver struct {
+	id      string
+	publish func(event types.ProgressEvent)
+}
+
+func (o *progressObserver) StateChanged(oldState aliceTypes.MainState, newState aliceTypes.MainState) {
+	event := newProgressEvent(types.ProgressState, o.id)
+	event.OldState = oldState.String()
+	event.State = newState.String()
+	o.publish(event)
+}
+
+func (o *progressObserver) MessageReceived(from string, msgType string) {
+	event := newProgressEvent(types.ProgressMessage, o.id)
+	event.Peer = from
+	event.Message = msgType
+	o.publish(event)
+}
+
+var progressUpgrader = websocket.Upgrader{}
+
+// progressHandler streams the progress of a job over a WebSocket, as JSON
+// progress events: the job first, then the steps of its session, and its
+// result last. The connection is closed after the result.
+func progressHandler(jobs *jobRunner, authz *authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		job, err := jobs.Get(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := authz.authorizeJob(r.Context(), job); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrPermissionDenied) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		conn, err := progressUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Warn("Cannot upgrade progress connection", "job", id, "err", err)
+			return
+		}
+		defer conn.Close()
+
+		events, job, err := jobs.Watch(id)
+		if err != nil {
+			log.Error("Cannot watch job", "job", id, "err", err)
+			return
+		}
+		defer jobs.Unwatch(id, events)
+		closed := make(chan struct{})
+		go func() {
+			// Control frames are read here; clients send nothing else.
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		first := newProgressEvent(types.ProgressJob, id)
+		first.Job = job
+		if events == nil {
+			first.Type = types.ProgressResult
+		}
+		if err := writeProgress(conn, first); err != nil {
+			return
+		}
+		for events != nil {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					break
+				}
+				if err := writeProgress(conn, event); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+		deadline := time.Now().Add(progressWriteTimeout)
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	})
+}
+
+func writeProgress(conn *websocket.Conn, event types.ProgressEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(progressWriteTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		log.Warn("Cannot write progress", "job", event.JobID, "err", err)
+		return err
+	}
+	return nil
+}
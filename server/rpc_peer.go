@@ -2,11 +2,11 @@ package server
 
 import (
 	"alice-tss/pb"
-	"alice-tss/service"
 	"alice-tss/store"
+	"alice-tss/tsserr"
 	"alice-tss/types"
 	"context"
-	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -17,9 +17,12 @@ import (
 	"github.com/multiformats/go-multiaddr"
 
 	"alice-tss/peer"
-	"alice-tss/utils"
 )
 
+// peerRetryInterval is how long SendToPeer waits before calling a peer it
+// could not reach again.
+const peerRetryInterval = 3 * time.Second
+
 type PeerArgs struct {
 	PeerAddrTarget string
 	SvcName        string
@@ -39,63 +42,129 @@ type TssPeerService struct {
 	TssCaller *TssCaller
 }
 
-func (t *TssPeerService) SignMessage(_ context.Context, args PingArgs, _ *PingReply) error {
+func (t *TssPeerService) SignMessage(ctx context.Context, args PingArgs, _ *PingReply) error {
 	log.Info("RPC server", "SignMessage", "called", "args", args)
 	var signRequest pb.SignRequest
 	err := UnmarshalRequest(args.Data, &signRequest)
 	if err != nil {
-		return errors.New("invalid message, cannot unmarshal")
+		return err
+	}
+	sender, err := gorpc.GetRequestSender(ctx)
+	if err != nil {
+		return err
+	}
+	if signRequest.Initiator != sender.String() {
+		return fmt.Errorf("initiator %q does not match sender %s", signRequest.Initiator, sender)
+	}
+	if signRequest.SessionId == "" {
+		return tsserr.New(tsserr.InvalidInput, "sign request of %s has no session ID", sender)
 	}
 
-	hash := utils.ToHexHash([]byte(signRequest.Message))
-	pm := t.Pm.ClonePeerManager(peer.GetProtocol(hash))
+	pm := t.Pm.ClonePeerManager(peer.GetProtocol(signRequest.SessionId))
 
-	_, err = t.TssCaller.SignMessage(pm, &signRequest, nil)
+	_, err = t.TssCaller.SignMessage(context.Background(), pm, &signRequest, nil)
 	return err
 }
 
-func (t *TssPeerService) Reshare(_ context.Context, args PingArgs, _ *PingReply) error {
+// Reshare joins the reshare of a key that the sender holds too, unless the
+// state of the key refuses it.
+func (t *TssPeerService) Reshare(ctx context.Context, args PingArgs, _ *PingReply) error {
 	log.Info("RPC server", "Reshare", "called", "args", args)
 	var reshareRequest pb.ReshareRequest
 	err := UnmarshalRequest(args.Data, &reshareRequest)
 	if err != nil {
-		return errors.New("invalid message, cannot unmarshal")
+		return err
 	}
-
-	signerCfg, err := t.TssCaller.StoreDB.GetSignerConfig(reshareRequest.Hash, reshareRequest.Pubkey)
+	if err := t.checkSender(ctx, reshareRequest.Hash); err != nil {
+		return err
+	}
+	record, err := t.TssCaller.keyRecord(reshareRequest.Hash, reshareRequest.Pubkey)
 	if err != nil {
-		log.Error("GetSignerConfig", "err", err)
+		return err
+	}
+	if err := checkKeyState(reshareRequest.Hash, record.State, types.KeyOperationReshare); err != nil {
+		log.Error("Reshare", "hash", reshareRequest.Hash, "err", err)
 		return err
 	}
 
 	pm := t.Pm.ClonePeerManager(peer.GetProtocol(reshareRequest.Hash))
-	reshare, err := service.NewReshareService(&types.ReshareConfig{
-		Threshold: 2,
-		Share:     signerCfg.Share,
-		Pubkey:    signerCfg.Pubkey,
-		BKs:       signerCfg.BKs,
-	}, pm, reshareRequest.Hash, t.TssCaller.StoreDB)
-	if err != nil {
-		log.Error("NewSignerService", "err", err)
+	return t.TssCaller.Reshare(context.Background(), pm, &reshareRequest, nil)
+}
+
+// PrepareRollback agrees to a rollback only if this node still holds the requested epoch.
+func (t *TssPeerService) PrepareRollback(ctx context.Context, args PingArgs, _ *PingReply) error {
+	log.Info("RPC server", "PrepareRollback", "called")
+	var rollbackRequest pb.RollbackRequest
+	if err := UnmarshalRequest(args.Data, &rollbackRequest); err != nil {
+		return err
+	}
+	if err := t.checkSender(ctx, rollbackRequest.Hash); err != nil {
 		return err
 	}
+	return t.TssCaller.PrepareRollback(&rollbackRequest)
+}
 
-	log.Info("Stream Test", "service process", "called")
-	go reshare.Process()
+// CommitRollback switches this node to the epoch every holder agreed on.
+func (t *TssPeerService) CommitRollback(ctx context.Context, args PingArgs, _ *PingReply) error {
+	log.Info("RPC server", "CommitRollback", "called")
+	var rollbackRequest pb.RollbackRequest
+	if err := UnmarshalRequest(args.Data, &rollbackRequest); err != nil {
+		return err
+	}
+	if err := t.checkSender(ctx, rollbackRequest.Hash); err != nil {
+		return err
+	}
+	return t.TssCaller.CommitRollback(&rollbackRequest)
+}
+
+// PrepareKeyState agrees to a key state change only if the key can make the transition on this node.
+func (t *TssPeerService) PrepareKeyState(ctx context.Context, args PingArgs, _ *PingReply) error {
+	log.Info("RPC server", "PrepareKeyState", "called")
+	var keyStateRequest pb.KeyStateRequest
+	if err := UnmarshalRequest(args.Data, &keyStateRequest); err != nil {
+		return err
+	}
+	if err := t.checkSender(ctx, keyStateRequest.Hash); err != nil {
+		return err
+	}
+	return t.TssCaller.PrepareKeyState(&keyStateRequest)
+}
+
+// CommitKeyState moves the key to the state every holder agreed on.
+func (t *TssPeerService) CommitKeyState(ctx context.Context, args PingArgs, _ *PingReply) error {
+	log.Info("RPC server", "CommitKeyState", "called")
+	var keyStateRequest pb.KeyStateRequest
+	if err := UnmarshalRequest(args.Data, &keyStateRequest); err != nil {
+		return err
+	}
+	if err := t.checkSender(ctx, keyStateRequest.Hash); err != nil {
+		return err
+	}
+	return t.TssCaller.CommitKeyState(&keyStateRequest)
+}
 
-	return nil
+// checkSender refuses the peer call of ctx unless its sender holds a share of
+// the key hash: only holders may change the key on this node.
+func (t *TssPeerService) checkSender(ctx context.Context, hash string) error {
+	sender, err := gorpc.GetRequestSender(ctx)
+	if err != nil {
+		return err
+	}
+	return t.TssCaller.checkHolder(hash, sender.String())
 }
 
 func (t *TssPeerService) RegisterDKG(_ context.Context, argType PingArgs, _ *PingReply) error {
 	log.Info("RegisterDKG")
 
 	pm := t.Pm.ClonePeerManager(peer.GetProtocol(string(argType.Data)))
-	_, err := t.TssCaller.RegisterDKG(pm, string(argType.Data), nil)
+	_, err := t.TssCaller.RegisterDKG(context.Background(), pm, string(argType.Data), nil)
 
 	return err
 }
 
-func MsgToPeer(client host.Host, data PeerArgs) (*PingReply, error) {
+// MsgToPeer calls the method of data on the peer at its address once. ctx
+// bounds the connection and the call.
+func MsgToPeer(ctx context.Context, client host.Host, data PeerArgs) (*PingReply, error) {
 	ma, err := multiaddr.NewMultiaddr(data.PeerAddrTarget)
 	if err != nil {
 		log.Error("Failed to create multiaddr", "error", err)
@@ -106,32 +175,53 @@ func MsgToPeer(client host.Host, data PeerArgs) (*PingReply, error) {
 		log.Error("Failed to get addr info from p2p addr", "error", err)
 		return nil, err
 	}
-	err = client.Connect(context.Background(), *peerInfo)
+	err = client.Connect(ctx, *peerInfo)
 	if err != nil {
 		log.Error("Failed to connect to peer", "error", err)
-		return nil, err
+		return nil, peerUnreachable(peerInfo.ID, err)
 	}
 
 	rpcClient := gorpc.NewClient(client, peer.ProtocolId)
 
 	var reply PingReply
-	err = rpcClient.Call(peerInfo.ID, data.SvcName, data.SvcMethod, data.Args, &reply)
+	err = rpcClient.CallContext(ctx, peerInfo.ID, data.SvcName, data.SvcMethod, data.Args, &reply)
 	if err != nil {
 		log.Error("Failed to call peer", "error", err)
-		return nil, err
+		if gorpc.IsServerError(err) {
+			return nil, err
+		}
+		return nil, peerUnreachable(peerInfo.ID, err)
 	}
 	return &reply, nil
 }
 
-func SendToPeer(client host.Host, data PeerArgs, wg *sync.WaitGroup) (*PingReply, error) {
+// peerUnreachable reports that the peer id could not be called.
+func peerUnreachable(id libp2pPeer.ID, err error) error {
+	return tsserr.New(tsserr.PeerUnreachable, "peer %s unreachable: %w", id, err).With("peer", id.String())
+}
+
+// SendToPeer calls the method of data on a peer like MsgToPeer, retrying
+// every peerRetryInterval while the peer cannot be reached, until ctx is done.
+func SendToPeer(ctx context.Context, client host.Host, data PeerArgs, wg *sync.WaitGroup) (*PingReply, error) {
 	defer wg.Done()
 
 	for {
 		// Connect the host to the peer.
-		reply, err := MsgToPeer(client, data)
+		reply, err := MsgToPeer(ctx, client, data)
+		if gorpc.IsServerError(err) {
+			// The peer was reached and refused the call; retrying won't help.
+			log.Warn("Peer refused the call", "method", data.SvcMethod, "err", err)
+			return nil, err
+		}
 		if err != nil {
 			log.Warn("Failed to sent to peer", "to", client.ID().String(), "err", err)
-			time.Sleep(3 * time.Second)
+			timer := time.NewTimer(peerRetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("call %s.%s: %w", data.SvcName, data.SvcMethod, ctx.Err())
+			case <-timer.C:
+			}
 			continue
 		}
 		log.Debug("Successfully connect to peer")
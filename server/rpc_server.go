@@ -2,13 +2,15 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"time"
+	"slices"
 
+	"alice-tss/metrics"
 	"alice-tss/pb"
 	"alice-tss/peer"
 	"alice-tss/store"
@@ -16,22 +18,18 @@ import (
 	"alice-tss/utils"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/getamis/alice/crypto/tss/dkg"
 	"github.com/getamis/sirius/log"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/rpc/v2"
 	rpcjson "github.com/gorilla/rpc/v2/json2"
+	gorpc "github.com/libp2p/go-libp2p-gorpc"
 )
 
-// unmarshalRequestData is a helper function to unmarshal request data with proper error handling
-func unmarshalRequestData(data interface{}, target interface{}) error {
-	argData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request data: %w", err)
-	}
-	if err := json.Unmarshal(argData, target); err != nil {
-		return fmt.Errorf("failed to unmarshal request data: %w", err)
-	}
-	return nil
+// signRequestProto returns the sign request of the data of a JSON-RPC call,
+// as sent to the peers.
+func signRequestProto(data *types.SignRequest) *pb.SignRequest {
+	return &pb.SignRequest{Hash: data.Hash, Pubkey: data.Pubkey, Message: data.Message, Epoch: data.Epoch}
 }
 
 type RpcService struct {
@@ -40,19 +38,20 @@ type RpcService struct {
 	storeDB     store.HandlerData
 	selfService *SelfService
 	tssCaller   *TssCaller
+	authz       *authorizer
+	jobs        *jobRunner
+	ops         *operations
 }
 
-// GetSignerConfig retrieves the signer configuration for a given request.
-func (h *RpcService) GetSignerConfig(_ *http.Request, args *types.RpcDataArgs, reply *types.RpcDataReply) error {
+// GetSignerConfig returns the public view of the key of a sign request. The
+// share itself is only available from admin.GetSignerConfig.
+func (h *RpcService) GetSignerConfig(r *http.Request, args *types.RpcArgs[types.KeyRequest], reply *types.RpcReply[*types.KeyView]) error {
 	log.Info("RPC server GetSignerConfig called", "args", args)
-
-	var dataRequestSign pb.SignRequest
-	if err := unmarshalRequestData(args.Data, &dataRequestSign); err != nil {
-		log.Error("Failed to unmarshal sign request", "error", err)
+	if err := h.authz.authorize(r.Context(), types.PermissionRead, args.Data.Hash); err != nil {
 		return err
 	}
 
-	result, err := h.tssCaller.GetSignerConfig(&dataRequestSign)
+	result, err := h.tssCaller.GetKeyView(args.Data.Hash, args.Data.Pubkey)
 	if err != nil {
 		log.Error("Failed to get signer config", "error", err)
 		return err
@@ -62,189 +61,209 @@ func (h *RpcService) GetSignerConfig(_ *http.Request, args *types.RpcDataArgs, r
 	return nil
 }
 
-// SignMessage performs threshold signature generation for a given message.
-func (h *RpcService) SignMessage(_ *http.Request, args *types.RpcDataArgs, reply *types.RpcDataReply) error {
+// SignMessage starts threshold signature generation for a given message, and
+// returns its job. The signature is the result of the job.
+func (h *RpcService) SignMessage(r *http.Request, args *types.RpcArgs[types.SignRequest], reply *types.RpcReply[*types.Job]) error {
 	log.Info("RPC server SignMessage called", "args", args)
-
-	var dataRequestSign pb.SignRequest
-	if err := unmarshalRequestData(args.Data, &dataRequestSign); err != nil {
-		log.Error("Failed to unmarshal sign request", "error", err)
+	if err := h.authz.authorize(r.Context(), types.PermissionSign, args.Data.Hash); err != nil {
 		return err
 	}
 
-	hash := utils.ToHexHash([]byte(dataRequestSign.Message))
-	pm := h.pm.ClonePeerManager(peer.GetProtocol(hash))
-
-	argData, err := json.Marshal(args.Data)
+	job, err := h.ops.startSign(r.Context(), args.Data)
 	if err != nil {
-		log.Error("Failed to marshal args data", "error", err)
 		return err
 	}
 
-	result, err := h.tssCaller.SignMessage(pm, &dataRequestSign, RpcToPeer(pm, "TssPeerService", "SignMessage", argData))
-	if err != nil {
-		log.Error("Failed to sign message", "error", err)
-		return err
-	}
-
-	reply.Data = types.RVSignature{
-		R:    hex.EncodeToString(result.R.Bytes()),
-		S:    hex.EncodeToString(result.S.Bytes()),
-		Hash: hash,
-	}
-
+	reply.Data = job
 	return nil
 }
 
-// SelfSignMessage performs threshold signature generation using the self-service cluster.
-func (h *RpcService) SelfSignMessage(_ *http.Request, args *types.RpcDataArgs, reply *types.RpcDataReply) error {
+// SelfSignMessage starts threshold signature generation using the self-service
+// cluster, and returns its job.
+func (h *RpcService) SelfSignMessage(r *http.Request, args *types.RpcArgs[types.SignRequest], reply *types.RpcReply[*types.Job]) error {
 	log.Info("RPC server SelfSignMessage called", "args", args)
 	if h.selfService == nil {
 		return errors.New("self service is not available")
 	}
-
-	var dataRequestSign pb.SignRequest
-	if err := unmarshalRequestData(args.Data, &dataRequestSign); err != nil {
-		log.Error("Failed to unmarshal sign request", "error", err)
+	if err := h.authz.authorize(r.Context(), types.PermissionSign, args.Data.Hash); err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-	result, err := h.selfService.SignMessage(ctx, h.tssCaller, &dataRequestSign)
+	job, err := h.ops.startSelfSign(r.Context(), args.Data)
 	if err != nil {
-		log.Error("Failed to sign message with self service", "error", err)
 		return err
 	}
 
-	reply.Data = types.RVSignature{
-		R:    hex.EncodeToString(result.R.Bytes()),
-		S:    hex.EncodeToString(result.S.Bytes()),
-		Hash: utils.ToHexHash([]byte(dataRequestSign.Message)),
-	}
-
+	reply.Data = job
 	return nil
 }
 
-// RegisterDKG initiates a Distributed Key Generation process across connected peers.
-func (h *RpcService) RegisterDKG(_ *http.Request, _ *types.RpcDataArgs, reply *types.RpcDataReply) error {
+// RegisterDKG starts a Distributed Key Generation process across connected
+// peers, and returns its job. The hash of the new key is known at once, as
+// the key hash of the job.
+func (h *RpcService) RegisterDKG(r *http.Request, args *types.RpcArgs[types.DKGRequest], reply *types.RpcReply[*types.Job]) error {
 	log.Info("RPC server RegisterDKG called")
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
 
-	hash := utils.RandomHash()
-	pm := h.pm.ClonePeerManager(peer.GetProtocol(hash))
-
-	result, err := h.tssCaller.RegisterDKG(pm, hash, RpcToPeer(pm, "TssPeerService", "RegisterDKG", []byte(hash)))
+	job, err := h.ops.startDKG(r.Context(), args.Data)
 	if err != nil {
-		log.Error("Failed to register DKG", "error", err)
 		return err
 	}
 
-	pubkey := crypto.CompressPubkey(result.PublicKey.ToPubKey())
-	reply.Data = pb.DkgReply{
-		X:       hex.EncodeToString(result.PublicKey.GetX().Bytes()),
-		Y:       hex.EncodeToString(result.PublicKey.GetY().Bytes()),
-		Address: crypto.PubkeyToAddress(*result.PublicKey.ToPubKey()).String(),
-		Pubkey:  hex.EncodeToString(pubkey),
-		Hash:    hash,
-	}
+	reply.Data = job
 	return nil
 }
 
-func (h *RpcService) RegisterSelfDKG(_ *http.Request, _ *types.RpcDataArgs, reply *types.RpcDataReply) error {
+func (h *RpcService) RegisterSelfDKG(r *http.Request, args *types.RpcArgs[types.DKGRequest], reply *types.RpcReply[*types.Job]) error {
 	log.Info("RPC server", "RegisterSelfDKG", "called", "port", h.config.Port)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, ""); err != nil {
+		return err
+	}
 	if h.selfService == nil {
 		return errors.New("self service is not available")
 	}
 
-	hash := utils.RandomHash()
-
-	ctx := context.Background()
-	dkgResult, err := h.selfService.RegisterDKG(ctx, h.tssCaller, hash)
+	job, err := h.ops.startSelfDKG(r.Context(), args.Data)
 	if err != nil {
 		return err
 	}
 
-	pubkey := crypto.CompressPubkey(dkgResult.PublicKey.ToPubKey())
-	reply.Data = pb.DkgReply{
-		X:       hex.EncodeToString(dkgResult.PublicKey.GetX().Bytes()),
-		Y:       hex.EncodeToString(dkgResult.PublicKey.GetY().Bytes()),
-		Address: crypto.PubkeyToAddress(*dkgResult.PublicKey.ToPubKey()).String(),
-		Pubkey:  hex.EncodeToString(pubkey),
-		Hash:    hash,
-	}
+	reply.Data = job
 	return nil
 }
 
-// Reshare initiates a key resharing process to refresh the threshold shares.
-func (h *RpcService) Reshare(_ *http.Request, args *types.RpcDataArgs, reply *types.RpcDataReply) error {
+// Reshare starts a key resharing process to refresh the threshold shares, and
+// returns its job. The result of the job is the key after resharing.
+func (h *RpcService) Reshare(r *http.Request, args *types.RpcArgs[types.ReshareRequest], reply *types.RpcReply[*types.Job]) error {
 	log.Info("RPC server Reshare called", "args", args)
+	if err := h.authz.authorize(r.Context(), types.PermissionAdmin, args.Data.Hash); err != nil {
+		return err
+	}
 
-	var dataShare pb.ReshareRequest
-	if err := unmarshalRequestData(args.Data, &dataShare); err != nil {
-		log.Error("Failed to unmarshal reshare request", "error", err)
+	job, err := h.ops.startReshare(r.Context(), args.Data)
+	if err != nil {
 		return err
 	}
 
-	reply.Data = dataShare.Hash
-	pm := h.pm.ClonePeerManager(peer.GetProtocol(dataShare.Hash))
+	reply.Data = job
+	return nil
+}
+
+// GetJob returns the job of an operation: its status, the protocol state of
+// its session, and its result or error once it is over.
+func (h *RpcService) GetJob(r *http.Request, args *types.RpcKeyArgs, reply *types.RpcReply[*types.Job]) error {
+	log.Info("RPC server GetJob called", "key", args.Key)
 
-	argData, err := json.Marshal(args.Data)
+	job, err := h.jobs.Get(args.Key)
 	if err != nil {
-		log.Error("Failed to marshal args data", "error", err)
+		log.Error("Failed to get job", "id", args.Key, "error", err)
 		return err
 	}
-
-	if err := h.tssCaller.Reshare(pm, &dataShare, RpcToPeer(pm, "TssPeerService", "Reshare", argData)); err != nil {
-		log.Error("Failed to reshare", "error", err)
+	if err := h.authz.authorizeJob(r.Context(), job); err != nil {
 		return err
 	}
+	reply.Data = job
+	return nil
+}
+
+// CancelJob stops the session of a running job on this node. The job turns
+// cancelled once the session has stopped.
+func (h *RpcService) CancelJob(r *http.Request, args *types.RpcKeyArgs, reply *types.RpcReply[*types.Job]) error {
+	log.Info("RPC server CancelJob called", "key", args.Key)
 
+	job, err := h.jobs.Get(args.Key)
+	if err != nil {
+		log.Error("Failed to get job", "id", args.Key, "error", err)
+		return err
+	}
+	if err := h.authz.authorizeJob(r.Context(), job); err != nil {
+		return err
+	}
+	if job, err = h.jobs.Cancel(args.Key); err != nil {
+		log.Error("Failed to cancel job", "id", args.Key, "error", err)
+		return err
+	}
+	reply.Data = job
 	return nil
 }
 
-// GetDKG retrieves DKG result data by hash key.
-func (h *RpcService) GetDKG(_ *http.Request, args *types.RpcKeyArgs, reply *types.RpcDataReply) error {
+// GetDKG returns the public view of the key stored under a hash.
+func (h *RpcService) GetDKG(r *http.Request, args *types.RpcKeyArgs, reply *types.RpcReply[*types.KeyView]) error {
 	log.Info("RPC server GetDKG called", "key", args.Key)
+	if err := h.authz.authorize(r.Context(), types.PermissionRead, args.Key); err != nil {
+		return err
+	}
 
 	data, err := h.tssCaller.StoreDB.GetDKGResultData(args.Key)
 	if err != nil {
 		log.Error("Failed to get DKG result data", "key", args.Key, "error", err)
 		return err
 	}
-	reply.Data = data
+	reply.Data = data.View(args.Key)
 	return nil
 }
 
-// CheckSignature verifies an ECDSA signature against a message and public key.
-func (h *RpcService) CheckSignature(_ *http.Request, args *types.RpcDataArgs, reply *types.RpcDataReply) error {
-	log.Info("RPC server CheckSignature called", "args", args)
+// ListKeys lists the keys held by this node, filtered and paginated by the request.
+func (h *RpcService) ListKeys(r *http.Request, args *types.RpcArgs[types.KeyFilter], reply *types.RpcReply[*types.KeyPage]) error {
+	log.Info("RPC server ListKeys called", "args", args)
 
-	var dataSignature pb.CheckSignatureByPubkeyRequest
-	if err := unmarshalRequestData(args.Data, &dataSignature); err != nil {
-		log.Error("Failed to unmarshal signature check request", "error", err)
+	canUse, err := h.authz.keyFilter(r.Context(), types.PermissionRead)
+	if err != nil {
 		return err
 	}
 
-	hash := utils.ToHexHash([]byte(dataSignature.Message))
-	log.Info("CheckSignature", "hash", hash)
+	page, err := h.tssCaller.StoreDB.ListKeys(args.Data)
+	if err != nil {
+		log.Error("Failed to list keys", "error", err)
+		return err
+	}
+	page.Keys = slices.DeleteFunc(page.Keys, func(key types.KeySummary) bool { return !canUse(key.Hash) })
+	reply.Data = page
+	return nil
+}
+
+// QueryLedger returns the signing sessions recorded in the ledger, selected by
+// key hash, message digest and start time and paginated by the request.
+func (h *RpcService) QueryLedger(r *http.Request, args *types.RpcArgs[types.LedgerQuery], reply *types.RpcReply[*types.LedgerPage]) error {
+	log.Info("RPC server QueryLedger called", "args", args)
+
+	canUse, err := h.authz.keyFilter(r.Context(), types.PermissionRead)
+	if err != nil {
+		return err
+	}
 
-	data, err := h.tssCaller.StoreDB.GetDKGResultData(hash)
+	page, err := store.QueryLedger(h.tssCaller.StoreDB, args.Data)
 	if err != nil {
-		log.Error("Failed to get DKG result data", "hash", hash, "error", err)
+		log.Error("Failed to query ledger", "error", err)
+		return err
+	}
+	page.Entries = slices.DeleteFunc(page.Entries, func(entry types.LedgerEntry) bool { return !canUse(entry.KeyHash) })
+	reply.Data = page
+	return nil
+}
+
+// CheckSignature verifies an ECDSA signature against a message and public key.
+func (h *RpcService) CheckSignature(r *http.Request, args *types.RpcArgs[types.CheckSignatureRequest], reply *types.RpcReply[*utils.ResponseCheckSignature]) error {
+	log.Info("RPC server CheckSignature called", "args", args)
+
+	dataSignature := args.Data
+	if err := h.authz.authorize(r.Context(), types.PermissionRead, ""); err != nil {
 		return err
 	}
 
-	var rvSignature types.RVSignature
-	rvsData, err := json.Marshal(data)
+	log.Info("CheckSignature", "message", dataSignature.Message, "session", dataSignature.SessionID)
+	rvSignature, err := h.tssCaller.GetSignature(dataSignature.Message, dataSignature.Pubkey, dataSignature.SessionID)
 	if err != nil {
-		log.Error("Failed to marshal signature data", "error", err)
+		log.Error("Failed to get signature data", "session", dataSignature.SessionID, "error", err)
 		return err
 	}
-	if err := json.Unmarshal(rvsData, &rvSignature); err != nil {
-		log.Error("Failed to unmarshal signature data", "error", err)
+	if err := h.authz.authorize(r.Context(), types.PermissionRead, signatureKey(rvSignature)); err != nil {
 		return err
 	}
 
-	checkedSignature, err := utils.CheckSignatureECDSA(dataSignature.Message, rvSignature, dataSignature.Pubkey)
+	checkedSignature, err := utils.CheckSignatureECDSA(dataSignature.Message, *rvSignature, dataSignature.Pubkey)
 	if err != nil {
 		log.Error("Failed to check signature", "error", err)
 		return err
@@ -254,26 +273,175 @@ func (h *RpcService) CheckSignature(_ *http.Request, args *types.RpcDataArgs, re
 	return nil
 }
 
-// InitRouter initializes and starts the HTTP RPC server with timeout middleware.
-// It registers the RPC service and starts listening on the configured port.
-func InitRouter(config *types.AppConfig, pm *peer.P2PManager, storeDB store.HandlerData, selfService *SelfService) error {
-	log.Info("init router rpc", "port", config.RPC)
+// dkgReply describes the key created by a DKG under hash.
+func dkgReply(hash string, result *dkg.Result) *pb.DkgReply {
+	pubkey := crypto.CompressPubkey(result.PublicKey.ToPubKey())
+	return &pb.DkgReply{
+		X:       hex.EncodeToString(result.PublicKey.GetX().Bytes()),
+		Y:       hex.EncodeToString(result.PublicKey.GetY().Bytes()),
+		Address: crypto.PubkeyToAddress(*result.PublicKey.ToPubKey()).String(),
+		Pubkey:  hex.EncodeToString(pubkey),
+		Hash:    hash,
+	}
+}
+
+// NewRouter returns the HTTP handler of the node APIs: JSON-RPC on /tss, the
+// progress of jobs over WebSocket, the ledger export, the status of the node
+// and the REST gateway of TssService on /v1/, all authenticating and
+// authorizing their callers, and the /healthz and /readyz probes and the
+// /metrics of Prometheus, which do not.
+func NewRouter(config *types.AppConfig, pm *peer.P2PManager, storeDB store.HandlerData, selfService *SelfService) (http.Handler, error) {
+	api, err := newNodeAPI(config, pm, storeDB, selfService)
+	if err != nil {
+		return nil, err
+	}
+	return api.router()
+}
+
+func (a *nodeAPI) router() (http.Handler, error) {
 	rpcServer := rpc.NewServer()
-	rpcServer.RegisterCodec(rpcjson.NewCodec(), "application/json")
+	rpcServer.RegisterCodec(discoverCodec{rpcjson.NewCustomCodecWithErrorMapper(rpc.DefaultEncoderSelector, rpcError)}, "application/json")
 
 	err := rpcServer.RegisterService(&RpcService{
-		pm:          pm,
-		config:      config,
-		selfService: selfService,
-		tssCaller:   &TssCaller{StoreDB: storeDB},
+		pm:          a.pm,
+		config:      a.config,
+		selfService: a.selfService,
+		tssCaller:   a.tssCaller,
+		authz:       a.authz,
+		jobs:        a.jobs,
+		ops:         a.operations(),
 	}, "signer")
 	if err != nil {
-		log.Crit("start service service failed", "err", err)
+		return nil, fmt.Errorf("register signer service: %w", err)
+	}
+	err = rpcServer.RegisterService(&AdminService{
+		pm:           a.pm,
+		tssCaller:    a.tssCaller,
+		backups:      a.backups,
+		exposeShares: a.config.Admin.ExposeShares,
+		authz:        a.authz,
+	}, "admin")
+	if err != nil {
+		return nil, fmt.Errorf("register admin service: %w", err)
+	}
+	document, err := apiOpenRPCDocument()
+	if err != nil {
+		return nil, fmt.Errorf("openrpc document: %w", err)
+	}
+	if err := rpcServer.RegisterService(&DiscoverService{document: document}, "rpc"); err != nil {
+		return nil, fmt.Errorf("register rpc service: %w", err)
 	}
 
+	// Progress streams last as long as their jobs, and are not bounded. The
+	// ledger export is not held in a TimeoutHandler either, which would buffer
+	// it: each of its writes is bounded instead.
 	r := mux.NewRouter()
-	r.Handle("/tss", rpcServer)
+	r.Handle("/tss", a.timeouts.handler(AuthHandler(a.authenticator, rpcServer)))
+	r.Handle(progressPath, AuthHandler(a.authenticator, progressHandler(a.jobs, a.authz))).Methods(http.MethodGet)
+	r.HandleFunc("/healthz", healthHandler).Methods(http.MethodGet)
+	r.Handle("/readyz", http.TimeoutHandler(http.HandlerFunc(a.readyHandler), a.timeouts.query, "Timeout!")).Methods(http.MethodGet)
+	r.Handle("/metrics", http.TimeoutHandler(metrics.Handler(), a.timeouts.query, "Timeout!")).Methods(http.MethodGet)
+	r.Handle("/status", http.TimeoutHandler(AuthHandler(a.authenticator, a.authz.Handler(types.PermissionAdmin, http.HandlerFunc(a.statusHandler))), a.timeouts.query, "Timeout!")).Methods(http.MethodGet)
+	r.Handle("/ledger/export", AuthHandler(a.authenticator, a.authz.Handler(types.PermissionAdmin, LedgerExportHandler(a.storeDB, a.timeouts.query)))).Methods(http.MethodGet)
+
+	routes, err := tssGatewayRoutes()
+	if err != nil {
+		return nil, fmt.Errorf("gateway routes: %w", err)
+	}
+	err = registerGateway(r, a.tssServer(), routes, a.timeouts.UnaryInterceptor(), func(next http.Handler) http.Handler {
+		return AuthHandler(a.authenticator, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// InitRouter starts serving the node APIs: HTTP on config.RPC, over TLS when
+// configured, and gRPC alongside on config.GRPC when it is set, sharing the
+// jobs of the JSON-RPC API. The sessions that peers ask for are served on the
+// host of pm, when there is one. They serve in the background until Shutdown.
+func InitRouter(config *types.AppConfig, pm *peer.P2PManager, storeDB store.HandlerData, selfService *SelfService) (*APIServer, error) {
+	log.Info("init router rpc", "port", config.RPC)
+	tlsConfig, err := serverTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	api, err := newNodeAPI(config, pm, storeDB, selfService)
+	if err != nil {
+		return nil, err
+	}
+	if pm != nil {
+		rpcHost := gorpc.NewServer(pm.Host, peer.ProtocolId)
+		if err := rpcHost.Register(&TssPeerService{Pm: pm, TssCaller: api.peerCaller}); err != nil {
+			api.close()
+			return nil, fmt.Errorf("register peer service: %w", err)
+		}
+	}
+	s, err := api.listen(tlsConfig)
+	if err != nil {
+		api.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (a *nodeAPI) listen(tlsConfig *tls.Config) (*APIServer, error) {
+	handler, err := a.router()
+	if err != nil {
+		return nil, err
+	}
+	callCtx, cancelCalls := context.WithCancel(context.Background())
+	s := &APIServer{
+		api: a,
+		http: &http.Server{
+			Handler:           handler,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: orDefault(a.config.Timeouts.ReadHeader, defaultReadHeaderTimeout),
+			IdleTimeout:       orDefault(a.config.Timeouts.Idle, defaultIdleTimeout),
+			BaseContext:       func(net.Listener) context.Context { return callCtx },
+		},
+		cancelCalls: cancelCalls,
+		errs:        make(chan error, 2),
+	}
+	s.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", a.config.RPC))
+	if err != nil {
+		cancelCalls()
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	if a.config.GRPC != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.GRPC))
+		if err != nil {
+			s.listener.Close()
+			cancelCalls()
+			return nil, fmt.Errorf("listen grpc: %w", err)
+		}
+		s.grpc, err = a.grpcServer(tlsConfig)
+		if err != nil {
+			lis.Close()
+			s.listener.Close()
+			cancelCalls()
+			return nil, err
+		}
+		go func() {
+			log.Info("grpc server listening", "addr", lis.Addr())
+			if err := s.grpc.Serve(lis); err != nil {
+				s.errs <- fmt.Errorf("serve grpc: %w", err)
+			}
+		}()
+	}
 
-	muxWithMiddlewares := http.TimeoutHandler(r, time.Second*5, "Timeout!")
-	return http.ListenAndServe(fmt.Sprintf(":%d", config.RPC), muxWithMiddlewares)
+	go func() {
+		log.Info("http server listening", "addr", s.listener.Addr(), "tls", tlsConfig != nil)
+		var err error
+		if tlsConfig != nil {
+			err = s.http.ServeTLS(s.listener, "", "")
+		} else {
+			err = s.http.Serve(s.listener)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- fmt.Errorf("serve http: %w", err)
+		}
+	}()
+	return s, nil
 }
@@ -8,7 +8,7 @@ import (
 
 	"alice-tss/pb"
 	"alice-tss/peer"
-	"alice-tss/utils"
+	"alice-tss/tsserr"
 
 	"github.com/getamis/alice/crypto/tss/dkg"
 	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/signer"
@@ -34,6 +34,9 @@ const (
 	numNodes          = 3
 	peerWaitTimeout   = 30 * time.Second
 	peerCheckInterval = 1 * time.Second
+	// sessionWaitTimeout bounds how long node 0 waits for the sessions of
+	// the other nodes to end after its own.
+	sessionWaitTimeout = 30 * time.Second
 )
 
 // RegisterDKG initiates a Distributed Key Generation process across all nodes
@@ -50,6 +53,13 @@ func (s *SelfService) RegisterDKG(ctx context.Context, tssCaller *TssCaller, has
 		return nil, fmt.Errorf("failed to create peer managers: %w", err)
 	}
 
+	// Nodes 1 and 2 run their sessions in the background. Node 0 waits for
+	// them, so that every node holds its result when this returns.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var sessions sync.WaitGroup
+	helperCtx := withSessionWait(ctx, &sessions)
+
 	// Use a WaitGroup to ensure all goroutines complete before function returns
 	var wg sync.WaitGroup
 	errChan := make(chan error, numNodes-1)
@@ -60,7 +70,7 @@ func (s *SelfService) RegisterDKG(ctx context.Context, tssCaller *TssCaller, has
 		go func(nodeIndex int) {
 			defer wg.Done()
 			nodeID := fmt.Sprintf("%s-%d", hash, nodeIndex)
-			if _, err := tssCaller.RegisterDKG(pms[nodeIndex], nodeID, nil); err != nil {
+			if _, err := tssCaller.RegisterDKG(helperCtx, pms[nodeIndex], nodeID, nil); err != nil {
 				log.Error("RegisterDKG failed", "node", nodeIndex, "nodeID", nodeID, "error", err)
 				select {
 				case errChan <- fmt.Errorf("node %d DKG failed: %w", nodeIndex, err):
@@ -71,7 +81,7 @@ func (s *SelfService) RegisterDKG(ctx context.Context, tssCaller *TssCaller, has
 	}
 
 	// Start DKG on node 0 (primary node) and wait for result
-	result, err := tssCaller.RegisterDKG(pms[0], fmt.Sprintf("%s-%d", hash, 0), func() error {
+	result, err := tssCaller.RegisterDKG(ctx, pms[0], fmt.Sprintf("%s-%d", hash, 0), func(ctx context.Context) error {
 		// Wait for other nodes to complete with timeout
 		done := make(chan struct{})
 		go func() {
@@ -88,11 +98,13 @@ func (s *SelfService) RegisterDKG(ctx context.Context, tssCaller *TssCaller, has
 			return err
 		}
 	})
+	waitSessions(&sessions, cancel, err)
 
 	return result, err
 }
 
-// SignMessage performs threshold signing across all nodes
+// SignMessage performs threshold signing across all nodes, in a new session
+// initiated by node 0 whose ID it sets in dataRequestSign.
 func (s *SelfService) SignMessage(ctx context.Context, tssCaller *TssCaller, dataRequestSign *pb.SignRequest) (*signer.Result, error) {
 	if tssCaller == nil {
 		return nil, fmt.Errorf("tssCaller cannot be nil")
@@ -101,15 +113,25 @@ func (s *SelfService) SignMessage(ctx context.Context, tssCaller *TssCaller, dat
 		return nil, fmt.Errorf("dataRequestSign cannot be nil")
 	}
 	if dataRequestSign.Message == "" {
-		return nil, fmt.Errorf("message cannot be empty")
+		return nil, tsserr.New(tsserr.InvalidInput, "message cannot be empty")
 	}
 
-	hash := utils.ToHexHash([]byte(dataRequestSign.Message))
-	pms, err := s.CreatePm(ctx, hash)
+	s.mu.RLock()
+	initiator := s.peerIDs[0].String()
+	s.mu.RUnlock()
+	sessionID := newSignSession(initiator, dataRequestSign)
+	pms, err := s.CreatePm(ctx, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create peer managers: %w", err)
 	}
 
+	// Nodes 1 and 2 run their sessions in the background. Node 0 waits for
+	// them, so that every node holds its result when this returns.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var sessions sync.WaitGroup
+	helperCtx := withSessionWait(ctx, &sessions)
+
 	// Use a WaitGroup to ensure all goroutines complete before function returns
 	var wg sync.WaitGroup
 	errChan := make(chan error, numNodes-1)
@@ -120,11 +142,14 @@ func (s *SelfService) SignMessage(ctx context.Context, tssCaller *TssCaller, dat
 		go func(nodeIndex int) {
 			defer wg.Done()
 			signRequest := &pb.SignRequest{
-				Hash:    fmt.Sprintf("%s-%d", dataRequestSign.Hash, nodeIndex),
-				Pubkey:  dataRequestSign.Pubkey,
-				Message: dataRequestSign.Message,
+				Hash:      fmt.Sprintf("%s-%d", dataRequestSign.Hash, nodeIndex),
+				Pubkey:    dataRequestSign.Pubkey,
+				Message:   dataRequestSign.Message,
+				Epoch:     dataRequestSign.Epoch,
+				Initiator: initiator,
+				SessionId: sessionID,
 			}
-			if _, err := tssCaller.SignMessage(pms[nodeIndex], signRequest, nil); err != nil {
+			if _, err := tssCaller.SignMessage(helperCtx, pms[nodeIndex], signRequest, nil); err != nil {
 				log.Error("SignMessage failed", "node", nodeIndex, "hash", signRequest.Hash, "error", err)
 				select {
 				case errChan <- fmt.Errorf("node %d signing failed: %w", nodeIndex, err):
@@ -136,12 +161,14 @@ func (s *SelfService) SignMessage(ctx context.Context, tssCaller *TssCaller, dat
 
 	// Start signing on node 0 (primary node) and wait for result
 	primarySignRequest := &pb.SignRequest{
-		Hash:    fmt.Sprintf("%s-%d", dataRequestSign.Hash, 0),
-		Pubkey:  dataRequestSign.Pubkey,
-		Message: dataRequestSign.Message,
+		Hash:      fmt.Sprintf("%s-%d", dataRequestSign.Hash, 0),
+		Pubkey:    dataRequestSign.Pubkey,
+		Message:   dataRequestSign.Message,
+		Epoch:     dataRequestSign.Epoch,
+		SessionId: sessionID,
 	}
 
-	result, err := tssCaller.SignMessage(pms[0], primarySignRequest, func() error {
+	result, err := tssCaller.SignMessage(ctx, pms[0], primarySignRequest, func(ctx context.Context) error {
 		// Wait for other nodes to complete with timeout
 		done := make(chan struct{})
 		go func() {
@@ -158,10 +185,31 @@ func (s *SelfService) SignMessage(ctx context.Context, tssCaller *TssCaller, dat
 			return err
 		}
 	})
+	waitSessions(&sessions, cancel, err)
 
 	return result, err
 }
 
+// waitSessions waits for the sessions of nodes 1 and 2 to save their result,
+// once that of node 0 succeeded. They are cancelled when it failed, or when
+// they outlast sessionWaitTimeout.
+func waitSessions(sessions *sync.WaitGroup, cancel context.CancelFunc, err error) {
+	defer cancel()
+	if err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(sessionWaitTimeout):
+		log.Warn("Cancelling the sessions of the other nodes", "after", sessionWaitTimeout)
+	}
+}
+
 // CreatePm creates peer managers for all nodes with proper error handling and timeout
 func (s *SelfService) CreatePm(ctx context.Context, protocolID string) ([3]*peer.P2PManager, error) {
 	if protocolID == "" {
@@ -267,6 +315,17 @@ func NewSelfService() (*SelfService, error) {
 	return service, nil
 }
 
+// PeerIDs returns the peer IDs of the nodes.
+func (s *SelfService) PeerIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, numNodes)
+	for _, id := range s.peerIDs {
+		ids = append(ids, id.String())
+	}
+	return ids
+}
+
 // Close gracefully shuts down the SelfService and releases resources
 func (s *SelfService) Close() error {
 	s.mu.Lock()
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getamis/sirius/log"
+	"google.golang.org/grpc"
+)
+
+// abortTimeout bounds how long a stopping node waits for the calls and
+// sessions it cancelled to return.
+const abortTimeout = 5 * time.Second
+
+// ErrShuttingDown is returned for the work submitted while the node stops.
+var ErrShuttingDown = errors.New("node is shutting down")
+
+// sessionGroup runs sessions in the background, so that a stopping node can
+// wait for them, and cancel those that outlast its shutdown timeout.
+type sessionGroup struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	next     uint64
+	cancels  map[uint64]context.CancelFunc
+	stopping bool
+}
+
+// Go runs session in the background with a context derived from ctx, which
+// Drain cancels. Once Drain is called, it refuses new sessions.
+func (g *sessionGroup) Go(ctx context.Context, session func(ctx context.Context)) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		return ErrShuttingDown
+	}
+	if g.cancels == nil {
+		g.cancels = make(map[uint64]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	id := g.next
+	g.next++
+	g.cancels[id] = cancel
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			delete(g.cancels, id)
+			g.mu.Unlock()
+			cancel()
+		}()
+		session(ctx)
+	}()
+	return nil
+}
+
+// Stopping reports whether Drain was called.
+func (g *sessionGroup) Stopping() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stopping
+}
+
+// Drain refuses new sessions and waits for the running ones until ctx is
+// done. It then cancels those still running, and waits abortTimeout more for
+// them to return. It fails when sessions had to be cancelled.
+func (g *sessionGroup) Drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.stopping = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	n := len(g.cancels)
+	for _, cancel := range g.cancels {
+		cancel()
+	}
+	g.mu.Unlock()
+	log.Warn("Cancelling sessions", "count", n)
+	select {
+	case <-done:
+		return fmt.Errorf("cancelled %d sessions: %w", n, ctx.Err())
+	case <-time.After(abortTimeout):
+		return fmt.Errorf("%d sessions still running after cancellation", n)
+	}
+}
+
+// APIServer serves the HTTP and gRPC APIs of a node, until Shutdown.
+type APIServer struct {
+	api      *nodeAPI
+	listener net.Listener
+	http     *http.Server
+	grpc     *grpc.Server
+	// cancelCalls cancels the context of the HTTP calls.
+	cancelCalls context.CancelFunc
+	errs        chan error
+}
+
+// Addr returns the address of the HTTP server.
+func (s *APIServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Err reports the servers that stopped serving on their own.
+func (s *APIServer) Err() <-chan error {
+	return s.errs
+}
+
+// Shutdown stops the APIs gracefully. The servers stop accepting calls, and
+// no job nor peer session starts; the calls, jobs and sessions in flight are
+// waited for until ctx is done, then cancelled. Pending webhooks stay in the store for the
+// next run.
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	log.Info("Shutting down the APIs")
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	stop := func(name string, stop func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stop(ctx); err != nil {
+				log.Warn("Unclean shutdown", "of", name, "err", err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	stop("http", s.shutdownHTTP)
+	if s.grpc != nil {
+		stop("grpc", s.shutdownGRPC)
+	}
+	stop("jobs", s.api.jobs.Drain)
+	stop("peer sessions", s.api.peerCaller.Drain)
+	stop("backups", s.api.backups.Wait)
+	wg.Wait()
+	// The calls and jobs over, what is left are the sessions they ran in
+	// the background, such as those of the other nodes of the self service.
+	stop("sessions", s.api.tssCaller.Drain)
+	wg.Wait()
+
+	s.api.close()
+	log.Info("APIs shut down")
+	return errors.Join(errs...)
+}
+
+func (s *APIServer) shutdownHTTP(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+	s.cancelCalls()
+	abortCtx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	if abortErr := s.http.Shutdown(abortCtx); errors.Is(abortErr, context.DeadlineExceeded) {
+		_ = s.http.Close()
+		return errors.New("calls still running after cancellation")
+	}
+	return fmt.Errorf("cancelled calls: %w", err)
+}
+
+func (s *APIServer) shutdownGRPC(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		// Stop cancels the calls and streams still running.
+		s.grpc.Stop()
+		<-stopped
+		return fmt.Errorf("cancelled calls: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultQueryTimeout      = 5 * time.Second
+	defaultSessionTimeout    = 2 * time.Minute
+	defaultAdminTimeout      = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// sessionMethods are the methods that run, or start, a protocol session.
+var sessionMethods = map[string]bool{
+	"SignMessage":     true,
+	"SelfSignMessage": true,
+	"RegisterDKG":     true,
+	"RegisterSelfDKG": true,
+	"Reshare":         true,
+}
+
+// ShutdownTimeout returns how long a node stopping with config waits for the
+// work in flight.
+func ShutdownTimeout(config types.TimeoutConfig) time.Duration {
+	return orDefault(config.Shutdown, defaultShutdownTimeout)
+}
+
+func orDefault(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// callTimeouts bounds the API calls by class: queries, calls running
+// protocol sessions, and admin calls.
+type callTimeouts struct {
+	query   time.Duration
+	session time.Duration
+	admin   time.Duration
+}
+
+func newCallTimeouts(config types.TimeoutConfig) callTimeouts {
+	return callTimeouts{
+		query:   orDefault(config.Query, defaultQueryTimeout),
+		session: orDefault(config.Session, defaultSessionTimeout),
+		admin:   orDefault(config.Admin, defaultAdminTimeout),
+	}
+}
+
+// of returns the timeout of method, named service.Method in JSON-RPC and
+// /package.Service/Method in gRPC.
+func (t callTimeouts) of(method string) time.Duration {
+	method = strings.TrimPrefix(method, "/")
+	service, name := "", method
+	if i := strings.LastIndexAny(method, "./"); i >= 0 {
+		service, name = method[:i], method[i+1:]
+	}
+	switch {
+	case service == "admin" || strings.HasSuffix(service, ".AdminService"):
+		return t.admin
+	case sessionMethods[name]:
+		return t.session
+	default:
+		return t.query
+	}
+}
+
+// handler bounds the JSON-RPC calls served by next by the timeout of their
+// method. Calls past their timeout are answered 503 with a TIMEOUT JSON-RPC
+// error, and their context is cancelled.
+func (t callTimeouts) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var call struct {
+			Method string          `json:"method"`
+			ID     json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(body, &call)
+		timeout := t.of(call.Method)
+		reply := jsonRPCError(call.ID, tsserr.New(tsserr.Timeout, "%s timed out after %s", call.Method, timeout).
+			With("method", call.Method).With("timeout", timeout.String()))
+		// Replies in time replace this header with their own.
+		w.Header().Set("Content-Type", "application/json")
+		http.TimeoutHandler(next, timeout, string(reply)).ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor bounds unary gRPC calls, and the REST calls of the
+// gateway, by the timeout of their method.
+func (t callTimeouts) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, t.of(info.FullMethod))
+		defer cancel()
+		reply, err := handler(ctx, req)
+		if err != nil && status.Code(err) == codes.Unknown && ctx.Err() == context.DeadlineExceeded {
+			return nil, tsserr.New(tsserr.Timeout, "%w", err).With("method", info.FullMethod)
+		}
+		return reply, err
+	}
+}
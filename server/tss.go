@@ -1,52 +1,202 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
 	"alice-tss/pb"
 	"alice-tss/peer"
 	tssService "alice-tss/service"
 	"alice-tss/store"
+	"alice-tss/tsserr"
 	"alice-tss/types"
+	"alice-tss/utils"
 
 	"github.com/getamis/alice/crypto/tss/dkg"
 	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/signer"
 	"github.com/getamis/sirius/log"
+	"github.com/golang/protobuf/proto"
 )
 
+// ErrKeyState is returned when the lifecycle state of a key forbids an operation.
+var ErrKeyState = errors.New("operation not allowed in key state")
+
+// ErrEpochMismatch is returned when a request names a share epoch that is not
+// the current one of the key.
+var ErrEpochMismatch = errors.New("share epoch mismatch")
+
+// keyStateError reports an operation that the state of the key hash forbids as
+// INVALID_KEY_STATE, wrapping ErrKeyState.
+func keyStateError(hash string, state types.KeyState, format string, args ...interface{}) error {
+	return tsserr.New(tsserr.InvalidKeyState, "%w: %s", ErrKeyState, fmt.Sprintf(format, args...)).
+		With("hash", hash).With("state", string(state))
+}
+
 // TssCaller handles TSS (Threshold Signature Scheme) operations including
 // DKG, signing, and resharing across peer-to-peer networks.
 type TssCaller struct {
 	StoreDB store.HandlerData
+	// sessions are those run for other initiators.
+	sessions sessionGroup
+	active   activeSessions
+}
+
+type sessionWaitKey struct{}
+
+// withSessionWait returns a copy of ctx whose background sessions are added
+// to wg, so that the caller can wait for them to end.
+func withSessionWait(ctx context.Context, wg *sync.WaitGroup) context.Context {
+	return context.WithValue(ctx, sessionWaitKey{}, wg)
+}
+
+// goSession runs process, the session of kind on hash of another initiator,
+// in the background.
+func (t *TssCaller) goSession(ctx context.Context, kind types.JobKind, hash string, process func(ctx context.Context) error) error {
+	wg, _ := ctx.Value(sessionWaitKey{}).(*sync.WaitGroup)
+	if wg != nil {
+		wg.Add(1)
+	}
+	err := t.sessions.Go(ctx, func(ctx context.Context) {
+		if wg != nil {
+			defer wg.Done()
+		}
+		defer t.active.add(types.SessionStatus{Kind: kind, KeyHash: hash})()
+		_ = process(tssService.WithObserver(ctx, nil))
+	})
+	if err != nil && wg != nil {
+		wg.Done()
+	}
+	return err
+}
+
+// Sessions returns the protocol sessions running on this node.
+func (t *TssCaller) Sessions() []types.SessionStatus {
+	return t.active.list()
+}
+
+// Drain refuses the sessions of other initiators, and waits for those
+// running until ctx is done. Those still running are then cancelled.
+func (t *TssCaller) Drain(ctx context.Context) error {
+	return t.sessions.Drain(ctx)
+}
+
+// newSignSession makes initiator the initiator of a new signing session of
+// signRequest, and returns the ID of the session. Signing the same message
+// twice makes two sessions, with their own streams and signatures.
+func newSignSession(initiator string, signRequest *pb.SignRequest) string {
+	signRequest.Initiator = initiator
+	signRequest.SessionId = utils.SessionID(initiator, signRequest.Hash, utils.RandomHash())
+	return signRequest.SessionId
 }
 
 // SignMessage performs threshold signature generation for a given message using ECDSA.
-func (t *TssCaller) SignMessage(pm *peer.P2PManager, signRequest *pb.SignRequest, call2peer func() error) (*signer.Result, error) {
-	log.Info("SignMessage", "hash", signRequest.Hash, "pubkey", signRequest.Pubkey)
+// The initiator, with call2peer, waits for the session and reports its state
+// changes to the observer of ctx; other holders sign in the background.
+// Cancelling ctx stops the session on this node, also while call2peer is still
+// waiting for the peers. pm speaks the protocol of the
+// session of signRequest, which the initiator starts with newSignSession.
+func (t *TssCaller) SignMessage(ctx context.Context, pm *peer.P2PManager, signRequest *pb.SignRequest, call2peer func(ctx context.Context) error) (_ *signer.Result, err error) {
+	log.Info("SignMessage", "hash", signRequest.Hash, "pubkey", signRequest.Pubkey, "epoch", signRequest.Epoch)
 
 	signerCfg, err := t.StoreDB.GetSignerConfig(signRequest.Hash, signRequest.Pubkey)
 	if err != nil {
 		log.Error("GetSignerConfig", "err", err)
 		return nil, err
 	}
+	if err := checkKeyState(signRequest.Hash, signerCfg.State, types.KeyOperationSign); err != nil {
+		log.Error("SignMessage", "hash", signRequest.Hash, "err", err)
+		return nil, err
+	}
+	if err := checkEpoch(signRequest.Hash, &signRequest.Epoch, signerCfg.Epoch); err != nil {
+		log.Error("SignMessage", "hash", signRequest.Hash, "err", err)
+		return nil, err
+	}
 
-	service, err := tssService.NewSignerService(signerCfg, pm, t.StoreDB, signRequest.Message)
+	if call2peer != nil {
+		signRequest.Initiator = pm.SelfID()
+	}
+
+	service, err := tssService.NewSignerService(signerCfg, pm, t.StoreDB, signRequest)
 	if err != nil {
 		log.Error("NewSignerService", "err", err)
 		return nil, err
 	}
+	// Once it runs, the session releases its stream handler and share itself;
+	// until then, they are released here.
+	started := false
+	defer func() {
+		if !started {
+			service.Abort(err)
+		}
+	}()
 	if call2peer != nil {
-		if err := call2peer(); err != nil {
+		defer t.active.add(types.SessionStatus{Kind: types.JobKindSign, KeyHash: signRequest.Hash, Initiator: true})()
+		if err := call2peer(ctx); err != nil {
+			return nil, err
+		}
+		started = true
+		if err := service.Process(ctx); err != nil {
 			return nil, err
 		}
-		service.Process()
 		return service.GetResult()
-	} else {
-		go service.Process()
 	}
-
+	if err := t.goSession(ctx, types.JobKindSign, signRequest.Hash, service.Process); err != nil {
+		return nil, err
+	}
+	started = true
 	return nil, nil
 }
 
-// GetSignerConfig retrieves the signer configuration for a given hash and public key.
+// GetSignature returns the signature of message made by the signing session
+// sessionID or, without one, the latest signature of message under pubkey
+// this node took part in.
+func (t *TssCaller) GetSignature(message, pubkey, sessionID string) (*types.RVSignature, error) {
+	if sessionID != "" {
+		return t.StoreDB.GetSignerResultData(sessionID)
+	}
+	digest := utils.ToHexHash([]byte(message))
+	var latest *types.LedgerEntry
+	err := t.StoreDB.ScanLedger(types.LedgerQuery{Digest: digest}, func(entry *types.LedgerEntry) error {
+		if entry.Outcome == types.LedgerOutcomeSigned && entry.Pubkey == pubkey {
+			latest = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		// Sessions of older builds stored their signature under the digest.
+		return t.StoreDB.GetSignerResultData(digest)
+	}
+	return &types.RVSignature{R: latest.R, S: latest.S, Hash: digest, KeyHash: latest.KeyHash}, nil
+}
+
+// signatureKey returns the key hash that reading signature is authorized
+// against. Signatures of older builds name no key, and need every key.
+func signatureKey(signature *types.RVSignature) string {
+	if signature.KeyHash == "" {
+		return types.AllKeys
+	}
+	return signature.KeyHash
+}
+
+// GetKeyView returns the public view of the key hash, provided it belongs to
+// pubkey. It never opens the share.
+func (t *TssCaller) GetKeyView(hash, pubkey string) (*types.KeyView, error) {
+	record, err := t.keyRecord(hash, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	return record.View(hash), nil
+}
+
+// GetSignerConfig retrieves the signer configuration, with the decrypted
+// share, for a given hash and public key.
 func (t *TssCaller) GetSignerConfig(signRequest *pb.SignRequest) (*types.SignerConfig, error) {
 	signerCfg, err := t.StoreDB.GetSignerConfig(signRequest.Hash, signRequest.Pubkey)
 	if err != nil {
@@ -58,38 +208,218 @@ func (t *TssCaller) GetSignerConfig(signRequest *pb.SignRequest) (*types.SignerC
 }
 
 // Reshare initiates a key resharing process to refresh threshold shares while maintaining the same public key.
-func (t *TssCaller) Reshare(pm *peer.P2PManager, reshareRequest *pb.ReshareRequest, call2peer func() error) error {
+// Like SignMessage, the initiator waits for the outcome of the session.
+func (t *TssCaller) Reshare(ctx context.Context, pm *peer.P2PManager, reshareRequest *pb.ReshareRequest, call2peer func(ctx context.Context) error) (err error) {
 	signerCfg, err := t.StoreDB.GetSignerConfig(reshareRequest.Hash, reshareRequest.Pubkey)
 	if err != nil {
 		log.Error("GetSignerConfig", "err", err)
 		return err
 	}
+	if err := checkKeyState(reshareRequest.Hash, signerCfg.State, types.KeyOperationReshare); err != nil {
+		log.Error("Reshare", "hash", reshareRequest.Hash, "err", err)
+		return err
+	}
+	if err := checkEpoch(reshareRequest.Hash, &reshareRequest.Epoch, signerCfg.Epoch); err != nil {
+		log.Error("Reshare", "hash", reshareRequest.Hash, "err", err)
+		return err
+	}
+	// The initiator numbers the new epoch, so that holders agree on it
+	// whatever epochs they kept from earlier rollbacks.
+	if call2peer != nil {
+		reshareRequest.TargetEpoch = reshareRequest.Epoch + 1
+	} else if reshareRequest.TargetEpoch <= reshareRequest.Epoch {
+		return tsserr.New(tsserr.InvalidInput, "reshare of %s to epoch %d from epoch %d", reshareRequest.Hash, reshareRequest.TargetEpoch, reshareRequest.Epoch)
+	}
 
 	service, err := tssService.NewReshareService(&types.ReshareConfig{
 		Threshold: 2,
 		Share:     signerCfg.Share,
 		Pubkey:    signerCfg.Pubkey,
 		BKs:       signerCfg.BKs,
+		Epoch:     reshareRequest.TargetEpoch,
 	}, pm, reshareRequest.Hash, t.StoreDB)
 	if err != nil {
 		log.Error("NewReshareService", "err", err)
 		return err
 	}
+	started := false
+	defer func() {
+		if !started {
+			service.Abort(err)
+		}
+	}()
 
 	if call2peer != nil {
-		if err := call2peer(); err != nil {
+		defer t.active.add(types.SessionStatus{Kind: types.JobKindReshare, KeyHash: reshareRequest.Hash, Initiator: true})()
+		if err := call2peer(ctx); err != nil {
 			log.Error("NewReshareService", "err", err)
 			return err
 		}
+		started = true
+		return service.Process(ctx)
 	}
 
-	go service.Process()
+	if err := t.goSession(ctx, types.JobKindReshare, reshareRequest.Hash, service.Process); err != nil {
+		return err
+	}
+	started = true
+	return nil
+}
+
+// RollbackEpoch makes a previous share epoch of a key current again, on this
+// node and on every other holder of the key. All holders are first asked to
+// confirm that they still hold the epoch; nobody switches unless all agree.
+func (t *TssCaller) RollbackEpoch(ctx context.Context, pm *peer.P2PManager, rollbackRequest *pb.RollbackRequest) error {
+	signerCfg, err := t.StoreDB.GetSignerConfig(rollbackRequest.Hash, rollbackRequest.Pubkey)
+	if err != nil {
+		log.Error("GetSignerConfig", "err", err)
+		return err
+	}
 
+	var holders []string
+	for peerID := range signerCfg.BKs {
+		if peerID != pm.SelfID() {
+			holders = append(holders, peerID)
+		}
+	}
+	bs, err := proto.Marshal(rollbackRequest)
+	if err != nil {
+		log.Warn("Cannot proto marshal message", "err", err)
+		return err
+	}
+
+	if err := t.PrepareRollback(rollbackRequest); err != nil {
+		return err
+	}
+	if err := CallPeers(ctx, pm, holders, "TssPeerService", "PrepareRollback", bs); err != nil {
+		log.Error("Rollback refused", "hash", rollbackRequest.Hash, "epoch", rollbackRequest.Epoch, "err", err)
+		return fmt.Errorf("rollback refused: %w", err)
+	}
+	if err := CallPeers(ctx, pm, holders, "TssPeerService", "CommitRollback", bs); err != nil {
+		log.Error("Rollback commit failed", "hash", rollbackRequest.Hash, "epoch", rollbackRequest.Epoch, "err", err)
+		return fmt.Errorf("rollback commit failed, retry to converge: %w", err)
+	}
+	return t.CommitRollback(rollbackRequest)
+}
+
+// PrepareRollback checks that this node can roll a key back to the requested epoch.
+func (t *TssCaller) PrepareRollback(rollbackRequest *pb.RollbackRequest) error {
+	signerCfg, err := t.StoreDB.GetSignerConfig(rollbackRequest.Hash, rollbackRequest.Pubkey)
+	if err != nil {
+		log.Error("GetSignerConfig", "err", err)
+		return err
+	}
+	if err := checkKeyState(rollbackRequest.Hash, signerCfg.State, types.KeyOperationRollback); err != nil {
+		return err
+	}
+	epochs, err := t.StoreDB.GetShareEpochs(rollbackRequest.Hash)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(epochs, rollbackRequest.Epoch) {
+		return tsserr.New(tsserr.EpochMismatch, "share epoch %d of %s is not held", rollbackRequest.Epoch, rollbackRequest.Hash).
+			With("hash", rollbackRequest.Hash).With("epoch", strconv.FormatUint(uint64(signerCfg.Epoch), 10))
+	}
+	return nil
+}
+
+// CommitRollback switches the key to the requested epoch on this node.
+func (t *TssCaller) CommitRollback(rollbackRequest *pb.RollbackRequest) error {
+	if err := t.PrepareRollback(rollbackRequest); err != nil {
+		return err
+	}
+	return t.StoreDB.RollbackShareEpoch(rollbackRequest.Hash, rollbackRequest.Epoch)
+}
+
+// SetKeyState moves a key to another lifecycle state on this node and on every
+// other holder of the key, in two phases like RollbackEpoch: nobody changes
+// state unless every holder accepts the transition.
+func (t *TssCaller) SetKeyState(ctx context.Context, pm *peer.P2PManager, keyStateRequest *pb.KeyStateRequest) error {
+	record, err := t.keyRecord(keyStateRequest.Hash, keyStateRequest.Pubkey)
+	if err != nil {
+		return err
+	}
+
+	var holders []string
+	for peerID := range record.BKs {
+		if peerID != pm.SelfID() {
+			holders = append(holders, peerID)
+		}
+	}
+	bs, err := proto.Marshal(keyStateRequest)
+	if err != nil {
+		log.Warn("Cannot proto marshal message", "err", err)
+		return err
+	}
+
+	if err := t.PrepareKeyState(keyStateRequest); err != nil {
+		return err
+	}
+	if err := CallPeers(ctx, pm, holders, "TssPeerService", "PrepareKeyState", bs); err != nil {
+		log.Error("Key state change refused", "hash", keyStateRequest.Hash, "state", keyStateRequest.State, "err", err)
+		return fmt.Errorf("key state change refused: %w", err)
+	}
+	if err := CallPeers(ctx, pm, holders, "TssPeerService", "CommitKeyState", bs); err != nil {
+		log.Error("Key state commit failed", "hash", keyStateRequest.Hash, "state", keyStateRequest.State, "err", err)
+		return fmt.Errorf("key state commit failed, retry to converge: %w", err)
+	}
+	return t.CommitKeyState(keyStateRequest)
+}
+
+// PrepareKeyState checks that this node can move a key to the requested state.
+func (t *TssCaller) PrepareKeyState(keyStateRequest *pb.KeyStateRequest) error {
+	record, err := t.keyRecord(keyStateRequest.Hash, keyStateRequest.Pubkey)
+	if err != nil {
+		return err
+	}
+	state := types.KeyState(keyStateRequest.State)
+	if !record.State.CanBecome(state) {
+		return keyStateError(keyStateRequest.Hash, record.State, "%s cannot go from %s to %q", keyStateRequest.Hash, record.State, state)
+	}
+	return nil
+}
+
+// CommitKeyState moves the key to the requested state on this node.
+func (t *TssCaller) CommitKeyState(keyStateRequest *pb.KeyStateRequest) error {
+	if err := t.PrepareKeyState(keyStateRequest); err != nil {
+		return err
+	}
+	return t.StoreDB.SetKeyState(keyStateRequest.Hash, types.KeyState(keyStateRequest.State))
+}
+
+// checkHolder returns an error unless peerID holds a share of the key hash.
+func (t *TssCaller) checkHolder(hash, peerID string) error {
+	record, err := t.StoreDB.GetDKGResultData(hash)
+	if err != nil {
+		log.Error("GetDKGResultData", "err", err)
+		return err
+	}
+	if _, ok := record.BKs[peerID]; !ok {
+		log.Warn("Refused a peer that does not hold the key", "hash", hash, "peer", peerID)
+		return tsserr.New(tsserr.PermissionDenied, "peer %s does not hold key %s", peerID, hash).
+			With("hash", hash).With("peer", peerID)
+	}
 	return nil
 }
 
+// keyRecord returns the DKG record of hash if it belongs to pubkey. Unlike
+// GetSignerConfig it does not open the share, so it works for destroyed keys.
+func (t *TssCaller) keyRecord(hash, pubkey string) (*types.DKGResult, error) {
+	record, err := t.StoreDB.GetDKGResultData(hash)
+	if err != nil {
+		log.Error("GetDKGResultData", "err", err)
+		return nil, err
+	}
+	if record.PublicKey != pubkey {
+		return nil, tsserr.New(tsserr.PubkeyMismatch, "pubkey %s does not match key %s", pubkey, hash).
+			With("hash", hash).With("pubkey", pubkey)
+	}
+	return record, nil
+}
+
 // RegisterDKG initiates a Distributed Key Generation process to create shared public/private key pairs.
-func (t *TssCaller) RegisterDKG(pm *peer.P2PManager, hash string, call2peer func() error) (*dkg.Result, error) {
+// Like SignMessage, the initiator waits for the outcome of the session.
+func (t *TssCaller) RegisterDKG(ctx context.Context, pm *peer.P2PManager, hash string, call2peer func(ctx context.Context) error) (_ *dkg.Result, err error) {
 	cfg := &types.DKGConfig{
 		Rank:      0,
 		Threshold: pm.NumPeers(),
@@ -101,16 +431,51 @@ func (t *TssCaller) RegisterDKG(pm *peer.P2PManager, hash string, call2peer func
 		log.Error("NewDkgService", "err", err)
 		return nil, err
 	}
+	started := false
+	defer func() {
+		if !started {
+			service.Abort(err)
+		}
+	}()
 
 	if call2peer != nil {
-		if err := call2peer(); err != nil {
+		defer t.active.add(types.SessionStatus{Kind: types.JobKindDKG, KeyHash: hash, Initiator: true})()
+		if err := call2peer(ctx); err != nil {
+			return nil, err
+		}
+		started = true
+		if err := service.Process(ctx); err != nil {
 			return nil, err
 		}
-		service.Process()
 		return service.GetResult()
-	} else {
-		go service.Process()
 	}
-
+	if err := t.goSession(ctx, types.JobKindDKG, hash, service.Process); err != nil {
+		return nil, err
+	}
+	started = true
 	return nil, nil
 }
+
+// checkKeyState refuses to run an operation that the lifecycle state of a key
+// does not allow.
+func checkKeyState(hash string, state types.KeyState, op types.KeyOperation) error {
+	if !state.Allows(op) {
+		return keyStateError(hash, state, "%s is %s, cannot %s", hash, state, op)
+	}
+	return nil
+}
+
+// checkEpoch fills in the current share epoch when a request names none, and
+// otherwise refuses to run a protocol on a share of another epoch of the key
+// hash.
+func checkEpoch(hash string, requested *uint32, current uint32) error {
+	if *requested == 0 {
+		*requested = current
+		return nil
+	}
+	if *requested != current {
+		return tsserr.New(tsserr.EpochMismatch, "%w: requested %d, holding %d", ErrEpochMismatch, *requested, current).
+			With("hash", hash).With("epoch", strconv.FormatUint(uint64(current), 10))
+	}
+	return nil
+}
@@ -3,8 +3,11 @@ package server
 import (
 	"alice-tss/pb"
 	"alice-tss/peer"
+	"alice-tss/tsserr"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/getamis/sirius/log"
@@ -12,9 +15,12 @@ import (
 )
 
 type TssRequest interface {
-	pb.SignRequest | pb.ReshareRequest | pb.DKGRequest
+	pb.SignRequest | pb.ReshareRequest | pb.DKGRequest | pb.RollbackRequest | pb.KeyStateRequest
 }
 
+// errInvalidMessage is returned for requests that are neither JSON nor proto.
+var errInvalidMessage = tsserr.New(tsserr.InvalidInput, "invalid message, cannot unmarshal")
+
 func UnmarshalRequest[T TssRequest](data []byte, request *T) error {
 	err := json.Unmarshal(data, request)
 	if err != nil {
@@ -22,27 +28,26 @@ func UnmarshalRequest[T TssRequest](data []byte, request *T) error {
 		errProto := proto.Unmarshal(data, any(request).(proto.Message))
 		if errProto != nil {
 			log.Warn("invalid proto message")
-			return errors.New("invalid message, cannot unmarshal")
+			return errInvalidMessage
 		} else {
 			err = nil
 		}
 	}
 
 	if err != nil {
-		return errors.New("invalid message, cannot unmarshal")
+		return errInvalidMessage
 	}
 	return nil
 }
 
-func RpcToPeer(pm *peer.P2PManager, svcName, svcMethod string, data []byte) func() error {
-	return func() error {
+func RpcToPeer(pm *peer.P2PManager, svcName, svcMethod string, data []byte) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
 		var wg sync.WaitGroup
 
-		for _, peerId := range pm.PeerIDs() {
+		for _, peerAddrTarget := range pm.Peers() {
 			wg.Add(1)
-			peerAddrTarget := pm.Peers()[peerId]
 			log.Debug("Sending message to peer", "target", peerAddrTarget)
-			peerReply, err := SendToPeer(pm.Host, PeerArgs{
+			peerReply, err := SendToPeer(ctx, pm.Host, PeerArgs{
 				peerAddrTarget,
 				svcName,
 				svcMethod,
@@ -62,3 +67,52 @@ func RpcToPeer(pm *peer.P2PManager, svcName, svcMethod string, data []byte) func
 		return nil
 	}
 }
+
+// RequestToPeer is like RpcToPeer, but marshals request only when the call is
+// made, so fields that TssCaller fills in (such as the share epoch) reach the
+// peers.
+func RequestToPeer(pm *peer.P2PManager, svcName, svcMethod string, request proto.Message) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		data, err := proto.Marshal(request)
+		if err != nil {
+			log.Warn("Cannot proto marshal message", "err", err)
+			return err
+		}
+		return RpcToPeer(pm, svcName, svcMethod, data)(ctx)
+	}
+}
+
+// CallPeers calls svcMethod once on each of peerIDs in parallel. Unlike
+// RpcToPeer it never retries, so that a peer refusing the call is reported
+// instead of being asked again forever.
+func CallPeers(ctx context.Context, pm *peer.P2PManager, peerIDs []string, svcName, svcMethod string, data []byte) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(peerIDs))
+	peers := pm.Peers()
+
+	for i, peerID := range peerIDs {
+		peerAddrTarget, ok := peers[peerID]
+		if !ok {
+			errs[i] = tsserr.New(tsserr.PeerUnreachable, "peer %s is not connected", peerID).With("peer", peerID)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, peerID, peerAddrTarget string) {
+			defer wg.Done()
+			_, err := MsgToPeer(ctx, pm.Host, PeerArgs{
+				peerAddrTarget,
+				svcName,
+				svcMethod,
+				PingArgs{
+					Data: data,
+				},
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("peer %s: %w", peerID, err)
+			}
+		}(i, peerID, peerAddrTarget)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
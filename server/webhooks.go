@@ -0,0 +1,388 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"alice-tss/auth"
+	"alice-tss/store"
+	"alice-tss/types"
+	"alice-tss/utils"
+
+	"github.com/getamis/sirius/log"
+)
+
+// Headers of a webhook delivery.
+const (
+	HeaderWebhookID        = "X-TSS-Webhook-Id"
+	HeaderWebhookTimestamp = "X-TSS-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-TSS-Webhook-Signature"
+)
+
+const (
+	defaultWebhookMaxAttempts  = 10
+	defaultWebhookRetryBackoff = 5 * time.Second
+	maxWebhookRetryBackoff     = time.Hour
+	webhookTimeout             = 10 * time.Second
+	// webhookIdleInterval bounds how long the dispatcher sleeps without
+	// looking at the store.
+	webhookIdleInterval = time.Minute
+)
+
+// ErrWebhooksDisabled is returned for requests naming a callback URL when no
+// webhook secret is configured to sign their callbacks.
+var ErrWebhooksDisabled = errors.New("callbacks are disabled, set webhooks.secret")
+
+// errWebhookRedirect is the error of a webhook answering with a redirect,
+// which is not followed.
+var errWebhookRedirect = errors.New("webhook redirects are not followed")
+
+// nonPublicNetworks are the networks, besides those the net package
+// classifies, that webhooks may not reach without AllowPrivateHosts: "this"
+// network and the shared address space of carrier-grade NAT, where some
+// clouds serve instance metadata.
+var nonPublicNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// publicIP reports whether ip is a public unicast address.
+func publicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicNetworks {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPublicAddress refuses to dial a non-public address. It runs once the
+// host is resolved, so that a name cannot lead to another address than the
+// one checked.
+func checkPublicAddress(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicIP(ip) {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+	return nil
+}
+
+// newWebhookClient returns the HTTP client of the deliveries. It does not
+// follow redirects, goes through no proxy and, unless allowPrivate, only
+// dials public addresses.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = checkPublicAddress
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errWebhookRedirect
+		},
+	}
+}
+
+// SignWebhook returns the X-TSS-Webhook-Signature header of a delivery: the
+// hex HMAC-SHA256, under secret, of its timestamp header, a dot and its body.
+// Receivers compute it to authenticate a callback.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDispatcher posts an event to webhooks when jobs finish. Deliveries
+// are written to the store before they are attempted, and retried with
+// exponential backoff, also after a restart, until they succeed or run out
+// of attempts.
+type webhookDispatcher struct {
+	storeDB store.HandlerData
+	config  types.WebhookConfig
+	client  *http.Client
+	now     func() time.Time
+	wake    chan struct{}
+}
+
+// newWebhookDispatcher returns the dispatcher of config, or nil when webhooks
+// are disabled.
+func newWebhookDispatcher(storeDB store.HandlerData, config types.WebhookConfig) (*webhookDispatcher, error) {
+	if config.Secret == "" && len(config.Clients) == 0 {
+		return nil, nil
+	}
+	for _, client := range config.Clients {
+		if client.Subject == "" {
+			return nil, errors.New("client webhook needs a subject")
+		}
+		if err := auth.CheckSubject(client.Subject); err != nil {
+			return nil, fmt.Errorf("client webhook: %w", err)
+		}
+		if err := checkWebhookURL(client.URL); err != nil {
+			return nil, fmt.Errorf("client webhook of %s: %w", client.Subject, err)
+		}
+		if client.Secret == "" && config.Secret == "" {
+			return nil, fmt.Errorf("client webhook of %s needs a secret", client.Subject)
+		}
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultWebhookRetryBackoff
+	}
+	// Attempts are scheduled in unix seconds.
+	config.RetryBackoff = max(config.RetryBackoff, time.Second)
+	return &webhookDispatcher{
+		storeDB: storeDB,
+		config:  config,
+		client:  newWebhookClient(config.AllowPrivateHosts),
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url %q is not http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("webhook url %q has no host", raw)
+	}
+	return nil
+}
+
+// CheckCallbackURL checks that the callback URL of a request can be called:
+// an http or https URL, on one of the allowed hosts if any, and not on a
+// non-public address unless those are allowed.
+func (w *webhookDispatcher) CheckCallbackURL(raw string) error {
+	if w == nil || w.config.Secret == "" {
+		return ErrWebhooksDisabled
+	}
+	if err := checkWebhookURL(raw); err != nil {
+		return err
+	}
+	u, _ := url.Parse(raw)
+	if len(w.config.AllowedHosts) > 0 && !slices.Contains(w.config.AllowedHosts, u.Hostname()) {
+		return fmt.Errorf("callback host %s is not allowed", u.Hostname())
+	}
+	// Names are checked once resolved, when the callback is made.
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !w.config.AllowPrivateHosts && !publicIP(ip) {
+		return fmt.Errorf("callback address %s is not public", ip)
+	}
+	return nil
+}
+
+// Enqueue stores the deliveries of a finished job, to its callback URL and to
+// the webhooks of its owner, and wakes the dispatcher up.
+func (w *webhookDispatcher) Enqueue(job *types.Job) {
+	if w == nil {
+		return
+	}
+	type target struct{ url, client string }
+	var targets []target
+	if job.CallbackURL != "" {
+		targets = append(targets, target{url: job.CallbackURL})
+	}
+	for _, client := range w.config.Clients {
+		if job.Owner != "" && client.Subject == job.Owner {
+			targets = append(targets, target{url: client.URL, client: client.Subject})
+		}
+	}
+
+	now := w.now().Unix()
+	for _, t := range targets {
+		event := types.WebhookEvent{
+			ID:    utils.RandomHash(),
+			Event: "job." + string(job.Status),
+			Job:   job,
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			log.Error("Cannot marshal webhook event", "job", job.ID, "err", err)
+			continue
+		}
+		delivery := &types.WebhookDelivery{
+			ID:            event.ID,
+			JobID:         job.ID,
+			URL:           t.url,
+			Client:        t.client,
+			Payload:       payload,
+			Status:        types.WebhookStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := w.storeDB.SaveWebhookDelivery(delivery); err != nil {
+			log.Error("Cannot save webhook delivery", "job", job.ID, "url", t.url, "err", err)
+			continue
+		}
+		log.Info("Webhook queued", "id", delivery.ID, "job", job.ID, "url", t.url)
+	}
+	if len(targets) > 0 {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers the pending deliveries as they fall due, until ctx is done.
+func (w *webhookDispatcher) Run(ctx context.Context) {
+	for {
+		wait := webhookIdleInterval
+		if next, ok := w.deliverDue(ctx); ok {
+			wait = min(wait, max(next.Sub(w.now()), 0))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// deliverDue attempts the pending deliveries that are due, and returns when
+// the next one falls due.
+func (w *webhookDispatcher) deliverDue(ctx context.Context) (time.Time, bool) {
+	deliveries, err := w.storeDB.ListWebhookDeliveries(types.WebhookStatusPending)
+	if err != nil {
+		log.Error("Cannot list webhook deliveries", "err", err)
+		return time.Time{}, false
+	}
+	var (
+		next    time.Time
+		pending bool
+	)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if delivery.NextAttemptAt <= w.now().Unix() {
+			w.attempt(ctx, delivery)
+		}
+		if delivery.Status != types.WebhookStatusPending {
+			continue
+		}
+		at := time.Unix(delivery.NextAttemptAt, 0)
+		if !pending || at.Before(next) {
+			next, pending = at, true
+		}
+	}
+	return next, pending
+}
+
+func (w *webhookDispatcher) attempt(ctx context.Context, delivery *types.WebhookDelivery) {
+	err := w.post(ctx, delivery)
+	now := w.now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now.Unix()
+	switch {
+	case err == nil:
+		delivery.Status = types.WebhookStatusDelivered
+		delivery.DeliveredAt = now.Unix()
+		delivery.LastError = ""
+		log.Info("Webhook delivered", "id", delivery.ID, "job", delivery.JobID, "url", delivery.URL)
+	case delivery.Attempts >= w.config.MaxAttempts:
+		delivery.Status = types.WebhookStatusFailed
+		delivery.LastError = err.Error()
+		log.Error("Webhook failed", "id", delivery.ID, "job", delivery.JobID, "url", delivery.URL, "attempts", delivery.Attempts, "err", err)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = ceilUnix(now.Add(w.backoff(delivery.Attempts)))
+		log.Warn("Webhook attempt failed", "id", delivery.ID, "job", delivery.JobID, "url", delivery.URL, "attempts", delivery.Attempts, "err", err)
+	}
+	if err := w.storeDB.SaveWebhookDelivery(delivery); err != nil {
+		log.Error("Cannot save webhook delivery", "id", delivery.ID, "err", err)
+	}
+}
+
+// backoff returns the delay after the attempt-th failed attempt.
+func (w *webhookDispatcher) backoff(attempt int) time.Duration {
+	delay := w.config.RetryBackoff
+	for i := 1; i < attempt && delay < maxWebhookRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookRetryBackoff)
+}
+
+// ceilUnix returns t in unix seconds, rounded up so that a retry never comes
+// early.
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() == 0 {
+		return t.Unix()
+	}
+	return t.Unix() + 1
+}
+
+// secret returns the secret that signs delivery: the one of its client
+// webhook, if it has one, or the global one.
+func (w *webhookDispatcher) secret(delivery *types.WebhookDelivery) string {
+	for _, client := range w.config.Clients {
+		if delivery.Client != "" && client.Subject == delivery.Client && client.URL == delivery.URL && client.Secret != "" {
+			return client.Secret
+		}
+	}
+	return w.config.Secret
+}
+
+func (w *webhookDispatcher) post(ctx context.Context, delivery *types.WebhookDelivery) error {
+	secret := w.secret(delivery)
+	if secret == "" {
+		return errors.New("no webhook secret")
+	}
+	timestamp := w.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, delivery.ID)
+	req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
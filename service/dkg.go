@@ -1,13 +1,16 @@
 package service
 
 import (
+	"alice-tss/metrics"
 	"alice-tss/store"
 	types2 "alice-tss/types"
+	"context"
 	"github.com/getamis/alice/crypto/tss/dkg"
 	"github.com/getamis/alice/types"
 	"github.com/getamis/sirius/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"io"
 	"strings"
 
@@ -23,6 +26,13 @@ type Dkg struct {
 
 	dkg  *dkg.DKG
 	hash string
+	// protocol is the protocol of the streams of the session.
+	protocol protocol.ID
+
+	observer sessionObserver
+	metrics  *sessionMetrics
+	// err is why the session failed, set before done is closed.
+	err error
 }
 
 func NewDkgService(config *types2.DKGConfig, pm *peer.P2PManager, hash string, storeDB store.HandlerData) (*Dkg, error) {
@@ -31,6 +41,7 @@ func NewDkgService(config *types2.DKGConfig, pm *peer.P2PManager, hash string, s
 		pm:      pm,
 		storeDB: storeDB,
 		done:    make(chan struct{}),
+		metrics: newSessionMetrics(types2.JobKindDKG),
 	}
 	log.Warn("new DKG", "config", config, "hash", hash, "port", s.getPort())
 
@@ -38,7 +49,7 @@ func NewDkgService(config *types2.DKGConfig, pm *peer.P2PManager, hash string, s
 	d, err := dkg.NewDKG(utils.GetCurve(), pm, config.Threshold, config.Rank, s)
 	if err != nil {
 		log.Warn("Cannot create a new DKG", "config", config, "err", err)
-		return nil, err
+		return nil, newSessionErr(err, pm)
 	}
 	s.dkg = d
 	s.hash = hash
@@ -47,6 +58,8 @@ func NewDkgService(config *types2.DKGConfig, pm *peer.P2PManager, hash string, s
 		streamHandlerProtocol = peer.GetProtocol(strings.Split(hash, "-")[0])
 	}
 
+	s.protocol = streamHandlerProtocol
+
 	pm.Host.SetStreamHandler(streamHandlerProtocol, func(stream network.Stream) {
 		s.Handle(stream)
 	})
@@ -80,21 +93,42 @@ func (p *Dkg) Handle(s network.Stream) {
 		return
 	}
 
+	metrics.MessageReceived(s.Conn().RemotePeer().String())
 	log.Info("Received request", "from", s.Conn().RemotePeer())
 	err = p.dkg.AddMessage(data.GetId(), data)
 	if err != nil {
 		log.Warn("Cannot add message to DKG", "err", err)
 		return
 	}
+	p.observer.messageReceived(data.GetId(), data.GetType().String())
+	p.metrics.messageAccepted(data.GetType().String())
 }
 
-func (p *Dkg) Process() {
+// Process runs the session until it is done, failed or ctx is cancelled, and
+// returns why it did not succeed.
+func (p *Dkg) Process(ctx context.Context) (err error) {
 	// 1. Start a DKG process.
+	p.observer.attach(observerFrom(ctx))
+	p.metrics.start()
+	defer func() { p.metrics.done(err) }()
 	p.dkg.Start()
 	defer p.dkg.Stop()
 
-	// 2. Wait the dkg is done or failed
-	<-p.done
+	// 2. Wait the dkg is done, failed or cancelled
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		log.Warn("Dkg cancelled", "hash", p.hash, "err", ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// Abort releases a session that will not be processed, because reason kept
+// it from starting: its stream handler.
+func (p *Dkg) Abort(reason error) {
+	log.Warn("Dkg aborted", "hash", p.hash, "err", reason)
+	p.pm.Host.RemoveStreamHandler(p.protocol)
 }
 
 func (p *Dkg) closeDone() {
@@ -103,24 +137,25 @@ func (p *Dkg) closeDone() {
 }
 
 func (p *Dkg) OnStateChanged(oldState types.MainState, newState types.MainState) {
+	p.observer.stateChanged(oldState, newState)
+	p.metrics.stateChanged(newState)
 	if newState == types.StateFailed {
 		log.Error("Dkg failed", "old", oldState.String(), "new", newState.String())
+		p.err = protocolFailed(types2.JobKindDKG, oldState)
 		close(p.done)
 		return
 	} else if newState == types.StateDone {
 		log.Info("Dkg done", "old", oldState.String(), "new", newState.String())
 		result, err := p.dkg.GetResult()
-		close(p.done)
-
 		if err == nil {
-			log.Debug("Register dkg", "result", result)
-			if err := p.storeDB.SaveDKGResultData(p.hash, result); err != nil {
+			if err = p.storeDB.SaveDKGResultData(p.hash, result); err != nil {
 				log.Error("Cannot save dkg result", "err", err)
-				return
 			}
 		} else {
 			log.Warn("Failed to get result from DKG", "err", err)
 		}
+		p.err = err
+		close(p.done)
 		return
 	}
 	log.Info("State changed", "old", oldState.String(), "new", newState.String())
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+
+	"alice-tss/peer"
+	"alice-tss/tsserr"
+	types2 "alice-tss/types"
+
+	"github.com/getamis/alice/crypto/tss"
+	"github.com/getamis/alice/crypto/utils"
+	"github.com/getamis/alice/types"
+)
+
+// newSessionErr reports the errors of alice refusing a session for the number
+// of peers of pm as INSUFFICIENT_PEERS.
+func newSessionErr(err error, pm *peer.P2PManager) error {
+	if errors.Is(err, utils.ErrSmallThreshold) || errors.Is(err, utils.ErrLargeThreshold) ||
+		errors.Is(err, tss.ErrNotEnoughBKs) || errors.Is(err, tss.ErrInconsistentPeerNumAndBks) {
+		return tsserr.New(tsserr.InsufficientPeers, "%d peers: %w", pm.NumPeers(), err)
+	}
+	return err
+}
+
+// protocolFailed is the error of a session of kind that failed in state.
+func protocolFailed(kind types2.JobKind, state types.MainState) error {
+	return tsserr.New(tsserr.ProtocolFailed, "%s failed in state %s", kind, state.String()).
+		With("kind", string(kind)).With("state", state.String())
+}
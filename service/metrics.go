@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"alice-tss/metrics"
+	types2 "alice-tss/types"
+
+	"github.com/getamis/alice/types"
+)
+
+// sessionMetrics times a session and its rounds. alice only reports the main
+// states of a session, so a round is told by the type of its messages: it
+// starts with its first message, and ends with the first message of the next
+// round, or when the session is done or failed.
+type sessionMetrics struct {
+	kind    string
+	started time.Time
+
+	mu           sync.Mutex
+	round        string
+	roundStarted time.Time
+	rounds       map[string]bool
+}
+
+func newSessionMetrics(kind types2.JobKind) *sessionMetrics {
+	return &sessionMetrics{kind: string(kind), rounds: map[string]bool{}}
+}
+
+// start is called when the session starts processing.
+func (m *sessionMetrics) start() {
+	m.started = time.Now()
+}
+
+// messageAccepted records the round of a message the session accepted.
+func (m *sessionMetrics) messageAccepted(msgType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rounds[msgType] {
+		// Late messages of a round do not restart it.
+		return
+	}
+	m.rounds[msgType] = true
+	m.endRound()
+	m.round, m.roundStarted = msgType, time.Now()
+}
+
+// stateChanged ends the last round once the session is over.
+func (m *sessionMetrics) stateChanged(newState types.MainState) {
+	if newState != types.StateDone && newState != types.StateFailed {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endRound()
+	m.round = ""
+}
+
+func (m *sessionMetrics) endRound() {
+	if m.round != "" {
+		metrics.RoundEnded(m.kind, m.round, time.Since(m.roundStarted))
+	}
+}
+
+// done records the outcome of the session, given what Process returned.
+func (m *sessionMetrics) done(err error) {
+	outcome := metrics.OutcomeDone
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		outcome = metrics.OutcomeCancelled
+	case err != nil:
+		outcome = metrics.OutcomeFailed
+	}
+	metrics.SessionEnded(m.kind, outcome, time.Since(m.started))
+}
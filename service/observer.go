@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/getamis/alice/types"
+)
+
+// Observer follows the progress of a session, e.g. to report it on a job.
+type Observer interface {
+	// StateChanged is called on every protocol state change of the session.
+	StateChanged(oldState types.MainState, newState types.MainState)
+	// MessageReceived is called when the message of a round, named by its
+	// type, from the peer from has been accepted by the session.
+	MessageReceived(from string, msgType string)
+}
+
+type observerKey struct{}
+
+// WithObserver returns a copy of ctx whose sessions report to o. A nil o
+// detaches the sessions of ctx from any observer.
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, o)
+}
+
+func observerFrom(ctx context.Context) Observer {
+	o, _ := ctx.Value(observerKey{}).(Observer)
+	return o
+}
+
+// sessionObserver holds the observer of a session. It is attached by Process,
+// while messages of peers may already be arriving.
+type sessionObserver struct {
+	mu sync.Mutex
+	o  Observer
+}
+
+func (s *sessionObserver) attach(o Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.o = o
+}
+
+func (s *sessionObserver) get() Observer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.o
+}
+
+// stateChanged reports a state change to the observer, if any.
+func (s *sessionObserver) stateChanged(oldState types.MainState, newState types.MainState) {
+	if o := s.get(); o != nil {
+		o.StateChanged(oldState, newState)
+	}
+}
+
+// messageReceived reports a message of a peer to the observer, if any.
+func (s *sessionObserver) messageReceived(from string, msgType string) {
+	if o := s.get(); o != nil {
+		o.MessageReceived(from, msgType)
+	}
+}
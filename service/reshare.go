@@ -1,14 +1,17 @@
 package service
 
 import (
+	"alice-tss/metrics"
 	"alice-tss/store"
 	types2 "alice-tss/types"
+	"context"
 	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
 	"github.com/getamis/alice/types"
 	"github.com/getamis/sirius/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"io"
+	"math/big"
 
 	"alice-tss/peer"
 	"alice-tss/utils"
@@ -22,6 +25,14 @@ type Reshare struct {
 
 	reshare *reshare.Reshare
 	hash    string
+	// share is the decrypted share being reshared, wiped once the session is
+	// over.
+	share *big.Int
+
+	observer sessionObserver
+	metrics  *sessionMetrics
+	// err is why the session failed, set before done is closed.
+	err error
 }
 
 func NewReshareService(config *types2.ReshareConfig, pm *peer.P2PManager, hash string, storeDb store.HandlerData) (*Reshare, error) {
@@ -30,6 +41,7 @@ func NewReshareService(config *types2.ReshareConfig, pm *peer.P2PManager, hash s
 		pm:      pm,
 		storeDB: storeDb,
 		done:    make(chan struct{}),
+		metrics: newSessionMetrics(types2.JobKindReshare),
 	}
 
 	// Reshare needs results from DKG.
@@ -40,10 +52,12 @@ func NewReshareService(config *types2.ReshareConfig, pm *peer.P2PManager, hash s
 	}
 
 	// Create reshare
+	s.share = dkgResult.Share
 	s.reshare, err = reshare.NewReshare(pm, config.Threshold, dkgResult.PublicKey, dkgResult.Share, dkgResult.Bks, s)
 	if err != nil {
 		log.Warn("Cannot create a new reshare", "err", err)
-		return nil, err
+		s.wipe()
+		return nil, newSessionErr(err, pm)
 	}
 
 	s.hash = hash
@@ -71,46 +85,83 @@ func (p *Reshare) Handle(s network.Stream) {
 		return
 	}
 
+	metrics.MessageReceived(s.Conn().RemotePeer().String())
 	log.Info("Received request", "from", s.Conn().RemotePeer())
 	err = p.reshare.AddMessage(data.GetId(), data)
 	if err != nil {
 		log.Warn("Cannot add message to reshare", "err", err)
 		return
 	}
+	p.observer.messageReceived(data.GetId(), data.GetType().String())
+	p.metrics.messageAccepted(data.GetType().String())
 }
 
-func (p *Reshare) Process() {
+// Process runs the session until it is done, failed or ctx is cancelled, and
+// returns why it did not succeed.
+func (p *Reshare) Process(ctx context.Context) (err error) {
 	// 1. Start a reshare process.
+	p.observer.attach(observerFrom(ctx))
+	p.metrics.start()
+	defer func() { p.metrics.done(err) }()
 	p.reshare.Start()
-	defer p.reshare.Stop()
+	defer func() {
+		p.reshare.Stop()
+		p.wipe()
+	}()
+
+	// 2. Wait reshare is done, failed or cancelled
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		log.Warn("Reshare cancelled", "hash", p.hash, "err", ctx.Err())
+		p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.hash))
+		return ctx.Err()
+	}
+}
+
+// Abort releases a session that will not be processed, because reason kept
+// it from starting: its stream handler and its share.
+func (p *Reshare) Abort(reason error) {
+	log.Warn("Reshare aborted", "hash", p.hash, "err", reason)
+	p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.hash))
+	p.wipe()
+}
 
-	// 2. Wait reshare is done or failed
-	<-p.done
+// wipe erases the share of the session. Go strings cannot be overwritten, so
+// the decimal copy in the config is only dropped.
+func (p *Reshare) wipe() {
+	utils.ZeroInt(p.share)
+	p.config.Share = ""
 }
+
 func (p *Reshare) closeDone() {
 	close(p.done)
 	p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.hash))
 }
 
 func (p *Reshare) OnStateChanged(oldState types.MainState, newState types.MainState) {
+	p.observer.stateChanged(oldState, newState)
+	p.metrics.stateChanged(newState)
 	if newState == types.StateFailed {
 		log.Error("Reshare failed", "old", oldState.String(), "new", newState.String())
+		p.err = protocolFailed(types2.JobKindReshare, oldState)
 		p.closeDone()
 		return
 	} else if newState == types.StateDone {
 		log.Info("Reshare done", "old", oldState.String(), "new", newState.String())
 		result, err := p.reshare.GetResult()
-		p.closeDone()
-
 		if err == nil {
-			log.Debug("reshare", "result", result)
-			if err := p.storeDB.UpdateDKGResultData(p.hash, result); err != nil {
+			err = p.storeDB.UpdateDKGResultData(p.hash, p.config.Epoch, result)
+			utils.ZeroInt(result.Share)
+			if err != nil {
 				log.Error("Cannot reshare DKG result data", "err", err)
-				return
 			}
 		} else {
 			log.Warn("Failed to get result from reshare", "err", err)
 		}
+		p.err = err
+		p.closeDone()
 		return
 	}
 	log.Info("State changed", "old", oldState.String(), "new", newState.String())
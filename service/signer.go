@@ -1,10 +1,13 @@
 package service
 
 import (
+	"alice-tss/metrics"
+	"alice-tss/pb"
 	"alice-tss/peer"
 	"alice-tss/store"
 	types2 "alice-tss/types"
 	"alice-tss/utils"
+	"context"
 	"encoding/hex"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/getamis/alice/crypto/homo/paillier"
@@ -14,6 +17,10 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"io"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
 )
 
 type Signer struct {
@@ -24,29 +31,56 @@ type Signer struct {
 
 	signer *signer.Signer
 	hash   string
+	// session is the ID of the session: the protocol of its streams and the
+	// key of its signature in the store.
+	session string
+	entry   *types2.LedgerEntry
+	// share is the decrypted share the session signs with, wiped once the
+	// session is over.
+	share *big.Int
+
+	observer sessionObserver
+	metrics  *sessionMetrics
+	// err is why the session failed, set before done is closed.
+	err error
+	// ledgerOnce records the session once, whether it ends or is cancelled.
+	ledgerOnce sync.Once
 }
 
 func NewSignerService(
 	config *types2.SignerConfig,
 	pm *peer.P2PManager,
 	storeDB store.HandlerData,
-	msg string,
+	signRequest *pb.SignRequest,
 ) (*Signer, error) {
 	s := &Signer{
 		config:  config,
 		pm:      pm,
 		storeDB: storeDB,
 		done:    make(chan struct{}),
+		metrics: newSessionMetrics(types2.JobKindSign),
 	}
 
 	log.Info("Service call")
-	if err := s.createSigner(msg); err != nil {
+	if err := s.createSigner(signRequest.Message); err != nil {
 		return nil, err
 	}
-	hash := utils.ToHexHash([]byte(msg))
+	hash := utils.ToHexHash([]byte(signRequest.Message))
 	s.hash = hash
+	s.session = signRequest.SessionId
+
+	participants := append(pm.PeerIDs(), pm.SelfID())
+	sort.Strings(participants)
+	s.entry = &types2.LedgerEntry{
+		ID:           utils.RandomHash(),
+		KeyHash:      signRequest.Hash,
+		Pubkey:       signRequest.Pubkey,
+		Digest:       hash,
+		Participants: participants,
+		Initiator:    signRequest.Initiator,
+	}
 
-	pm.Host.SetStreamHandler(peer.GetProtocol(hash), func(stream network.Stream) {
+	pm.Host.SetStreamHandler(peer.GetProtocol(s.session), func(stream network.Stream) {
 		s.Handle(stream)
 	})
 
@@ -69,10 +103,12 @@ func (p *Signer) createSigner(msg string) error {
 
 	log.Info("Signer created", "msg", msg)
 	byteMessage := common.Hex2Bytes(msg)
+	p.share = dkgResult.Share
 	newSigner, err := signer.NewSigner(p.pm, dkgResult.PublicKey, newPaillier, dkgResult.Share, dkgResult.Bks, byteMessage, p)
 	if err != nil {
 		log.Warn("Cannot create a new cmd", "err", err)
-		return err
+		p.wipe()
+		return newSessionErr(err, p.pm)
 	}
 	p.signer = newSigner
 
@@ -101,57 +137,119 @@ func (p *Signer) Handle(s network.Stream) {
 		return
 	}
 
+	metrics.MessageReceived(s.Conn().RemotePeer().String())
 	log.Info("Received request", "from", s.Conn().RemotePeer())
 	err = p.signer.AddMessage(data.GetId(), data)
 	if err != nil {
 		log.Warn("Cannot add message to cmd", "err", err)
 		return
 	}
+	p.observer.messageReceived(data.GetId(), data.GetType().String())
+	p.metrics.messageAccepted(data.GetType().String())
 }
 
-func (p *Signer) Process() {
+// Process runs the session until it is done, failed or ctx is cancelled, and
+// returns why it did not succeed.
+func (p *Signer) Process(ctx context.Context) (err error) {
 	// 1. Start a cmd process.
+	p.observer.attach(observerFrom(ctx))
+	p.metrics.start()
+	defer func() { p.metrics.done(err) }()
+	p.entry.StartedAt = time.Now().Unix()
 	p.signer.Start()
 	log.Info("Signer process", "action", "start")
 	defer func() {
 		log.Info("Signer process", "action", "stop")
 		p.signer.Stop()
+		p.wipe()
 	}()
 
-	// 2. Wait the cmd is done or failed
-	<-p.done
+	// 2. Wait the cmd is done, failed or cancelled
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		log.Warn("Signer cancelled", "hash", p.hash, "session", p.session, "err", ctx.Err())
+		p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.session))
+		p.appendLedger(types2.LedgerOutcomeFailed, nil, ctx.Err().Error())
+		return ctx.Err()
+	}
+}
+
+// Abort releases a session that will not be processed, because reason kept
+// it from starting: its stream handler and its share. The attempt is recorded
+// in the ledger.
+func (p *Signer) Abort(reason error) {
+	log.Warn("Signer aborted", "hash", p.hash, "session", p.session, "err", reason)
+	p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.session))
+	p.wipe()
+	p.entry.StartedAt = time.Now().Unix()
+	p.appendLedger(types2.LedgerOutcomeFailed, nil, reason.Error())
+}
+
+// wipe erases the share of the session. Go strings cannot be overwritten, so
+// the decimal copy in the config is only dropped.
+func (p *Signer) wipe() {
+	utils.ZeroInt(p.share)
+	p.config.Share = ""
 }
 
 func (p *Signer) closeDone() {
 	close(p.done)
-	p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.hash))
+	p.pm.Host.RemoveStreamHandler(peer.GetProtocol(p.session))
 }
 
 func (p *Signer) OnStateChanged(oldState types.MainState, newState types.MainState) {
+	p.observer.stateChanged(oldState, newState)
+	p.metrics.stateChanged(newState)
 	if newState == types.StateFailed {
 		log.Error("Signer failed", "old", oldState.String(), "new", newState.String())
+		p.err = protocolFailed(types2.JobKindSign, oldState)
 		p.closeDone()
+		p.appendLedger(types2.LedgerOutcomeFailed, nil, p.err.Error())
 		return
 	} else if newState == types.StateDone {
 		log.Info("Signer done", "old", oldState.String(), "new", newState.String())
 		result, err := p.signer.GetResult()
+		p.err = err
 		p.closeDone()
 
 		if err == nil {
-			log.Debug("signed", "result", result)
-
-			if err := p.storeDB.SaveSignerResultData(p.hash, types2.RVSignature{
-				R:    hex.EncodeToString(result.R.Bytes()),
-				S:    hex.EncodeToString(result.S.Bytes()),
-				Hash: p.hash,
+			if err := p.storeDB.SaveSignerResultData(p.session, types2.RVSignature{
+				R:         hex.EncodeToString(result.R.Bytes()),
+				S:         hex.EncodeToString(result.S.Bytes()),
+				Hash:      p.hash,
+				SessionID: p.session,
+				KeyHash:   p.entry.KeyHash,
 			}); err != nil {
 				log.Error("Cannot save sign result", "err", err)
-				return
 			}
+			p.appendLedger(types2.LedgerOutcomeSigned, result, "")
 		} else {
 			log.Warn("Failed to get result from cmd", "err", err)
+			p.appendLedger(types2.LedgerOutcomeFailed, nil, err.Error())
 		}
 		return
 	}
 	log.Info("State changed", "old", oldState.String(), "new", newState.String())
 }
+
+// appendLedger records the outcome of the session in the signature ledger,
+// once.
+func (p *Signer) appendLedger(outcome string, result *signer.Result, reason string) {
+	p.ledgerOnce.Do(func() { p.writeLedger(outcome, result, reason) })
+}
+
+func (p *Signer) writeLedger(outcome string, result *signer.Result, reason string) {
+	entry := *p.entry
+	entry.FinishedAt = time.Now().Unix()
+	entry.Outcome = outcome
+	entry.Error = reason
+	if result != nil {
+		entry.R = hex.EncodeToString(result.R.Bytes())
+		entry.S = hex.EncodeToString(result.S.Bytes())
+	}
+	if err := p.storeDB.AppendLedgerEntry(&entry); err != nil {
+		log.Error("Cannot append ledger entry", "id", entry.ID, "err", err)
+	}
+}
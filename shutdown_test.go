@@ -0,0 +1,128 @@
+package main_test
+
+import (
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// serving returns a handler calling apiServer over TCP, and its URL.
+func serving(t *testing.T, apiServer *server.APIServer) (http.Handler, string) {
+	t.Helper()
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", apiServer.Addr().(*net.TCPAddr).Port)
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httputil.NewSingleHostReverseProxy(target), baseURL
+}
+
+func TestShutdown(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+
+	apiServer, err := server.InitRouter(&types.AppConfig{}, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, baseURL := serving(t, apiServer)
+	rpcCall(t, handler, "", "signer.ListKeys", map[string]interface{}{"data": map[string]interface{}{}}, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := apiServer.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(baseURL + "/v1/openapi.json"); err == nil {
+		t.Fatal("the API still serves after shutdown")
+	}
+	select {
+	case err := <-apiServer.Err():
+		t.Fatalf("shutdown reported as a failure: %v", err)
+	default:
+	}
+}
+
+// TestShutdownDrainsJobs stops a node API while a DKG job runs, which it lets
+// finish, then while a signing job runs, which it cancels when the shutdown
+// timeout is over.
+func TestShutdownDrainsJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs protocol sessions")
+	}
+	selfService, err := server.NewSelfService()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer selfService.Close()
+
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+
+	apiServer, err := server.InitRouter(&types.AppConfig{}, nil, storeDB, selfService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, _ := serving(t, apiServer)
+	job := decodeJob(t, rpcCall(t, handler, "", "signer.RegisterSelfDKG", map[string]interface{}{"data": nil}, false))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := apiServer.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := storeDB.GetJob(job.ID)
+	if err != nil || stored.Status != types.JobStatusDone {
+		t.Fatalf("DKG job after shutdown %+v, %v", stored, err)
+	}
+	var key struct {
+		Pubkey string `json:"pubkey"`
+	}
+	if err := json.Unmarshal(stored.Result, &key); err != nil {
+		t.Fatal(err)
+	}
+
+	apiServer, err = server.InitRouter(&types.AppConfig{}, nil, storeDB, selfService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, _ = serving(t, apiServer)
+	job = decodeJob(t, rpcCall(t, handler, "", "signer.SelfSignMessage", map[string]interface{}{"data": map[string]interface{}{
+		"hash": stored.KeyHash, "pubkey": key.Pubkey, "message": "68656c6c6f",
+	}}, false))
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	shutdownErr := apiServer.Shutdown(expired)
+	stored, err = storeDB.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch stored.Status {
+	case types.JobStatusCancelled:
+		if shutdownErr == nil {
+			t.Fatal("shutdown must report the jobs it cancelled")
+		}
+	case types.JobStatusDone:
+		// the session won the race
+	default:
+		t.Fatalf("signing job ended %s: %s", stored.Status, stored.Error)
+	}
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"alice-tss/types"
+	"errors"
+	"fmt"
+)
+
+// validateAccessRule checks that a rule names a subject, only known roles,
+// and no empty key hash.
+func validateAccessRule(rule *types.AccessRule) error {
+	if rule.Subject == "" {
+		return errors.New("access rule needs a subject")
+	}
+	if len(rule.Roles) == 0 {
+		return errors.New("access rule needs a role")
+	}
+	for _, role := range rule.Roles {
+		if !role.Valid() {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for _, hash := range rule.Keys {
+		if hash == "" {
+			return errors.New("access rule has an empty key hash")
+		}
+	}
+	if rule.Keys == nil {
+		rule.Keys = []string{}
+	}
+	return nil
+}
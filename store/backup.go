@@ -0,0 +1,25 @@
+package store
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrBackupUnsupported is returned by Backup for stores that cannot take an
+// online backup.
+var ErrBackupUnsupported = errors.New("store does not support online backups")
+
+// Backuper is implemented by stores that can write a consistent snapshot of
+// themselves while the node keeps serving.
+type Backuper interface {
+	Backup(w io.Writer) error
+}
+
+// Backup writes a consistent snapshot of db to w.
+func Backup(db HandlerData, w io.Writer) error {
+	backuper, ok := db.(Backuper)
+	if !ok {
+		return ErrBackupUnsupported
+	}
+	return backuper.Backup(w)
+}
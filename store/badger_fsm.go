@@ -1,14 +1,38 @@
 package store
 
 import (
-	"crypto/ecdsa"
+	"alice-tss/types"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
 	"github.com/dgraph-io/badger"
+	"github.com/getamis/sirius/log"
+)
+
+const (
+	defaultGCInterval = 10 * time.Minute
+	// gcDiscardRatio is the share of stale data a value log file needs
+	// before it is rewritten.
+	gcDiscardRatio = 0.5
+	// compactDiscardRatio rewrites every value log file holding stale data.
+	compactDiscardRatio  = 0.01
+	restorePendingWrites = 256
 )
 
+var (
+	// errStopScan ends a Scan early without reporting an error.
+	errStopScan = errors.New("stop scan")
+	// ErrRecordExists is returned when Insert would overwrite a record.
+	ErrRecordExists = errors.New("record already exists")
+)
+
+// FSM is the badger implementation of kvStore.
 type FSM struct {
-	db         *badger.DB
-	privateKey *ecdsa.PrivateKey
+	db *badger.DB
 }
 
 // Get fetch data from badgerDB
@@ -49,6 +73,20 @@ func (fsm *FSM) Get(key string) (interface{}, error) {
 	return data, err
 }
 
+// Load decodes the JSON value stored at key into v. It returns
+// badger.ErrKeyNotFound when the key does not exist.
+func (fsm *FSM) Load(key string, v interface{}) error {
+	return fsm.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, v)
+		})
+	})
+}
+
 // Set store data to badgerDB
 func (fsm *FSM) Set(key string, value interface{}) error {
 	var data = make([]byte, 0)
@@ -71,6 +109,45 @@ func (fsm *FSM) Set(key string, value interface{}) error {
 	return txn.Commit()
 }
 
+// SetBatch stores every value in a single transaction, so that either all of
+// them or none are written.
+func (fsm *FSM) SetBatch(values map[string]interface{}) error {
+	return fsm.db.Update(func(txn *badger.Txn) error {
+		for key, value := range values {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Insert is like SetBatch, but fails with ErrRecordExists without writing
+// anything if one of the keys is already present.
+func (fsm *FSM) Insert(values map[string]interface{}) error {
+	return fsm.db.Update(func(txn *badger.Txn) error {
+		for key, value := range values {
+			if _, err := txn.Get([]byte(key)); err == nil {
+				return fmt.Errorf("%w: %s", ErrRecordExists, key)
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // SetArr store [data] to badgerDB
 func (fsm *FSM) SetArr(key string, value interface{}) error {
 	var data = make([]byte, 0)
@@ -118,10 +195,190 @@ func (fsm *FSM) Delete(key string) error {
 	return txn.Commit()
 }
 
+// Rename atomically moves the value stored at from to the key to. It refuses
+// to overwrite an existing key.
+func (fsm *FSM) Rename(from, to string) error {
+	return fsm.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(to)); err == nil {
+			return fmt.Errorf("key %s already exists", to)
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		item, err := txn.Get([]byte(from))
+		if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(to), value); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(from))
+	})
+}
+
+// Scan calls fn for every key starting with prefix, in key order. The value
+// slice is only valid until fn returns. fn may return errStopScan to end the
+// scan early without an error.
+func (fsm *FSM) Scan(prefix string, fn func(key string, value []byte) error) error {
+	return fsm.ScanFrom(prefix, "", fn)
+}
+
+// ScanFrom is like Scan but starts at the first key strictly after after.
+func (fsm *FSM) ScanFrom(prefix, after string, fn func(key string, value []byte) error) error {
+	err := fsm.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		p := []byte(prefix)
+		start := p
+		if after != "" {
+			start = []byte(after)
+		}
+		for it.Seek(start); it.ValidForPrefix(p); it.Next() {
+			item := it.Item()
+			if after != "" && string(item.Key()) == after {
+				continue
+			}
+			err := item.Value(func(val []byte) error {
+				return fn(string(item.Key()), val)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errStopScan {
+		return nil
+	}
+	return err
+}
+
 // NewBadgerFSM implementation using badgerDB
-func NewBadgerFSM(badgerDB *badger.DB, privateKey *ecdsa.PrivateKey) *FSM {
+func NewBadgerFSM(badgerDB *badger.DB) *FSM {
 	return &FSM{
-		db:         badgerDB,
-		privateKey: privateKey,
+		db: badgerDB,
+	}
+}
+
+// Backup writes a consistent snapshot of the database to w while it keeps
+// serving reads and writes.
+func (fsm *FSM) Backup(w io.Writer) error {
+	_, err := fsm.db.Backup(w, 0)
+	return err
+}
+
+// Compact drops deleted and overwritten values from the LSM tree, and rewrites
+// the value log files that still hold them. Badger never rewrites the value
+// log file it appends to, so the latest writes stay on disk until that file
+// is full and collected.
+func (fsm *FSM) Compact() error {
+	if err := fsm.db.Flatten(1); err != nil {
+		return err
+	}
+	for {
+		err := fsm.db.RunValueLogGC(compactDiscardRatio)
+		if err == badger.ErrNoRewrite {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NewBadgerDB opens the badger database in config.Path as a HandlerData, and
+// garbage collects its value log every config.GCInterval until it is closed.
+func NewBadgerDB(config types.StoreConfig, kek KeyEncryptionProvider) (HandlerData, error) {
+	log.Info("badger dir", "dir", config.Path)
+	badgerOpt := badger.DefaultOptions(config.Path).
+		WithCompactL0OnClose(true)
+	db, err := badger.Open(badgerOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := config.GCInterval
+	if interval == 0 {
+		interval = defaultGCInterval
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	if interval > 0 {
+		go runValueLogGC(db, interval, stop, done)
+	} else {
+		close(done)
+	}
+	closer := func() error {
+		close(stop)
+		<-done
+		return db.Close()
+	}
+
+	handler, err := newKVHandler(NewBadgerFSM(db), kek, closer)
+	if err != nil {
+		_ = closer()
+		return nil, err
+	}
+	return handler, nil
+}
+
+// runValueLogGC reclaims value log space every interval until stop is closed.
+// Each round rewrites log files until badger finds none worth rewriting.
+func runValueLogGC(db *badger.DB, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		rewritten := 0
+		for {
+			err := db.RunValueLogGC(gcDiscardRatio)
+			if err != nil {
+				if err != badger.ErrNoRewrite {
+					log.Warn("Value log GC failed", "err", err)
+				}
+				break
+			}
+			rewritten++
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		log.Debug("Value log GC done", "rewritten", rewritten)
+	}
+}
+
+// RestoreBadger loads a backup written by Backup into a new badger database
+// in badgerDir. It refuses to touch a directory that already holds files, so
+// that a restore never merges into or overwrites live node state.
+func RestoreBadger(badgerDir string, r io.Reader) error {
+	entries, err := os.ReadDir(badgerDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("restore target %s is not empty", badgerDir)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(badgerDir))
+	if err != nil {
+		return err
+	}
+	if err := db.Load(r, restorePendingWrites); err != nil {
+		_ = db.Close()
+		return err
 	}
+	return db.Close()
 }
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"alice-tss/types"
+	"alice-tss/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/getamis/sirius/log"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bundleVersion = 1
+	bundleKDF     = "scrypt"
+
+	bundleScryptN = 1 << 15
+	bundleScryptR = 8
+	bundleScryptP = 1
+	// The bounds of the work an imported bundle can ask for: scrypt takes
+	// 128*N*r bytes of memory, and p times as long as with p = 1.
+	bundleMaxScryptN      = 1 << 20
+	bundleMaxScryptR      = 32
+	bundleMaxScryptP      = 16
+	bundleMaxScryptMemory = 1 << 30
+)
+
+var (
+	// ErrBundlePassword is returned when a bundle cannot be opened, either
+	// because the password is wrong or because the bundle was tampered with.
+	ErrBundlePassword = errors.New("wrong bundle password or corrupted bundle")
+	// ErrUnsupportedBundle is returned for bundles written by another format.
+	ErrUnsupportedBundle = errors.New("unsupported key bundle")
+)
+
+// keyBundle is the file written by ExportBundle. The key list is sealed with
+// AES-256-GCM under a key derived from the password with scrypt; the header
+// fields are authenticated as additional data.
+type keyBundle struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// bundlePayload is the plaintext sealed in a keyBundle.
+type bundlePayload struct {
+	ExportedAt int64               `json:"exportedAt"`
+	Keys       []types.ExportedKey `json:"keys"`
+}
+
+// checkScrypt refuses scrypt parameters that are invalid or ask for more work
+// than bundles written by ExportBundle.
+func (b *keyBundle) checkScrypt() error {
+	switch {
+	case b.N <= 1 || b.N&(b.N-1) != 0:
+		return fmt.Errorf("scrypt N %d is not a power of two", b.N)
+	case b.N > bundleMaxScryptN:
+		return fmt.Errorf("scrypt N %d is too large", b.N)
+	case b.R < 1 || b.R > bundleMaxScryptR:
+		return fmt.Errorf("scrypt r %d is out of range", b.R)
+	case b.P < 1 || b.P > bundleMaxScryptP:
+		return fmt.Errorf("scrypt p %d is out of range", b.P)
+	case 128*b.N*b.R > bundleMaxScryptMemory:
+		return fmt.Errorf("scrypt N %d and r %d take too much memory", b.N, b.R)
+	}
+	return nil
+}
+
+func (b *keyBundle) additionalData() []byte {
+	return []byte(fmt.Sprintf("alice-tss key bundle v%d %s N=%d r=%d p=%d %x", b.Version, b.KDF, b.N, b.R, b.P, b.Salt))
+}
+
+func (b *keyBundle) aead(password string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), b.Salt, b.N, b.R, b.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.Zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ExportBundle writes every key held in db except destroyed ones, with all of its share epochs and
+// BK sets, to w as a bundle encrypted under password. It returns the number of
+// exported keys.
+func ExportBundle(db HandlerData, password string, w io.Writer) (int, error) {
+	if password == "" {
+		return 0, errors.New("bundle password is empty")
+	}
+
+	payload := bundlePayload{ExportedAt: time.Now().Unix(), Keys: []types.ExportedKey{}}
+	filter := types.KeyFilter{Limit: maxKeyPageSize}
+	for {
+		page, err := db.ListKeys(filter)
+		if err != nil {
+			return 0, err
+		}
+		for _, summary := range page.Keys {
+			if summary.State == types.KeyStateDestroyed {
+				continue
+			}
+			key, err := db.ExportKey(summary.Hash)
+			if err != nil {
+				log.Error("Cannot export key", "hash", summary.Hash, "err", err)
+				return 0, err
+			}
+			payload.Keys = append(payload.Keys, *key)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	defer utils.Zero(plaintext)
+
+	bundle := &keyBundle{
+		Version: bundleVersion,
+		KDF:     bundleKDF,
+		N:       bundleScryptN,
+		R:       bundleScryptR,
+		P:       bundleScryptP,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := io.ReadFull(rand.Reader, bundle.Salt); err != nil {
+		return 0, err
+	}
+	aead, err := bundle.aead(password)
+	if err != nil {
+		return 0, err
+	}
+	bundle.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, bundle.Nonce); err != nil {
+		return 0, err
+	}
+	bundle.Ciphertext = aead.Seal(nil, bundle.Nonce, plaintext, bundle.additionalData())
+
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		return 0, err
+	}
+	return len(payload.Keys), nil
+}
+
+// ImportBundle decrypts a bundle written by ExportBundle and stores its keys in
+// db, sealed under db's node key. Nothing is imported if db already holds one
+// of the keys. It returns the number of imported keys.
+func ImportBundle(db HandlerData, password string, r io.Reader) (int, error) {
+	var bundle keyBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+	}
+	if bundle.Version != bundleVersion || bundle.KDF != bundleKDF {
+		return 0, fmt.Errorf("%w: version %d, kdf %q", ErrUnsupportedBundle, bundle.Version, bundle.KDF)
+	}
+	if err := bundle.checkScrypt(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+	}
+
+	aead, err := bundle.aead(password)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedBundle, err)
+	}
+	if len(bundle.Nonce) != aead.NonceSize() {
+		return 0, ErrBundlePassword
+	}
+	plaintext, err := aead.Open(nil, bundle.Nonce, bundle.Ciphertext, bundle.additionalData())
+	if err != nil {
+		return 0, ErrBundlePassword
+	}
+	defer utils.Zero(plaintext)
+
+	var payload bundlePayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return 0, err
+	}
+	for _, key := range payload.Keys {
+		if _, err := db.GetDKGResultData(key.Hash); err == nil {
+			return 0, fmt.Errorf("%w: %s", ErrRecordExists, key.Hash)
+		} else if !errors.Is(err, ErrNotFound) {
+			return 0, err
+		}
+	}
+	for i := range payload.Keys {
+		if err := db.ImportKey(&payload.Keys[i]); err != nil {
+			log.Error("Cannot import key", "hash", payload.Keys[i].Hash, "err", err)
+			return i, err
+		}
+	}
+	return len(payload.Keys), nil
+}
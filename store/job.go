@@ -0,0 +1,60 @@
+package store
+
+import (
+	"alice-tss/types"
+	"cmp"
+	"errors"
+	"slices"
+)
+
+// validateJob checks that a job record has an ID, a kind and a status.
+func validateJob(job *types.Job) error {
+	if job.ID == "" {
+		return errors.New("job needs an id")
+	}
+	if job.Kind == "" {
+		return errors.New("job needs a kind")
+	}
+	if job.Status == "" {
+		return errors.New("job needs a status")
+	}
+	return nil
+}
+
+// sortJobs orders jobs by creation time, then ID.
+func sortJobs(jobs []types.Job) {
+	slices.SortFunc(jobs, func(a, b types.Job) int {
+		if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// validateWebhookDelivery checks that a delivery has an ID, a job, a URL and
+// a status.
+func validateWebhookDelivery(delivery *types.WebhookDelivery) error {
+	if delivery.ID == "" {
+		return errors.New("webhook delivery needs an id")
+	}
+	if delivery.JobID == "" {
+		return errors.New("webhook delivery needs a job")
+	}
+	if delivery.URL == "" {
+		return errors.New("webhook delivery needs a url")
+	}
+	if delivery.Status == "" {
+		return errors.New("webhook delivery needs a status")
+	}
+	return nil
+}
+
+// sortWebhookDeliveries orders deliveries by creation time, then ID.
+func sortWebhookDeliveries(deliveries []types.WebhookDelivery) {
+	slices.SortFunc(deliveries, func(a, b types.WebhookDelivery) int {
+		if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"alice-tss/types"
+	"alice-tss/utils"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const keyFileSize = 32
+
+// ErrKEKMismatch is returned when a store is opened with another key
+// encryption provider than the one its shares are sealed with.
+var ErrKEKMismatch = errors.New("store shares are sealed with another key encryption key")
+
+// KeyEncryptionProvider seals shares before they are stored and opens them
+// when they are read. aad binds a sealed share to the key it belongs to.
+type KeyEncryptionProvider interface {
+	// ID names the provider and its key. Stores record it so that they refuse
+	// to run with a key their shares were not sealed with.
+	ID() string
+	Seal(plaintext, aad []byte) (string, error)
+	Open(envelope string, aad []byte) ([]byte, error)
+}
+
+// secretProvider seals shares with utils.Seal under a secret held in memory.
+type secretProvider struct {
+	id     string
+	secret []byte
+	// nodeKey is set when secret is the node identity key, which is also what
+	// shares written before envelopes existed were encrypted with.
+	nodeKey bool
+}
+
+func (p *secretProvider) ID() string {
+	return p.id
+}
+
+func (p *secretProvider) Seal(plaintext, aad []byte) (string, error) {
+	return utils.Seal(plaintext, p.secret, aad)
+}
+
+func (p *secretProvider) Open(envelope string, aad []byte) ([]byte, error) {
+	return utils.Open(envelope, p.secret, aad)
+}
+
+// NewNodeKeyProvider seals shares under the node identity key, so the node
+// identity and the share encryption key are the same secret.
+func NewNodeKeyProvider(privateKey *ecdsa.PrivateKey) KeyEncryptionProvider {
+	return &secretProvider{
+		id:      fmt.Sprintf("%s:%s", types.KEKTypeNodeKey, crypto.PubkeyToAddress(privateKey.PublicKey).Hex()),
+		secret:  crypto.FromECDSA(privateKey),
+		nodeKey: true,
+	}
+}
+
+// NewKeyFileProvider seals shares under the AES-256 key stored hex encoded in
+// path, independent of the node identity.
+func NewKeyFileProvider(path string) (KeyEncryptionProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(secret) != keyFileSize {
+		return nil, fmt.Errorf("key file %s does not hold a hex encoded %d byte key", path, keyFileSize)
+	}
+	fingerprint := sha256.Sum256(secret)
+	return &secretProvider{
+		id:     fmt.Sprintf("%s:%x", types.KEKTypeKeyFile, fingerprint[:8]),
+		secret: secret,
+	}, nil
+}
+
+// GenerateKeyFile writes a new random key for NewKeyFileProvider to path. It
+// never overwrites an existing file.
+func GenerateKeyFile(path string) error {
+	secret := make([]byte, keyFileSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(hex.EncodeToString(secret) + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// NewKeyEncryptionProvider returns the provider selected by config. privateKey
+// is the node identity key, used by the default node-key provider.
+func NewKeyEncryptionProvider(config types.KEKConfig, privateKey *ecdsa.PrivateKey) (KeyEncryptionProvider, error) {
+	switch config.Type {
+	case "", types.KEKTypeNodeKey:
+		if privateKey == nil {
+			return nil, errors.New("store private key is nil")
+		}
+		return NewNodeKeyProvider(privateKey), nil
+	case types.KEKTypeKeyFile:
+		if config.Path == "" {
+			return nil, errors.New("kek key file path is empty")
+		}
+		return NewKeyFileProvider(config.Path)
+	case types.KEKTypePKCS11:
+		return NewPKCS11Provider(config.PKCS11)
+	default:
+		return nil, fmt.Errorf("unknown kek type %q", config.Type)
+	}
+}
+
+// checkKEK compares the provider recorded by a store with kek. stored is empty
+// for stores that never recorded one; their shares, if any, were sealed under
+// the node key. It reports whether kek's ID must be recorded.
+func checkKEK(stored string, hasShares bool, kek KeyEncryptionProvider) (bool, error) {
+	if stored == "" {
+		if p, ok := kek.(*secretProvider); hasShares && (!ok || !p.nodeKey) {
+			return false, fmt.Errorf("%w: node key, configured %s", ErrKEKMismatch, kek.ID())
+		}
+		return true, nil
+	}
+	if stored != kek.ID() {
+		return false, fmt.Errorf("%w: %s, configured %s", ErrKEKMismatch, stored, kek.ID())
+	}
+	return false, nil
+}
+
+// legacySecret returns the secret that shares written before envelopes
+// existed were encrypted with, if kek holds it.
+func legacySecret(kek KeyEncryptionProvider) ([]byte, error) {
+	if p, ok := kek.(*secretProvider); ok && p.nodeKey {
+		return p.secret, nil
+	}
+	return nil, errors.New("legacy shares can only be migrated with the node-key provider")
+}
+
+// closeKEK releases providers that hold external resources, such as a
+// PKCS#11 session.
+func closeKEK(kek KeyEncryptionProvider) error {
+	if closer, ok := kek.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package store
+
+import (
+	"alice-tss/types"
+	"errors"
+)
+
+// NewPKCS11Provider is unavailable in builds without cgo, which the PKCS#11
+// bindings need.
+func NewPKCS11Provider(types.PKCS11Config) (KeyEncryptionProvider, error) {
+	return nil, errors.New("pkcs11 kek needs a build with cgo enabled")
+}
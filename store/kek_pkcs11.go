@@ -0,0 +1,179 @@
+//go:build cgo
+
+package store
+
+import (
+	"alice-tss/types"
+	"alice-tss/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	// pkcs11EnvelopeV1 prefixes shares sealed with AES-256-GCM inside a token.
+	pkcs11EnvelopeV1 = "p11v1:"
+	pkcs11NonceSize  = 12
+	pkcs11TagBits    = 128
+)
+
+// pkcs11Provider seals shares with an AES-256 secret key that never leaves
+// a PKCS#11 token. Token sessions are not safe for concurrent operations, so
+// every operation holds mu.
+type pkcs11Provider struct {
+	id      string
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+
+	mu sync.Mutex
+}
+
+// NewPKCS11Provider logs into the token labelled config.TokenLabel and looks
+// up the secret key labelled config.KeyLabel.
+func NewPKCS11Provider(config types.PKCS11Config) (KeyEncryptionProvider, error) {
+	if config.Library == "" || config.TokenLabel == "" || config.KeyLabel == "" {
+		return nil, errors.New("pkcs11 kek needs a library, a token label and a key label")
+	}
+	ctx := pkcs11.New(config.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot load pkcs11 library %s", config.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("initialize pkcs11 library: %w", err)
+	}
+
+	p := &pkcs11Provider{
+		id:  fmt.Sprintf("%s:%s/%s", types.KEKTypePKCS11, config.TokenLabel, config.KeyLabel),
+		ctx: ctx,
+	}
+	if err := p.open(config); err != nil {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pkcs11Provider) open(config types.PKCS11Config) error {
+	slot, err := findPKCS11Slot(p.ctx, config.TokenLabel)
+	if err != nil {
+		return err
+	}
+	p.session, err = p.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("open pkcs11 session: %w", err)
+	}
+	if err := p.ctx.Login(p.session, pkcs11.CKU_USER, config.Pin); err != nil {
+		_ = p.ctx.CloseSession(p.session)
+		return fmt.Errorf("pkcs11 login: %w", err)
+	}
+	p.key, err = findPKCS11Key(p.ctx, p.session, config.KeyLabel)
+	if err != nil {
+		_ = p.ctx.Logout(p.session)
+		_ = p.ctx.CloseSession(p.session)
+		return err
+	}
+	return nil
+}
+
+func findPKCS11Slot(ctx *pkcs11.Ctx, tokenLabel string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("list pkcs11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(info.Label) == tokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("pkcs11 token %q not found", tokenLabel)
+}
+
+func findPKCS11Key(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, keyLabel string) (pkcs11.ObjectHandle, error) {
+	err := ctx.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+	})
+	if err != nil {
+		return 0, err
+	}
+	objects, _, err := ctx.FindObjects(session, 2)
+	if finalErr := ctx.FindObjectsFinal(session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(objects) != 1 {
+		return 0, fmt.Errorf("found %d pkcs11 secret keys labelled %q, want 1", len(objects), keyLabel)
+	}
+	return objects[0], nil
+}
+
+func (p *pkcs11Provider) ID() string {
+	return p.id
+}
+
+func (p *pkcs11Provider) Seal(plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, pkcs11NonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	params := pkcs11.NewGCMParams(nonce, aad, pkcs11TagBits)
+	defer params.Free()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.ctx.EncryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, p.key); err != nil {
+		return "", fmt.Errorf("pkcs11 encrypt: %w", err)
+	}
+	ciphertext, err := p.ctx.Encrypt(p.session, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("pkcs11 encrypt: %w", err)
+	}
+	return pkcs11EnvelopeV1 + utils.B64Encode(append(nonce, ciphertext...)), nil
+}
+
+func (p *pkcs11Provider) Open(envelope string, aad []byte) ([]byte, error) {
+	if !strings.HasPrefix(envelope, pkcs11EnvelopeV1) {
+		return nil, utils.ErrUnsupportedEnvelope
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(envelope, pkcs11EnvelopeV1))
+	if err != nil || len(raw) < pkcs11NonceSize+pkcs11TagBits/8 {
+		return nil, utils.ErrCorruptedEnvelope
+	}
+	params := pkcs11.NewGCMParams(raw[:pkcs11NonceSize], aad, pkcs11TagBits)
+	defer params.Free()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.ctx.DecryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, p.key); err != nil {
+		return nil, fmt.Errorf("pkcs11 decrypt: %w", err)
+	}
+	plaintext, err := p.ctx.Decrypt(p.session, raw[pkcs11NonceSize:])
+	if err != nil {
+		return nil, utils.ErrCorruptedEnvelope
+	}
+	return plaintext, nil
+}
+
+// Close logs out of the token and unloads the library.
+func (p *pkcs11Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := errors.Join(p.ctx.Logout(p.session), p.ctx.CloseSession(p.session), p.ctx.Finalize())
+	p.ctx.Destroy()
+	return err
+}
@@ -0,0 +1,56 @@
+package store
+
+import "fmt"
+
+// Namespace groups records of one kind under a common key prefix, so that
+// DKG results, signatures and bookkeeping never share a flat keyspace.
+type Namespace string
+
+const (
+	NamespaceKeys       Namespace = "keys"
+	NamespaceShares     Namespace = "shares"
+	NamespaceSignatures Namespace = "signatures"
+	NamespaceSessions   Namespace = "sessions"
+	NamespaceLedger     Namespace = "ledger"
+	NamespaceLedgerIdx  Namespace = "ledger_index"
+	NamespaceMetadata   Namespace = "meta"
+	NamespaceACL        Namespace = "acl"
+	NamespaceJobs       Namespace = "jobs"
+	NamespaceWebhooks   Namespace = "webhooks"
+)
+
+// namespaces lists every namespace known to this build.
+var namespaces = []Namespace{
+	NamespaceKeys, NamespaceShares, NamespaceSignatures, NamespaceSessions,
+	NamespaceLedger, NamespaceLedgerIdx, NamespaceMetadata, NamespaceACL,
+	NamespaceJobs, NamespaceWebhooks,
+}
+
+// Prefix returns the key prefix shared by all records of the namespace.
+func (n Namespace) Prefix() string {
+	return string(n) + "/"
+}
+
+// Key returns the storage key of id inside the namespace.
+func (n Namespace) Key(id string) string {
+	return n.Prefix() + id
+}
+
+// shareEpochKey returns the key of one share epoch. Epochs are zero padded so
+// that a scan over shareEpochPrefix returns them in order.
+func shareEpochKey(hash string, epoch uint32) string {
+	return fmt.Sprintf("%s%010d", shareEpochPrefix(hash), epoch)
+}
+
+func shareEpochPrefix(hash string) string {
+	return NamespaceShares.Key(hash) + "/"
+}
+
+// schemaVersionKey stores the keyspace layout version of the database.
+var schemaVersionKey = NamespaceMetadata.Key("schema_version")
+
+// kekKey stores the ID of the key encryption provider that sealed the shares.
+var kekKey = NamespaceMetadata.Key("kek")
+
+// SchemaVersion is the keyspace layout written by this build.
+const SchemaVersion = 9
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"alice-tss/tsserr"
+	"alice-tss/types"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ErrNotFound is returned by every backend when a record does not exist.
+var ErrNotFound = badger.ErrKeyNotFound
+
+// keyNotFound reports the missing key hash as KEY_NOT_FOUND, still wrapping
+// ErrNotFound.
+func keyNotFound(hash string) error {
+	return tsserr.New(tsserr.KeyNotFound, "key %s: %w", hash, ErrNotFound).With("hash", hash)
+}
+
+// signatureNotFound reports the missing signature of session as
+// SIGNATURE_NOT_FOUND, still wrapping ErrNotFound.
+func signatureNotFound(session string) error {
+	return tsserr.New(tsserr.SignatureNotFound, "signature %s: %w", session, ErrNotFound).With("session", session)
+}
+
+// accessRuleNotFound reports the missing access rule of subject as
+// ACCESS_RULE_NOT_FOUND, still wrapping ErrNotFound.
+func accessRuleNotFound(subject string) error {
+	return tsserr.New(tsserr.AccessRuleNotFound, "access rule of %s: %w", subject, ErrNotFound).With("subject", subject)
+}
+
+// jobNotFound reports the missing job id as JOB_NOT_FOUND, still wrapping
+// ErrNotFound.
+func jobNotFound(id string) error {
+	return tsserr.New(tsserr.JobNotFound, "job %s: %w", id, ErrNotFound).With("job", id)
+}
+
+// deliveryNotFound reports the missing webhook delivery id as
+// WEBHOOK_DELIVERY_NOT_FOUND, still wrapping ErrNotFound.
+func deliveryNotFound(id string) error {
+	return tsserr.New(tsserr.DeliveryNotFound, "webhook delivery %s: %w", id, ErrNotFound).With("delivery", id)
+}
+
+// staleEpoch reports a reshared share epoch that is not newer than the current
+// epoch of the key as EPOCH_MISMATCH.
+func staleEpoch(hash string, epoch, current uint32) error {
+	return tsserr.New(tsserr.EpochMismatch, "share epoch %d of %s is not newer than %d", epoch, hash, current).
+		With("hash", hash).With("epoch", strconv.FormatUint(uint64(current), 10))
+}
+
+// epochNotFound reports a share epoch of hash that is not stored as
+// EPOCH_MISMATCH with the current epoch, still wrapping ErrNotFound.
+func epochNotFound(hash string, epoch, current uint32) error {
+	return tsserr.New(tsserr.EpochMismatch, "share epoch %d of %s: %w", epoch, hash, ErrNotFound).
+		With("hash", hash).With("epoch", strconv.FormatUint(uint64(current), 10))
+}
+
+// keyDestroyed reports the destroyed key hash as INVALID_KEY_STATE, still
+// wrapping ErrKeyDestroyed.
+func keyDestroyed(hash string) error {
+	return tsserr.New(tsserr.InvalidKeyState, "%w: %s", ErrKeyDestroyed, hash).
+		With("hash", hash).With("state", string(types.KeyStateDestroyed))
+}
+
+// invalidTransition reports a key that cannot go from one lifecycle state to
+// another as INVALID_KEY_STATE with its current state.
+func invalidTransition(hash string, from, to types.KeyState) error {
+	return tsserr.New(tsserr.InvalidKeyState, "key %s cannot go from %s to %s", hash, from, to).
+		With("hash", hash).With("state", string(from))
+}
+
+// kvStore is the ordered key-value storage kvHandler is built on. Values are
+// JSON encoded. Implementations must return ErrNotFound for missing keys and
+// scan keys in byte order.
+type kvStore interface {
+	Load(key string, v interface{}) error
+	Set(key string, value interface{}) error
+	SetBatch(values map[string]interface{}) error
+	Insert(values map[string]interface{}) error
+	Rename(from, to string) error
+	Delete(key string) error
+	Scan(prefix string, fn func(key string, value []byte) error) error
+	ScanFrom(prefix, after string, fn func(key string, value []byte) error) error
+}
+
+// compactor is a kvStore that keeps deleted values on disk until it is
+// compacted.
+type compactor interface {
+	Compact() error
+}
+
+// newKVHandler wraps fsm into a HandlerData, migrates it to SchemaVersion and
+// checks that its shares are sealed by kek. closer is called by Defer.
+func newKVHandler(fsm kvStore, kek KeyEncryptionProvider, closer func() error) (*kvHandler, error) {
+	d := &kvHandler{fsm: fsm, kek: kek, closer: closer}
+	if err := migrate(d); err != nil {
+		return nil, fmt.Errorf("migrate store: %w", err)
+	}
+
+	var stored string
+	if err := fsm.Load(kekKey, &stored); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	hasShares := false
+	err := fsm.Scan(NamespaceShares.Prefix(), func(string, []byte) error {
+		hasShares = true
+		return errStopScan
+	})
+	if err != nil {
+		return nil, err
+	}
+	record, err := checkKEK(stored, hasShares, kek)
+	if err != nil {
+		return nil, err
+	}
+	if record {
+		if err := fsm.Set(kekKey, kek.ID()); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,565 @@
+package store
+
+import (
+	"alice-tss/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/getamis/alice/crypto/tss/dkg"
+	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
+	"github.com/getamis/sirius/log"
+	"io"
+	"strings"
+	"time"
+)
+
+// kvHandler implements HandlerData on top of an ordered key-value store. The
+// badger and memory backends share it, so they behave identically.
+type kvHandler struct {
+	fsm    kvStore
+	kek    KeyEncryptionProvider
+	closer func() error
+}
+
+// SaveDKGResultData save dkg result data
+func (d *kvHandler) SaveDKGResultData(hash string, result *dkg.Result) error {
+	data, err := newDKGRecord(d.kek, hash, result)
+	if err != nil {
+		log.Error("SaveDKGResultData", "err", err)
+		return err
+	}
+
+	err = d.fsm.SetBatch(map[string]interface{}{
+		NamespaceKeys.Key(hash): data,
+		shareEpochKey(hash, data.Epoch): &types.ShareEpoch{
+			Epoch:     data.Epoch,
+			Share:     data.Share,
+			BKs:       data.BKs,
+			CreatedAt: data.CreatedAt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDKGResultData store a reshared share as share epoch epoch of the key,
+// which must be newer than the current one. Every holder of the reshare
+// session stores the same epoch: a stored epoch of that number, left over by
+// a rollback, is replaced. The previous epochs are kept so the key can be
+// rolled back.
+func (d *kvHandler) UpdateDKGResultData(hash string, epoch uint32, result *reshare.Result) error {
+	oldDkg, err := d.GetDKGResultData(hash)
+	if err != nil {
+		log.Error("GetDKGResultData", "err", err)
+		return err
+	}
+	if epoch <= oldDkg.Epoch {
+		return staleEpoch(hash, epoch, oldDkg.Epoch)
+	}
+	log.Info("UpdateDKGResultData", "hash", hash, "epoch", epoch)
+
+	encryptedShare, err := sealShare(d.kek, result.Share, oldDkg.PublicKey)
+	if err != nil {
+		log.Error("UpdateDKGResultData", "err", err)
+		return err
+	}
+
+	oldDkg.Share = encryptedShare
+	oldDkg.Epoch = epoch
+
+	err = d.fsm.SetBatch(map[string]interface{}{
+		NamespaceKeys.Key(hash): oldDkg,
+		shareEpochKey(hash, epoch): &types.ShareEpoch{
+			Epoch:     epoch,
+			Share:     encryptedShare,
+			BKs:       oldDkg.BKs,
+			CreatedAt: time.Now().Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetShareEpochs list the share epochs stored for a key, oldest first
+func (d *kvHandler) GetShareEpochs(hash string) ([]uint32, error) {
+	epochs := []uint32{}
+	err := d.fsm.Scan(shareEpochPrefix(hash), func(_ string, value []byte) error {
+		var record types.ShareEpoch
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		epochs = append(epochs, record.Epoch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return epochs, nil
+}
+
+// RollbackShareEpoch make a previously stored share epoch the current one
+func (d *kvHandler) RollbackShareEpoch(hash string, epoch uint32) error {
+	record, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return err
+	}
+	var shareEpoch types.ShareEpoch
+	if err := d.fsm.Load(shareEpochKey(hash, epoch), &shareEpoch); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return epochNotFound(hash, epoch, record.Epoch)
+		}
+		return err
+	}
+	log.Info("RollbackShareEpoch", "hash", hash, "from", record.Epoch, "to", epoch)
+
+	record.Epoch = shareEpoch.Epoch
+	record.Share = shareEpoch.Share
+	record.BKs = shareEpoch.BKs
+	return d.fsm.Set(NamespaceKeys.Key(hash), record)
+}
+
+// SetKeyState move a key to another lifecycle state. Destroying a key erases
+// its share and every stored share epoch; the record itself is kept so the key
+// stays listed as destroyed. On badger this is not a secure erase: the sealed
+// shares stay in the value log file badger appends to, and compacting only
+// drops them from the other files.
+func (d *kvHandler) SetKeyState(hash string, state types.KeyState) error {
+	record, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return err
+	}
+	if !record.State.CanBecome(state) {
+		return invalidTransition(hash, record.State, state)
+	}
+	log.Info("SetKeyState", "hash", hash, "from", record.State, "to", state)
+
+	record.State = state
+	if state != types.KeyStateDestroyed {
+		return d.fsm.Set(NamespaceKeys.Key(hash), record)
+	}
+
+	record.Share = ""
+	if err := d.fsm.Set(NamespaceKeys.Key(hash), record); err != nil {
+		return err
+	}
+	// Deleting after the record is marked destroyed lets a retry finish an
+	// interrupted erase.
+	var epochs []string
+	err = d.fsm.Scan(shareEpochPrefix(hash), func(key string, _ []byte) error {
+		epochs = append(epochs, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range epochs {
+		if err := d.fsm.Delete(key); err != nil {
+			return err
+		}
+	}
+	if compactor, ok := d.fsm.(compactor); ok {
+		if err := compactor.Compact(); err != nil {
+			log.Warn("Cannot compact after destroying a key", "hash", hash, "err", err)
+		}
+	}
+	return nil
+}
+
+// ExportKey return a key with every share epoch opened, for an encrypted bundle
+func (d *kvHandler) ExportKey(hash string) (*types.ExportedKey, error) {
+	record, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return nil, err
+	}
+	var epochs []*types.ShareEpoch
+	err = d.fsm.Scan(shareEpochPrefix(hash), func(_ string, value []byte) error {
+		var epoch types.ShareEpoch
+		if err := json.Unmarshal(value, &epoch); err != nil {
+			return err
+		}
+		epochs = append(epochs, &epoch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exportKey(d.kek, hash, record, epochs)
+}
+
+// ImportKey store an exported key with its shares sealed under this node's key.
+// An existing key is never overwritten.
+func (d *kvHandler) ImportKey(key *types.ExportedKey) error {
+	record, epochs, err := importKey(d.kek, key)
+	if err != nil {
+		return err
+	}
+	log.Info("ImportKey", "hash", key.Hash, "epoch", record.Epoch)
+
+	values := map[string]interface{}{NamespaceKeys.Key(key.Hash): record}
+	for _, epoch := range epochs {
+		values[shareEpochKey(key.Hash, epoch.Epoch)] = epoch
+	}
+	return d.fsm.Insert(values)
+}
+
+// SaveSignerResultData save the signature of the signing session hash
+func (d *kvHandler) SaveSignerResultData(hash string, result types.RVSignature) error {
+	//log.Info("SaveSignerResultData", "hash", hash, "result", result)
+
+	err := d.fsm.Set(NamespaceSignatures.Key(hash), result)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AppendLedgerEntry append a signing session to the ledger. Entries are never
+// overwritten.
+func (d *kvHandler) AppendLedgerEntry(entry *types.LedgerEntry) error {
+	if err := validateLedgerEntry(entry); err != nil {
+		return err
+	}
+	seq := ledgerSeq(entry)
+	return d.fsm.Insert(map[string]interface{}{
+		NamespaceLedger.Key(seq):                    entry,
+		ledgerKeyIndexPrefix(entry.KeyHash) + seq:   seq,
+		ledgerDigestIndexPrefix(entry.Digest) + seq: seq,
+	})
+}
+
+// ScanLedger call fn for every ledger entry matching query after its cursor,
+// in start time order
+func (d *kvHandler) ScanLedger(query types.LedgerQuery, fn func(entry *types.LedgerEntry) error) error {
+	if query.Cursor != "" {
+		if _, _, err := parseLedgerCursor(query.Cursor); err != nil {
+			return err
+		}
+	}
+	var index string
+	switch {
+	case query.KeyHash != "":
+		index = ledgerKeyIndexPrefix(query.KeyHash)
+	case query.Digest != "":
+		index = ledgerDigestIndexPrefix(query.Digest)
+	default:
+		after := ""
+		if query.From != 0 {
+			after = NamespaceLedger.Key(fmt.Sprintf("%020d", query.From))
+		}
+		if query.Cursor != "" {
+			after = max(after, NamespaceLedger.Key(query.Cursor))
+		}
+		return d.fsm.ScanFrom(NamespaceLedger.Prefix(), after, func(_ string, value []byte) error {
+			var entry types.LedgerEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			if query.To != 0 && entry.StartedAt > query.To {
+				return errStopScan
+			}
+			return fn(&entry)
+		})
+	}
+
+	after := ""
+	if query.Cursor != "" {
+		after = index + query.Cursor
+	}
+	return d.fsm.ScanFrom(index, after, func(_ string, value []byte) error {
+		var seq string
+		if err := json.Unmarshal(value, &seq); err != nil {
+			return err
+		}
+		var entry types.LedgerEntry
+		if err := d.fsm.Load(NamespaceLedger.Key(seq), &entry); err != nil {
+			return err
+		}
+		if !matchLedger(query, &entry) {
+			return nil
+		}
+		return fn(&entry)
+	})
+}
+
+// GetDKGResultData get dkg result data
+func (d *kvHandler) GetDKGResultData(hash string) (*types.DKGResult, error) {
+	log.Info("GetDKGResultData", "hash", hash)
+	var result types.DKGResult
+	if err := d.fsm.Load(NamespaceKeys.Key(hash), &result); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, keyNotFound(hash)
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSignerResultData get the signature stored for a signing session. Older
+// builds stored signatures under the message hash, the ID of their sessions.
+func (d *kvHandler) GetSignerResultData(hash string) (*types.RVSignature, error) {
+	var result types.RVSignature
+	if err := d.fsm.Load(NamespaceSignatures.Key(hash), &result); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, signatureNotFound(hash)
+		}
+		return nil, err
+	}
+	result.SessionID = hash
+	return &result, nil
+}
+
+// ListKeys list the DKG results held by this node, in hash order
+func (d *kvHandler) ListKeys(filter types.KeyFilter) (*types.KeyPage, error) {
+	if err := validateKeyFilter(filter); err != nil {
+		return nil, err
+	}
+	limit := pageSize(filter.Limit)
+	after := ""
+	if filter.Cursor != "" {
+		after = NamespaceKeys.Key(filter.Cursor)
+	}
+
+	page := &types.KeyPage{Keys: []types.KeySummary{}}
+	err := d.fsm.ScanFrom(NamespaceKeys.Prefix(), after, func(key string, value []byte) error {
+		var record types.DKGResult
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if !matchKey(filter, &record) {
+			return nil
+		}
+		if len(page.Keys) == limit {
+			page.NextCursor = page.Keys[limit-1].Hash
+			return errStopScan
+		}
+		page.Keys = append(page.Keys, keySummary(strings.TrimPrefix(key, NamespaceKeys.Prefix()), &record))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetSchemaVersion get the keyspace layout version of the database
+func (d *kvHandler) GetSchemaVersion() (int, error) {
+	return schemaVersion(d.fsm)
+}
+
+// SetAccessRule creates or replaces the access rule of rule.Subject.
+func (d *kvHandler) SetAccessRule(rule *types.AccessRule) error {
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now().Unix()
+	return d.fsm.Set(NamespaceACL.Key(rule.Subject), rule)
+}
+
+// GetAccessRule returns the access rule of subject, or ErrNotFound.
+func (d *kvHandler) GetAccessRule(subject string) (*types.AccessRule, error) {
+	var rule types.AccessRule
+	if err := d.fsm.Load(NamespaceACL.Key(subject), &rule); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, accessRuleNotFound(subject)
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteAccessRule removes the access rule of subject, or returns ErrNotFound.
+func (d *kvHandler) DeleteAccessRule(subject string) error {
+	if _, err := d.GetAccessRule(subject); err != nil {
+		return err
+	}
+	return d.fsm.Delete(NamespaceACL.Key(subject))
+}
+
+// ListAccessRules returns every access rule, ordered by subject.
+func (d *kvHandler) ListAccessRules() ([]types.AccessRule, error) {
+	rules := []types.AccessRule{}
+	err := d.fsm.Scan(NamespaceACL.Prefix(), func(_ string, value []byte) error {
+		var rule types.AccessRule
+		if err := json.Unmarshal(value, &rule); err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, err
+}
+
+// SaveJob creates or replaces the record of job.ID.
+func (d *kvHandler) SaveJob(job *types.Job) error {
+	if err := validateJob(job); err != nil {
+		return err
+	}
+	return d.fsm.Set(NamespaceJobs.Key(job.ID), job)
+}
+
+// GetJob returns the job record of id, or ErrNotFound.
+func (d *kvHandler) GetJob(id string) (*types.Job, error) {
+	var job types.Job
+	if err := d.fsm.Load(NamespaceJobs.Key(id), &job); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, jobNotFound(id)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the jobs in status, or every job when status is empty,
+// oldest first.
+func (d *kvHandler) ListJobs(status types.JobStatus) ([]types.Job, error) {
+	jobs := []types.Job{}
+	err := d.fsm.Scan(NamespaceJobs.Prefix(), func(_ string, value []byte) error {
+		var job types.Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return err
+		}
+		if status == "" || job.Status == status {
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+// SaveWebhookDelivery creates or replaces the webhook delivery delivery.ID.
+func (d *kvHandler) SaveWebhookDelivery(delivery *types.WebhookDelivery) error {
+	if err := validateWebhookDelivery(delivery); err != nil {
+		return err
+	}
+	return d.fsm.Set(NamespaceWebhooks.Key(delivery.ID), delivery)
+}
+
+// GetWebhookDelivery returns the webhook delivery id, or ErrNotFound.
+func (d *kvHandler) GetWebhookDelivery(id string) (*types.WebhookDelivery, error) {
+	var delivery types.WebhookDelivery
+	if err := d.fsm.Load(NamespaceWebhooks.Key(id), &delivery); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, deliveryNotFound(id)
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns the webhook deliveries in status, or every
+// delivery when status is empty, oldest first.
+func (d *kvHandler) ListWebhookDeliveries(status types.WebhookStatus) ([]types.WebhookDelivery, error) {
+	deliveries := []types.WebhookDelivery{}
+	err := d.fsm.Scan(NamespaceWebhooks.Prefix(), func(_ string, value []byte) error {
+		var delivery types.WebhookDelivery
+		if err := json.Unmarshal(value, &delivery); err != nil {
+			return err
+		}
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortWebhookDeliveries(deliveries)
+	return deliveries, nil
+}
+
+// GetSignerConfig get cmd config
+func (d *kvHandler) GetSignerConfig(hash, pubkey string) (*types.SignerConfig, error) {
+	log.Info("GetSignerConfig", "hash", hash, "pubkey", pubkey)
+
+	resultDKG, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return nil, err
+	}
+	return signerConfig(d.kek, hash, pubkey, resultDKG)
+}
+
+// Backup writes a consistent snapshot of the store, if its backend supports it
+func (d *kvHandler) Backup(w io.Writer) error {
+	backuper, ok := d.fsm.(Backuper)
+	if !ok {
+		return ErrBackupUnsupported
+	}
+	return backuper.Backup(w)
+}
+
+// Rewrap re-seals every share with to and records it as the provider of the
+// store, in a single batch. The store then uses to, and closes its previous
+// provider.
+func (d *kvHandler) Rewrap(to KeyEncryptionProvider) error {
+	records := map[string]*types.DKGResult{}
+	err := d.fsm.Scan(NamespaceKeys.Prefix(), func(key string, value []byte) error {
+		var record types.DKGResult
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		records[strings.TrimPrefix(key, NamespaceKeys.Prefix())] = &record
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{kekKey: to.ID()}
+	for hash, record := range records {
+		if record.Share != "" {
+			if record.Share, err = rewrapShare(d.kek, to, record.Share, record.PublicKey); err != nil {
+				return fmt.Errorf("rewrap share of %s: %w", hash, err)
+			}
+		}
+		updates[NamespaceKeys.Key(hash)] = record
+	}
+	err = d.fsm.Scan(NamespaceShares.Prefix(), func(key string, value []byte) error {
+		var epoch types.ShareEpoch
+		if err := json.Unmarshal(value, &epoch); err != nil {
+			return err
+		}
+		hash := strings.TrimPrefix(key[:strings.LastIndex(key, "/")], NamespaceShares.Prefix())
+		record, ok := records[hash]
+		if !ok {
+			return fmt.Errorf("share epoch %s has no key", key)
+		}
+		if epoch.Share, err = rewrapShare(d.kek, to, epoch.Share, record.PublicKey); err != nil {
+			return fmt.Errorf("rewrap share epoch %d of %s: %w", epoch.Epoch, hash, err)
+		}
+		updates[key] = &epoch
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.fsm.SetBatch(updates); err != nil {
+		return err
+	}
+
+	log.Info("Rewrapped shares", "from", d.kek.ID(), "to", to.ID(), "keys", len(records))
+	previous := d.kek
+	d.kek = to
+	return closeKEK(previous)
+}
+
+func (d *kvHandler) Defer() {
+	if err := closeKEK(d.kek); err != nil {
+		log.Error("error close key encryption provider", "err", err)
+	}
+	if d.closer == nil {
+		return
+	}
+	if err := d.closer(); err != nil {
+		log.Error("error close store", "err", err)
+	} else {
+		log.Info("store closed")
+	}
+}
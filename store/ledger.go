@@ -0,0 +1,98 @@
+package store
+
+import (
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ledgerSeq orders ledger entries by start time. The entry ID breaks ties
+// between sessions started in the same second.
+func ledgerSeq(entry *types.LedgerEntry) string {
+	return fmt.Sprintf("%020d-%s", entry.StartedAt, entry.ID)
+}
+
+// parseLedgerCursor splits a ledger cursor, the ledgerSeq of the last entry of
+// a page, into its start time and entry ID.
+func parseLedgerCursor(cursor string) (int64, string, error) {
+	startedAt, id, ok := strings.Cut(cursor, "-")
+	if ok && len(startedAt) == 20 && id != "" {
+		if seconds, err := strconv.ParseInt(startedAt, 10, 64); err == nil {
+			return seconds, id, nil
+		}
+	}
+	return 0, "", tsserr.New(tsserr.InvalidInput, "invalid ledger cursor %q", cursor).With("cursor", cursor)
+}
+
+func ledgerKeyIndexPrefix(hash string) string {
+	return NamespaceLedgerIdx.Key("key/" + hash + "/")
+}
+
+func ledgerDigestIndexPrefix(digest string) string {
+	return NamespaceLedgerIdx.Key("digest/" + digest + "/")
+}
+
+// matchLedger reports whether an entry passes every filter of the query that is set.
+func matchLedger(query types.LedgerQuery, entry *types.LedgerEntry) bool {
+	if query.KeyHash != "" && entry.KeyHash != query.KeyHash {
+		return false
+	}
+	if query.Digest != "" && entry.Digest != query.Digest {
+		return false
+	}
+	if query.From != 0 && entry.StartedAt < query.From {
+		return false
+	}
+	if query.To != 0 && entry.StartedAt > query.To {
+		return false
+	}
+	return true
+}
+
+func validateLedgerEntry(entry *types.LedgerEntry) error {
+	if entry.ID == "" || entry.KeyHash == "" || entry.Digest == "" || entry.StartedAt == 0 {
+		return errors.New("ledger entry needs an id, key hash, digest and start time")
+	}
+	return nil
+}
+
+// QueryLedger returns the page of ledger entries matching query in start time
+// order, at most query.Limit of them, after query.Cursor.
+func QueryLedger(db HandlerData, query types.LedgerQuery) (*types.LedgerPage, error) {
+	limit := pageSize(query.Limit)
+	page := &types.LedgerPage{Entries: []types.LedgerEntry{}}
+	err := db.ScanLedger(query, func(entry *types.LedgerEntry) error {
+		if len(page.Entries) == limit {
+			page.NextCursor = ledgerSeq(&page.Entries[limit-1])
+			return errStopScan
+		}
+		page.Entries = append(page.Entries, *entry)
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ExportLedger writes every ledger entry matching query to w as JSON lines,
+// starting after query.Cursor, until ctx is done. query.Limit is ignored.
+func ExportLedger(ctx context.Context, db HandlerData, query types.LedgerQuery, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	err := db.ScanLedger(query, func(entry *types.LedgerEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return encoder.Encode(entry)
+	})
+	if err == errStopScan {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	defaultKeyPageSize = 50
+	maxKeyPageSize     = 1000
+)
+
+// pageSize clamps the requested page size of a listing.
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultKeyPageSize
+	}
+	if limit > maxKeyPageSize {
+		return maxKeyPageSize
+	}
+	return limit
+}
+
+// validateKeyFilter refuses filters that would otherwise quietly match
+// nothing, such as an address that is not hex.
+func validateKeyFilter(filter types.KeyFilter) error {
+	if filter.Address != "" && !common.IsHexAddress(filter.Address) {
+		return tsserr.New(tsserr.InvalidInput, "invalid address filter %q", filter.Address).With("address", filter.Address)
+	}
+	return nil
+}
+
+// matchKey reports whether a DKG result passes every filter that is set.
+func matchKey(filter types.KeyFilter, record *types.DKGResult) bool {
+	if filter.Address != "" && common.HexToAddress(filter.Address) != record.Address {
+		return false
+	}
+	if filter.Pubkey != "" && !strings.EqualFold(strings.TrimPrefix(filter.Pubkey, "0x"), record.PublicKey) {
+		return false
+	}
+	if filter.State != "" && record.State != filter.State {
+		return false
+	}
+	if filter.CreatedAfter != 0 && record.CreatedAt < filter.CreatedAfter {
+		return false
+	}
+	if filter.CreatedBefore != 0 && record.CreatedAt > filter.CreatedBefore {
+		return false
+	}
+	return true
+}
+
+func keySummary(hash string, record *types.DKGResult) types.KeySummary {
+	return types.KeySummary{
+		Hash:      hash,
+		PublicKey: record.PublicKey,
+		Address:   record.Address,
+		CreatedAt: record.CreatedAt,
+		State:     record.State,
+	}
+}
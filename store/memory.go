@@ -0,0 +1,138 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryFSM is an in-memory kvStore. Values are kept JSON encoded, exactly as
+// badger stores them, and scans run over a snapshot like a badger read
+// transaction.
+type memoryFSM struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+}
+
+func newMemoryFSM() *memoryFSM {
+	return &memoryFSM{values: map[string][]byte{}}
+}
+
+func (m *memoryFSM) Load(key string, v interface{}) error {
+	m.mu.RLock()
+	value, ok := m.values[key]
+	m.mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(value, v)
+}
+
+func (m *memoryFSM) Set(key string, value interface{}) error {
+	return m.SetBatch(map[string]interface{}{key: value})
+}
+
+func (m *memoryFSM) SetBatch(values map[string]interface{}) error {
+	encoded, err := encodeValues(values)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, value := range encoded {
+		m.values[key] = value
+	}
+	return nil
+}
+
+func (m *memoryFSM) Insert(values map[string]interface{}) error {
+	encoded, err := encodeValues(values)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range encoded {
+		if _, ok := m.values[key]; ok {
+			return fmt.Errorf("%w: %s", ErrRecordExists, key)
+		}
+	}
+	for key, value := range encoded {
+		m.values[key] = value
+	}
+	return nil
+}
+
+func (m *memoryFSM) Rename(from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[to]; ok {
+		return fmt.Errorf("key %s already exists", to)
+	}
+	value, ok := m.values[from]
+	if !ok {
+		return ErrNotFound
+	}
+	m.values[to] = value
+	delete(m.values, from)
+	return nil
+}
+
+func (m *memoryFSM) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+func (m *memoryFSM) Scan(prefix string, fn func(key string, value []byte) error) error {
+	return m.ScanFrom(prefix, "", fn)
+}
+
+func (m *memoryFSM) ScanFrom(prefix, after string, fn func(key string, value []byte) error) error {
+	m.mu.RLock()
+	var keys []string
+	for key := range m.values {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	snapshot := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		snapshot[key] = m.values[key]
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, snapshot[key]); err != nil {
+			if err == errStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeValues(values map[string]interface{}) (map[string][]byte, error) {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		encoded[key] = data
+	}
+	return encoded, nil
+}
+
+// NewMemoryDB returns a HandlerData that keeps its state in memory, for tests
+// and throwaway nodes. Every call returns an independent store.
+func NewMemoryDB(kek KeyEncryptionProvider) (HandlerData, error) {
+	return newKVHandler(newMemoryFSM(), kek, nil)
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"alice-tss/metrics"
+	"alice-tss/types"
+	"io"
+	"time"
+
+	"github.com/getamis/alice/crypto/tss/dkg"
+	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
+)
+
+// timedStore records the latency of every operation of a store in the
+// metrics, by method name. Scans include the time spent in their callback.
+type timedStore struct {
+	HandlerData
+}
+
+// Backup keeps the backups of the store available through the wrapper.
+func (d timedStore) Backup(w io.Writer) error {
+	defer metrics.StoreOp("Backup", time.Now())
+	return Backup(d.HandlerData, w)
+}
+
+func (d timedStore) SaveDKGResultData(hash string, result *dkg.Result) error {
+	defer metrics.StoreOp("SaveDKGResultData", time.Now())
+	return d.HandlerData.SaveDKGResultData(hash, result)
+}
+
+func (d timedStore) GetSignerConfig(hash, pubkey string) (*types.SignerConfig, error) {
+	defer metrics.StoreOp("GetSignerConfig", time.Now())
+	return d.HandlerData.GetSignerConfig(hash, pubkey)
+}
+
+func (d timedStore) UpdateDKGResultData(hash string, epoch uint32, result *reshare.Result) error {
+	defer metrics.StoreOp("UpdateDKGResultData", time.Now())
+	return d.HandlerData.UpdateDKGResultData(hash, epoch, result)
+}
+
+func (d timedStore) GetShareEpochs(hash string) ([]uint32, error) {
+	defer metrics.StoreOp("GetShareEpochs", time.Now())
+	return d.HandlerData.GetShareEpochs(hash)
+}
+
+func (d timedStore) RollbackShareEpoch(hash string, epoch uint32) error {
+	defer metrics.StoreOp("RollbackShareEpoch", time.Now())
+	return d.HandlerData.RollbackShareEpoch(hash, epoch)
+}
+
+func (d timedStore) SetKeyState(hash string, state types.KeyState) error {
+	defer metrics.StoreOp("SetKeyState", time.Now())
+	return d.HandlerData.SetKeyState(hash, state)
+}
+
+func (d timedStore) ExportKey(hash string) (*types.ExportedKey, error) {
+	defer metrics.StoreOp("ExportKey", time.Now())
+	return d.HandlerData.ExportKey(hash)
+}
+
+func (d timedStore) ImportKey(key *types.ExportedKey) error {
+	defer metrics.StoreOp("ImportKey", time.Now())
+	return d.HandlerData.ImportKey(key)
+}
+
+func (d timedStore) SaveSignerResultData(hash string, result types.RVSignature) error {
+	defer metrics.StoreOp("SaveSignerResultData", time.Now())
+	return d.HandlerData.SaveSignerResultData(hash, result)
+}
+
+func (d timedStore) AppendLedgerEntry(entry *types.LedgerEntry) error {
+	defer metrics.StoreOp("AppendLedgerEntry", time.Now())
+	return d.HandlerData.AppendLedgerEntry(entry)
+}
+
+func (d timedStore) ScanLedger(query types.LedgerQuery, fn func(entry *types.LedgerEntry) error) error {
+	defer metrics.StoreOp("ScanLedger", time.Now())
+	return d.HandlerData.ScanLedger(query, fn)
+}
+
+func (d timedStore) GetDKGResultData(hash string) (*types.DKGResult, error) {
+	defer metrics.StoreOp("GetDKGResultData", time.Now())
+	return d.HandlerData.GetDKGResultData(hash)
+}
+
+func (d timedStore) GetSignerResultData(hash string) (*types.RVSignature, error) {
+	defer metrics.StoreOp("GetSignerResultData", time.Now())
+	return d.HandlerData.GetSignerResultData(hash)
+}
+
+func (d timedStore) ListKeys(filter types.KeyFilter) (*types.KeyPage, error) {
+	defer metrics.StoreOp("ListKeys", time.Now())
+	return d.HandlerData.ListKeys(filter)
+}
+
+func (d timedStore) GetSchemaVersion() (int, error) {
+	defer metrics.StoreOp("GetSchemaVersion", time.Now())
+	return d.HandlerData.GetSchemaVersion()
+}
+
+func (d timedStore) Rewrap(to KeyEncryptionProvider) error {
+	defer metrics.StoreOp("Rewrap", time.Now())
+	return d.HandlerData.Rewrap(to)
+}
+
+func (d timedStore) SetAccessRule(rule *types.AccessRule) error {
+	defer metrics.StoreOp("SetAccessRule", time.Now())
+	return d.HandlerData.SetAccessRule(rule)
+}
+
+func (d timedStore) GetAccessRule(subject string) (*types.AccessRule, error) {
+	defer metrics.StoreOp("GetAccessRule", time.Now())
+	return d.HandlerData.GetAccessRule(subject)
+}
+
+func (d timedStore) DeleteAccessRule(subject string) error {
+	defer metrics.StoreOp("DeleteAccessRule", time.Now())
+	return d.HandlerData.DeleteAccessRule(subject)
+}
+
+func (d timedStore) ListAccessRules() ([]types.AccessRule, error) {
+	defer metrics.StoreOp("ListAccessRules", time.Now())
+	return d.HandlerData.ListAccessRules()
+}
+
+func (d timedStore) SaveJob(job *types.Job) error {
+	defer metrics.StoreOp("SaveJob", time.Now())
+	return d.HandlerData.SaveJob(job)
+}
+
+func (d timedStore) GetJob(id string) (*types.Job, error) {
+	defer metrics.StoreOp("GetJob", time.Now())
+	return d.HandlerData.GetJob(id)
+}
+
+func (d timedStore) ListJobs(status types.JobStatus) ([]types.Job, error) {
+	defer metrics.StoreOp("ListJobs", time.Now())
+	return d.HandlerData.ListJobs(status)
+}
+
+func (d timedStore) SaveWebhookDelivery(delivery *types.WebhookDelivery) error {
+	defer metrics.StoreOp("SaveWebhookDelivery", time.Now())
+	return d.HandlerData.SaveWebhookDelivery(delivery)
+}
+
+func (d timedStore) GetWebhookDelivery(id string) (*types.WebhookDelivery, error) {
+	defer metrics.StoreOp("GetWebhookDelivery", time.Now())
+	return d.HandlerData.GetWebhookDelivery(id)
+}
+
+func (d timedStore) ListWebhookDeliveries(status types.WebhookStatus) ([]types.WebhookDelivery, error) {
+	defer metrics.StoreOp("ListWebhookDeliveries", time.Now())
+	return d.HandlerData.ListWebhookDeliveries(status)
+}
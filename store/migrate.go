@@ -0,0 +1,253 @@
+package store
+
+import (
+	"alice-tss/types"
+	"alice-tss/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getamis/sirius/log"
+)
+
+// legacyShareEnvelopeMarker was written by the first share re-encryption,
+// before the schema version existed.
+const legacyShareEnvelopeMarker = "__migration/share-envelope-v1"
+
+type migration struct {
+	version int
+	name    string
+	run     func(d *kvHandler) error
+}
+
+// migrations upgrade a database one schema version at a time. Every step must
+// be idempotent, since a crash can interrupt it before the version is stored.
+var migrations = []migration{
+	{version: 1, name: "seal legacy shares", run: migrateShareEncryption},
+	{version: 2, name: "namespaced keyspace", run: migrateNamespaces},
+	{version: 3, name: "share epochs", run: migrateShareEpochs},
+	{version: 4, name: "key states", run: migrateKeyStates},
+	// Access rules live in a namespace of their own; there is nothing to move.
+	{version: 5, name: "access rules", run: func(*kvHandler) error { return nil }},
+	// Likewise for job records.
+	{version: 6, name: "jobs", run: func(*kvHandler) error { return nil }},
+	{version: 7, name: "webhook deliveries", run: func(*kvHandler) error { return nil }},
+	// Job records are JSON, an error type is a new field.
+	{version: 8, name: "job error types", run: func(*kvHandler) error { return nil }},
+	{version: 9, name: "job fingerprints", run: func(*kvHandler) error { return nil }},
+}
+
+// migrate brings the database up to SchemaVersion.
+func migrate(d *kvHandler) error {
+	fsm := d.fsm
+	version, err := schemaVersion(fsm)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		log.Info("Migrating store", "from", version, "to", m.version, "migration", m.name)
+		if err := m.run(d); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if err := fsm.Set(schemaVersionKey, m.version); err != nil {
+			return err
+		}
+		version = m.version
+	}
+	return nil
+}
+
+// schemaVersion returns the stored schema version, or 0 for databases written
+// before versioning.
+func schemaVersion(fsm kvStore) (int, error) {
+	var version int
+	err := fsm.Load(schemaVersionKey, &version)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// migrateShareEncryption re-encrypts every share still stored with the legacy
+// unauthenticated AES-CFB scheme into a sealed envelope. A record that cannot
+// be decrypted aborts the migration.
+func migrateShareEncryption(d *kvHandler) error {
+	fsm := d.fsm
+	legacy := map[string]*types.DKGResult{}
+	err := fsm.Scan("", func(key string, value []byte) error {
+		var record types.DKGResult
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil
+		}
+		if record.Share == "" || record.PublicKey == "" || utils.IsSealed(record.Share) {
+			return nil
+		}
+		legacy[key] = &record
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(legacy) == 0 {
+		return nil
+	}
+	secret, err := legacySecret(d.kek)
+	if err != nil {
+		return err
+	}
+	for key, record := range legacy {
+		share, err := utils.Decrypt(record.Share, secret, record.PublicKey)
+		if err != nil {
+			return fmt.Errorf("decrypt legacy share %s: %w", key, err)
+		}
+		if _, err := hex.DecodeString(share); err != nil {
+			return fmt.Errorf("legacy share %s does not decrypt with this node key", key)
+		}
+		record.Share, err = d.kek.Seal([]byte(share), []byte(record.PublicKey))
+		if err != nil {
+			return err
+		}
+		if err := fsm.Set(key, record); err != nil {
+			return err
+		}
+		log.Info("Re-encrypted legacy share", "hash", key)
+	}
+	return nil
+}
+
+// migrateNamespaces moves records of the flat layout, where DKG results and
+// signatures were both keyed by a bare hash, into their namespaces.
+func migrateNamespaces(d *kvHandler) error {
+	fsm := d.fsm
+	moves := map[string]string{}
+	err := fsm.Scan("", func(key string, value []byte) error {
+		if hasNamespace(key) {
+			return nil
+		}
+		if key == legacyShareEnvelopeMarker {
+			moves[key] = ""
+			return nil
+		}
+
+		var record struct {
+			types.DKGResult
+			types.RVSignature
+		}
+		if err := json.Unmarshal(value, &record); err != nil {
+			log.Warn("Skip unknown record", "key", key, "err", err)
+			return nil
+		}
+		switch {
+		case record.PublicKey != "":
+			moves[key] = NamespaceKeys.Key(key)
+		case record.R != "" && record.S != "":
+			moves[key] = NamespaceSignatures.Key(key)
+		default:
+			log.Warn("Skip unknown record", "key", key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for from, to := range moves {
+		if to == "" {
+			if err := fsm.Delete(from); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fsm.Rename(from, to); err != nil {
+			return fmt.Errorf("move %s to %s: %w", from, to, err)
+		}
+	}
+	log.Info("Moved records into namespaces", "count", len(moves))
+	return nil
+}
+
+// migrateShareEpochs turns the share of every key written before epochs
+// existed into its first epoch.
+func migrateShareEpochs(d *kvHandler) error {
+	fsm := d.fsm
+	records := map[string]*types.DKGResult{}
+	err := fsm.Scan(NamespaceKeys.Prefix(), func(key string, value []byte) error {
+		var record types.DKGResult
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if record.Epoch == 0 {
+			records[strings.TrimPrefix(key, NamespaceKeys.Prefix())] = &record
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for hash, record := range records {
+		record.Epoch = 1
+		err := fsm.SetBatch(map[string]interface{}{
+			NamespaceKeys.Key(hash): record,
+			shareEpochKey(hash, record.Epoch): &types.ShareEpoch{
+				Epoch:     record.Epoch,
+				Share:     record.Share,
+				BKs:       record.BKs,
+				CreatedAt: record.CreatedAt,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("Created first share epochs", "count", len(records))
+	return nil
+}
+
+// migrateKeyStates marks every key written before lifecycle states existed
+// as active.
+func migrateKeyStates(d *kvHandler) error {
+	fsm := d.fsm
+	records := map[string]*types.DKGResult{}
+	err := fsm.Scan(NamespaceKeys.Prefix(), func(key string, value []byte) error {
+		var record types.DKGResult
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if record.State == "" {
+			records[key] = &record
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, record := range records {
+		record.State = types.KeyStateActive
+		if err := fsm.Set(key, record); err != nil {
+			return err
+		}
+	}
+	log.Info("Marked keys active", "count", len(records))
+	return nil
+}
+
+func hasNamespace(key string) bool {
+	for _, ns := range namespaces {
+		if strings.HasPrefix(key, ns.Prefix()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"alice-tss/utils"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/getamis/alice/crypto/tss/dkg"
+	"github.com/getamis/sirius/log"
+)
+
+// ErrKeyDestroyed is returned when the share of a destroyed key is requested.
+var ErrKeyDestroyed = errors.New("key is destroyed")
+
+// newDKGRecord builds the stored form of a fresh DKG result, at epoch 1 and
+// with its share sealed by kek.
+func newDKGRecord(kek KeyEncryptionProvider, hash string, result *dkg.Result) (*types.DKGResult, error) {
+	pubkey := crypto.CompressPubkey(result.PublicKey.ToPubKey())
+	log.Info("SaveDKGResultData", "hash", hash, "pubkey", hex.EncodeToString(pubkey))
+
+	encryptedShare, err := sealShare(kek, result.Share, hex.EncodeToString(pubkey))
+	if err != nil {
+		return nil, err
+	}
+
+	data := &types.DKGResult{
+		CreatedAt: time.Now().Unix(),
+		Address:   crypto.PubkeyToAddress(*result.PublicKey.ToPubKey()),
+		Share:     encryptedShare,
+		PublicKey: hex.EncodeToString(pubkey),
+		BKs:       map[string]types.BK{},
+		Pubkey: types.Pubkey{
+			X: hex.EncodeToString(result.PublicKey.GetX().Bytes()),
+			Y: hex.EncodeToString(result.PublicKey.GetY().Bytes()),
+		},
+		Epoch: 1,
+		State: types.KeyStateActive,
+	}
+	for s, parameter := range result.Bks {
+		data.BKs[s] = types.BK{
+			X:    parameter.GetX().String(),
+			Rank: parameter.GetRank(),
+		}
+	}
+	return data, nil
+}
+
+// signerConfig checks that a stored DKG result belongs to pubkey and opens its
+// current share.
+func signerConfig(kek KeyEncryptionProvider, hash, pubkey string, resultDKG *types.DKGResult) (*types.SignerConfig, error) {
+	if resultDKG.State == types.KeyStateDestroyed {
+		return nil, keyDestroyed(hash)
+	}
+	publicKey := &ecdsa.PublicKey{
+		X: big.NewInt(0).SetBytes(common.FromHex(resultDKG.Pubkey.X)),
+		Y: big.NewInt(0).SetBytes(common.FromHex(resultDKG.Pubkey.Y)),
+	}
+	if hex.EncodeToString(crypto.CompressPubkey(publicKey)) != pubkey {
+		return nil, tsserr.New(tsserr.PubkeyMismatch, "pubkey %s does not match key %s", pubkey, hash).
+			With("hash", hash).With("pubkey", pubkey)
+	}
+
+	share, err := openShare(kek, resultDKG.Share, pubkey)
+	if err != nil {
+		log.Error("Cannot open share", "hash", hash, "err", err)
+		return nil, fmt.Errorf("open share of %s: %w", hash, err)
+	}
+
+	defer utils.ZeroInt(share)
+
+	signerCfg := &types.SignerConfig{
+		Share: share.String(),
+		Pubkey: types.Pubkey{
+			X: big.NewInt(0).SetBytes(common.FromHex(resultDKG.Pubkey.X)).String(),
+			Y: big.NewInt(0).SetBytes(common.FromHex(resultDKG.Pubkey.Y)).String(),
+		},
+		BKs:   resultDKG.BKs,
+		Epoch: resultDKG.Epoch,
+		State: resultDKG.State,
+	}
+
+	return signerCfg, nil
+}
+
+// sealShare encrypts a share with the key encryption provider, bound to the compressed pubkey
+// of the key it belongs to.
+func sealShare(kek KeyEncryptionProvider, share *big.Int, pubkey string) (string, error) {
+	raw := share.Bytes()
+	defer utils.Zero(raw)
+	plain := make([]byte, hex.EncodedLen(len(raw)))
+	defer utils.Zero(plain)
+	hex.Encode(plain, raw)
+	return kek.Seal(plain, []byte(pubkey))
+}
+
+// openShare reverses sealShare. Records that fail authentication are reported
+// as errors instead of being turned into a wrong share.
+func openShare(kek KeyEncryptionProvider, sealed, pubkey string) (*big.Int, error) {
+	plain, err := kek.Open(sealed, []byte(pubkey))
+	if err != nil {
+		return nil, err
+	}
+	defer utils.Zero(plain)
+	share := make([]byte, hex.DecodedLen(len(plain)))
+	defer utils.Zero(share)
+	if _, err := hex.Decode(share, plain); err != nil {
+		return nil, utils.ErrCorruptedEnvelope
+	}
+	return new(big.Int).SetBytes(share), nil
+}
+
+// exportKey opens every share epoch of a stored key.
+func exportKey(kek KeyEncryptionProvider, hash string, record *types.DKGResult, epochs []*types.ShareEpoch) (*types.ExportedKey, error) {
+	key := &types.ExportedKey{
+		Hash:      hash,
+		PublicKey: record.PublicKey,
+		Pubkey:    record.Pubkey,
+		Address:   record.Address,
+		CreatedAt: record.CreatedAt,
+		Epoch:     record.Epoch,
+		State:     record.State,
+		Shares:    make([]types.ExportedShare, 0, len(epochs)),
+	}
+	for _, epoch := range epochs {
+		share, err := openShare(kek, epoch.Share, record.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("open share epoch %d of %s: %w", epoch.Epoch, hash, err)
+		}
+		key.Shares = append(key.Shares, types.ExportedShare{
+			Epoch:     epoch.Epoch,
+			Share:     share.String(),
+			BKs:       epoch.BKs,
+			CreatedAt: epoch.CreatedAt,
+		})
+		utils.ZeroInt(share)
+	}
+	return key, nil
+}
+
+// importKey checks an exported key and seals its shares with kek. It
+// returns the key record and its share epochs, ready to be stored.
+func importKey(kek KeyEncryptionProvider, key *types.ExportedKey) (*types.DKGResult, []*types.ShareEpoch, error) {
+	publicKey := &ecdsa.PublicKey{
+		X: big.NewInt(0).SetBytes(common.FromHex(key.Pubkey.X)),
+		Y: big.NewInt(0).SetBytes(common.FromHex(key.Pubkey.Y)),
+	}
+	if !crypto.S256().IsOnCurve(publicKey.X, publicKey.Y) ||
+		hex.EncodeToString(crypto.CompressPubkey(publicKey)) != key.PublicKey {
+		return nil, nil, tsserr.New(tsserr.PubkeyMismatch, "key %s: pubkey %s does not match its point", key.Hash, key.PublicKey).
+			With("hash", key.Hash).With("pubkey", key.PublicKey)
+	}
+
+	record := &types.DKGResult{
+		Pubkey:    key.Pubkey,
+		PublicKey: key.PublicKey,
+		Address:   crypto.PubkeyToAddress(*publicKey),
+		Epoch:     key.Epoch,
+		CreatedAt: key.CreatedAt,
+		State:     key.State,
+	}
+	switch {
+	case record.State == "":
+		record.State = types.KeyStateActive
+	case record.State == types.KeyStateDestroyed || !record.State.Valid():
+		return nil, nil, fmt.Errorf("key %s: cannot import a key in state %q", key.Hash, key.State)
+	}
+	epochs := make([]*types.ShareEpoch, 0, len(key.Shares))
+	for _, exported := range key.Shares {
+		share, ok := new(big.Int).SetString(exported.Share, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("key %s: invalid share at epoch %d", key.Hash, exported.Epoch)
+		}
+		sealed, err := sealShare(kek, share, key.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		epoch := &types.ShareEpoch{
+			Epoch:     exported.Epoch,
+			Share:     sealed,
+			BKs:       exported.BKs,
+			CreatedAt: exported.CreatedAt,
+		}
+		if epoch.Epoch == record.Epoch {
+			record.Share = epoch.Share
+			record.BKs = epoch.BKs
+		}
+		epochs = append(epochs, epoch)
+	}
+	if record.Share == "" {
+		return nil, nil, fmt.Errorf("key %s: no share for current epoch %d", key.Hash, key.Epoch)
+	}
+	return record, epochs, nil
+}
+
+// rewrapShare opens a sealed share with from and seals it again with to.
+func rewrapShare(from, to KeyEncryptionProvider, sealed, pubkey string) (string, error) {
+	plain, err := from.Open(sealed, []byte(pubkey))
+	if err != nil {
+		return "", err
+	}
+	defer utils.Zero(plain)
+	return to.Seal(plain, []byte(pubkey))
+}
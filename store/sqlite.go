@@ -0,0 +1,974 @@
+package store
+
+import (
+	"alice-tss/types"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/getamis/alice/crypto/tss/dkg"
+	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
+	"github.com/getamis/sirius/log"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of a new SQLite store, at SchemaVersion. The
+// current share of a key is the shares row whose epoch matches keys.epoch;
+// sessions holds the signing ledger.
+var sqliteSchema = []string{
+	`CREATE TABLE keys (
+		hash       TEXT PRIMARY KEY,
+		public_key TEXT NOT NULL,
+		address    TEXT NOT NULL,
+		pubkey_x   TEXT NOT NULL,
+		pubkey_y   TEXT NOT NULL,
+		epoch      INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		state      TEXT NOT NULL DEFAULT 'active'
+	)`,
+	`CREATE INDEX keys_address ON keys (address)`,
+	`CREATE INDEX keys_public_key ON keys (public_key)`,
+	`CREATE TABLE shares (
+		key_hash   TEXT NOT NULL REFERENCES keys (hash),
+		epoch      INTEGER NOT NULL,
+		share      TEXT NOT NULL,
+		bks        TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (key_hash, epoch)
+	)`,
+	`CREATE TABLE signatures (
+		hash    TEXT PRIMARY KEY,
+		r       TEXT NOT NULL,
+		s       TEXT NOT NULL,
+		message TEXT NOT NULL
+	)`,
+	`CREATE TABLE sessions (
+		id           TEXT PRIMARY KEY,
+		key_hash     TEXT NOT NULL,
+		pubkey       TEXT NOT NULL,
+		digest       TEXT NOT NULL,
+		r            TEXT NOT NULL,
+		s            TEXT NOT NULL,
+		participants TEXT NOT NULL,
+		initiator    TEXT NOT NULL,
+		started_at   INTEGER NOT NULL,
+		finished_at  INTEGER NOT NULL,
+		outcome      TEXT NOT NULL,
+		error        TEXT NOT NULL
+	)`,
+	`CREATE INDEX sessions_started_at ON sessions (started_at, id)`,
+	`CREATE INDEX sessions_key_hash ON sessions (key_hash, started_at, id)`,
+	`CREATE INDEX sessions_digest ON sessions (digest, started_at, id)`,
+	`CREATE TABLE access_rules (
+		subject    TEXT PRIMARY KEY,
+		roles      TEXT NOT NULL,
+		keys       TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+	`CREATE TABLE jobs (
+		id           TEXT PRIMARY KEY,
+		kind         TEXT NOT NULL,
+		status       TEXT NOT NULL,
+		state        TEXT NOT NULL,
+		key_hash     TEXT NOT NULL,
+		owner        TEXT NOT NULL,
+		result       TEXT NOT NULL,
+		error        TEXT NOT NULL,
+		created_at   INTEGER NOT NULL,
+		updated_at   INTEGER NOT NULL,
+		finished_at  INTEGER NOT NULL,
+		callback_url TEXT NOT NULL DEFAULT '',
+		error_type   TEXT NOT NULL DEFAULT '',
+		fingerprint  TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX jobs_status ON jobs (status, created_at, id)`,
+	`CREATE TABLE webhook_deliveries (
+		id              TEXT PRIMARY KEY,
+		job_id          TEXT NOT NULL,
+		url             TEXT NOT NULL,
+		client          TEXT NOT NULL,
+		payload         TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL,
+		next_attempt_at INTEGER NOT NULL,
+		last_error      TEXT NOT NULL,
+		created_at      INTEGER NOT NULL,
+		updated_at      INTEGER NOT NULL,
+		delivered_at    INTEGER NOT NULL
+	)`,
+	`CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status, created_at, id)`,
+	`CREATE TABLE meta (
+		name  TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+}
+
+// sqliteMigrations upgrade a database written by an older build, one schema
+// version at a time. SQLite stores were introduced at schema version 3.
+var sqliteMigrations = []struct {
+	version int
+	stmts   []string
+}{
+	{version: 4, stmts: []string{`ALTER TABLE keys ADD COLUMN state TEXT NOT NULL DEFAULT 'active'`}},
+	{version: 5, stmts: []string{`CREATE TABLE access_rules (
+		subject    TEXT PRIMARY KEY,
+		roles      TEXT NOT NULL,
+		keys       TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`}},
+	{version: 6, stmts: []string{`CREATE TABLE jobs (
+		id          TEXT PRIMARY KEY,
+		kind        TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		state       TEXT NOT NULL,
+		key_hash    TEXT NOT NULL,
+		owner       TEXT NOT NULL,
+		result      TEXT NOT NULL,
+		error       TEXT NOT NULL,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL,
+		finished_at INTEGER NOT NULL
+	)`,
+		`CREATE INDEX jobs_status ON jobs (status, created_at, id)`}},
+	{version: 7, stmts: []string{`ALTER TABLE jobs ADD COLUMN callback_url TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE webhook_deliveries (
+		id              TEXT PRIMARY KEY,
+		job_id          TEXT NOT NULL,
+		url             TEXT NOT NULL,
+		client          TEXT NOT NULL,
+		payload         TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL,
+		next_attempt_at INTEGER NOT NULL,
+		last_error      TEXT NOT NULL,
+		created_at      INTEGER NOT NULL,
+		updated_at      INTEGER NOT NULL,
+		delivered_at    INTEGER NOT NULL
+	)`,
+		`CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status, created_at, id)`}},
+	{version: 8, stmts: []string{`ALTER TABLE jobs ADD COLUMN error_type TEXT NOT NULL DEFAULT ''`}},
+	{version: 9, stmts: []string{`ALTER TABLE jobs ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`}},
+}
+
+// selectKey reads a key with its current share. Destroyed keys have no share
+// left, hence the outer join.
+const selectKey = `SELECT k.public_key, k.address, k.pubkey_x, k.pubkey_y, k.epoch, k.created_at, k.state,
+		COALESCE(s.share, ''), COALESCE(s.bks, 'null')
+	FROM keys k LEFT JOIN shares s ON s.key_hash = k.hash AND s.epoch = k.epoch`
+
+// sqliteDB implements HandlerData on a SQLite database, so that node state can
+// be inspected and backed up with standard SQL tooling. Multi-record updates
+// run in a single transaction.
+type sqliteDB struct {
+	db  *sql.DB
+	kek KeyEncryptionProvider
+}
+
+// NewSQLiteDB opens, and creates if needed, the SQLite database file at path.
+func NewSQLiteDB(path string, kek KeyEncryptionProvider) (HandlerData, error) {
+	log.Info("sqlite path", "path", path)
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Opaque:   path,
+		RawQuery: "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=secure_delete(1)",
+	}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &sqliteDB{db: db, kek: kek}
+	if err := d.init(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init sqlite store: %w", err)
+	}
+	return d, nil
+}
+
+// init creates the tables of a new database, or migrates an existing one to
+// SchemaVersion, and records its schema version and key encryption provider.
+func (d *sqliteDB) init() error {
+	return d.update(func(tx *sql.Tx) error {
+		if err := d.initSchema(tx); err != nil {
+			return err
+		}
+
+		var stored string
+		err := tx.QueryRow(`SELECT value FROM meta WHERE name = 'kek'`).Scan(&stored)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		var hasShares bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM shares)`).Scan(&hasShares); err != nil {
+			return err
+		}
+		record, err := checkKEK(stored, hasShares, d.kek)
+		if err != nil || !record {
+			return err
+		}
+		return setSQLiteKEK(tx, d.kek.ID())
+	})
+}
+
+func (d *sqliteDB) initSchema(tx *sql.Tx) error {
+	var created bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'meta')`).Scan(&created)
+	if err != nil {
+		return err
+	}
+	if !created {
+		for _, stmt := range sqliteSchema {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return setSQLiteSchemaVersion(tx, SchemaVersion)
+	}
+
+	version, err := d.schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+	for _, m := range sqliteMigrations {
+		if m.version <= version {
+			continue
+		}
+		log.Info("Migrating store", "from", version, "to", m.version)
+		for _, stmt := range m.stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("migration %d: %w", m.version, err)
+			}
+		}
+		version = m.version
+	}
+	return setSQLiteSchemaVersion(tx, version)
+}
+
+func setSQLiteSchemaVersion(tx *sql.Tx, version int) error {
+	_, err := tx.Exec(`INSERT INTO meta (name, value) VALUES ('schema_version', ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`, version)
+	return err
+}
+
+func setSQLiteKEK(tx *sql.Tx, id string) error {
+	_, err := tx.Exec(`INSERT INTO meta (name, value) VALUES ('kek', ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`, id)
+	return err
+}
+
+// update runs fn in a transaction, committed only when fn succeeds.
+func (d *sqliteDB) update(fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (d *sqliteDB) schemaVersion(q sqlQueryer) (int, error) {
+	var version int
+	err := q.QueryRow(`SELECT value FROM meta WHERE name = 'schema_version'`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (d *sqliteDB) loadKey(q sqlQueryer, hash string) (*types.DKGResult, error) {
+	var (
+		record  types.DKGResult
+		address string
+		bks     string
+	)
+	err := q.QueryRow(selectKey+` WHERE k.hash = ?`, hash).Scan(&record.PublicKey, &address,
+		&record.Pubkey.X, &record.Pubkey.Y, &record.Epoch, &record.CreatedAt, &record.State, &record.Share, &bks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, keyNotFound(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.Address = common.HexToAddress(address)
+	if err := json.Unmarshal([]byte(bks), &record.BKs); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func insertShare(tx *sql.Tx, hash string, epoch *types.ShareEpoch) error {
+	bks, err := json.Marshal(epoch.BKs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO shares (key_hash, epoch, share, bks, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (key_hash, epoch) DO UPDATE SET share = excluded.share, bks = excluded.bks, created_at = excluded.created_at`,
+		hash, epoch.Epoch, epoch.Share, string(bks), epoch.CreatedAt)
+	return err
+}
+
+// SaveDKGResultData save dkg result data
+func (d *sqliteDB) SaveDKGResultData(hash string, result *dkg.Result) error {
+	data, err := newDKGRecord(d.kek, hash, result)
+	if err != nil {
+		log.Error("SaveDKGResultData", "err", err)
+		return err
+	}
+
+	return d.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO keys (hash, public_key, address, pubkey_x, pubkey_y, epoch, created_at, state)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (hash) DO UPDATE SET public_key = excluded.public_key, address = excluded.address,
+				pubkey_x = excluded.pubkey_x, pubkey_y = excluded.pubkey_y, epoch = excluded.epoch,
+				created_at = excluded.created_at, state = excluded.state`,
+			hash, data.PublicKey, data.Address.Hex(), data.Pubkey.X, data.Pubkey.Y, data.Epoch, data.CreatedAt, data.State)
+		if err != nil {
+			return err
+		}
+		return insertShare(tx, hash, &types.ShareEpoch{
+			Epoch:     data.Epoch,
+			Share:     data.Share,
+			BKs:       data.BKs,
+			CreatedAt: data.CreatedAt,
+		})
+	})
+}
+
+// UpdateDKGResultData store a reshared share as share epoch epoch of the key,
+// which must be newer than the current one. Every holder of the reshare
+// session stores the same epoch: a stored epoch of that number, left over by
+// a rollback, is replaced. The previous epochs are kept so the key can be
+// rolled back.
+func (d *sqliteDB) UpdateDKGResultData(hash string, epoch uint32, result *reshare.Result) error {
+	return d.update(func(tx *sql.Tx) error {
+		oldDkg, err := d.loadKey(tx, hash)
+		if err != nil {
+			log.Error("GetDKGResultData", "err", err)
+			return err
+		}
+		if epoch <= oldDkg.Epoch {
+			return staleEpoch(hash, epoch, oldDkg.Epoch)
+		}
+		log.Info("UpdateDKGResultData", "hash", hash, "epoch", epoch)
+
+		encryptedShare, err := sealShare(d.kek, result.Share, oldDkg.PublicKey)
+		if err != nil {
+			log.Error("UpdateDKGResultData", "err", err)
+			return err
+		}
+		err = insertShare(tx, hash, &types.ShareEpoch{
+			Epoch:     epoch,
+			Share:     encryptedShare,
+			BKs:       oldDkg.BKs,
+			CreatedAt: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE keys SET epoch = ? WHERE hash = ?`, epoch, hash)
+		return err
+	})
+}
+
+// GetShareEpochs list the share epochs stored for a key, oldest first
+func (d *sqliteDB) GetShareEpochs(hash string) ([]uint32, error) {
+	rows, err := d.db.Query(`SELECT epoch FROM shares WHERE key_hash = ? ORDER BY epoch`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	epochs := []uint32{}
+	for rows.Next() {
+		var epoch uint32
+		if err := rows.Scan(&epoch); err != nil {
+			return nil, err
+		}
+		epochs = append(epochs, epoch)
+	}
+	return epochs, rows.Err()
+}
+
+// RollbackShareEpoch make a previously stored share epoch the current one
+func (d *sqliteDB) RollbackShareEpoch(hash string, epoch uint32) error {
+	return d.update(func(tx *sql.Tx) error {
+		record, err := d.loadKey(tx, hash)
+		if err != nil {
+			return err
+		}
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM shares WHERE key_hash = ? AND epoch = ?)`, hash, epoch).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return epochNotFound(hash, epoch, record.Epoch)
+		}
+		log.Info("RollbackShareEpoch", "hash", hash, "from", record.Epoch, "to", epoch)
+
+		_, err = tx.Exec(`UPDATE keys SET epoch = ? WHERE hash = ?`, epoch, hash)
+		return err
+	})
+}
+
+// SetKeyState move a key to another lifecycle state. Destroying a key deletes
+// every share epoch; secure_delete makes SQLite overwrite the freed pages, and
+// a checkpoint flushes them out of the write-ahead log.
+func (d *sqliteDB) SetKeyState(hash string, state types.KeyState) error {
+	err := d.update(func(tx *sql.Tx) error {
+		record, err := d.loadKey(tx, hash)
+		if err != nil {
+			return err
+		}
+		if !record.State.CanBecome(state) {
+			return invalidTransition(hash, record.State, state)
+		}
+		log.Info("SetKeyState", "hash", hash, "from", record.State, "to", state)
+
+		if _, err := tx.Exec(`UPDATE keys SET state = ? WHERE hash = ?`, state, hash); err != nil {
+			return err
+		}
+		if state == types.KeyStateDestroyed {
+			_, err = tx.Exec(`DELETE FROM shares WHERE key_hash = ?`, hash)
+		}
+		return err
+	})
+	if err != nil || state != types.KeyStateDestroyed {
+		return err
+	}
+	if _, err := d.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		log.Warn("Cannot checkpoint after destroying a key", "hash", hash, "err", err)
+	}
+	return nil
+}
+
+// ExportKey return a key with every share epoch opened, for an encrypted bundle
+func (d *sqliteDB) ExportKey(hash string) (*types.ExportedKey, error) {
+	record, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`SELECT epoch, share, bks, created_at FROM shares WHERE key_hash = ? ORDER BY epoch`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var epochs []*types.ShareEpoch
+	for rows.Next() {
+		var (
+			epoch types.ShareEpoch
+			bks   string
+		)
+		if err := rows.Scan(&epoch.Epoch, &epoch.Share, &bks, &epoch.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(bks), &epoch.BKs); err != nil {
+			return nil, err
+		}
+		epochs = append(epochs, &epoch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return exportKey(d.kek, hash, record, epochs)
+}
+
+// ImportKey store an exported key with its shares sealed under this node's key.
+// An existing key is never overwritten.
+func (d *sqliteDB) ImportKey(key *types.ExportedKey) error {
+	record, epochs, err := importKey(d.kek, key)
+	if err != nil {
+		return err
+	}
+	log.Info("ImportKey", "hash", key.Hash, "epoch", record.Epoch)
+
+	return d.update(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO keys (hash, public_key, address, pubkey_x, pubkey_y, epoch, created_at, state)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (hash) DO NOTHING`,
+			key.Hash, record.PublicKey, record.Address.Hex(), record.Pubkey.X, record.Pubkey.Y, record.Epoch, record.CreatedAt, record.State)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("%w: %s", ErrRecordExists, key.Hash)
+		}
+		for _, epoch := range epochs {
+			if err := insertShare(tx, key.Hash, epoch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSignerResultData save the signature of the signing session hash
+func (d *sqliteDB) SaveSignerResultData(hash string, result types.RVSignature) error {
+	_, err := d.db.Exec(`INSERT INTO signatures (hash, r, s, message) VALUES (?, ?, ?, ?)
+		ON CONFLICT (hash) DO UPDATE SET r = excluded.r, s = excluded.s, message = excluded.message`,
+		hash, result.R, result.S, result.Hash)
+	return err
+}
+
+// AppendLedgerEntry append a signing session to the ledger. Entries are never
+// overwritten.
+func (d *sqliteDB) AppendLedgerEntry(entry *types.LedgerEntry) error {
+	if err := validateLedgerEntry(entry); err != nil {
+		return err
+	}
+	participants, err := json.Marshal(entry.Participants)
+	if err != nil {
+		return err
+	}
+	res, err := d.db.Exec(`INSERT INTO sessions (id, key_hash, pubkey, digest, r, s, participants, initiator,
+			started_at, finished_at, outcome, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		entry.ID, entry.KeyHash, entry.Pubkey, entry.Digest, entry.R, entry.S, string(participants), entry.Initiator,
+		entry.StartedAt, entry.FinishedAt, entry.Outcome, entry.Error)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: %s", ErrRecordExists, entry.ID)
+	}
+	return nil
+}
+
+// ScanLedger call fn for every ledger entry matching query after its cursor,
+// in start time order
+func (d *sqliteDB) ScanLedger(query types.LedgerQuery, fn func(entry *types.LedgerEntry) error) error {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if query.Cursor != "" {
+		startedAt, id, err := parseLedgerCursor(query.Cursor)
+		if err != nil {
+			return err
+		}
+		where, args = append(where, "(started_at > ? OR (started_at = ? AND id > ?))"), append(args, startedAt, startedAt, id)
+	}
+	if query.KeyHash != "" {
+		where, args = append(where, "key_hash = ?"), append(args, query.KeyHash)
+	}
+	if query.Digest != "" {
+		where, args = append(where, "digest = ?"), append(args, query.Digest)
+	}
+	if query.From != 0 {
+		where, args = append(where, "started_at >= ?"), append(args, query.From)
+	}
+	if query.To != 0 {
+		where, args = append(where, "started_at <= ?"), append(args, query.To)
+	}
+	stmt := `SELECT id, key_hash, pubkey, digest, r, s, participants, initiator, started_at, finished_at, outcome, error
+		FROM sessions`
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := d.db.Query(stmt+" ORDER BY started_at, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry        types.LedgerEntry
+			participants string
+		)
+		err := rows.Scan(&entry.ID, &entry.KeyHash, &entry.Pubkey, &entry.Digest, &entry.R, &entry.S, &participants,
+			&entry.Initiator, &entry.StartedAt, &entry.FinishedAt, &entry.Outcome, &entry.Error)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(participants), &entry.Participants); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			if err == errStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetDKGResultData get dkg result data
+func (d *sqliteDB) GetDKGResultData(hash string) (*types.DKGResult, error) {
+	log.Info("GetDKGResultData", "hash", hash)
+	return d.loadKey(d.db, hash)
+}
+
+// GetSignerResultData get the signature stored for a signing session. Older
+// builds stored signatures under the message hash, the ID of their sessions.
+func (d *sqliteDB) GetSignerResultData(hash string) (*types.RVSignature, error) {
+	result := types.RVSignature{SessionID: hash}
+	err := d.db.QueryRow(`SELECT r, s, message FROM signatures WHERE hash = ?`, hash).Scan(&result.R, &result.S, &result.Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, signatureNotFound(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListKeys list the DKG results held by this node, in hash order
+func (d *sqliteDB) ListKeys(filter types.KeyFilter) (*types.KeyPage, error) {
+	if err := validateKeyFilter(filter); err != nil {
+		return nil, err
+	}
+	limit := pageSize(filter.Limit)
+	where := []string{"hash > ?"}
+	args := []interface{}{filter.Cursor}
+	if filter.Address != "" {
+		where, args = append(where, "address = ?"), append(args, common.HexToAddress(filter.Address).Hex())
+	}
+	if filter.Pubkey != "" {
+		where, args = append(where, "public_key = ?"), append(args, strings.ToLower(strings.TrimPrefix(filter.Pubkey, "0x")))
+	}
+	if filter.State != "" {
+		where, args = append(where, "state = ?"), append(args, filter.State)
+	}
+	if filter.CreatedAfter != 0 {
+		where, args = append(where, "created_at >= ?"), append(args, filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != 0 {
+		where, args = append(where, "created_at <= ?"), append(args, filter.CreatedBefore)
+	}
+	rows, err := d.db.Query(`SELECT hash, public_key, address, created_at, state FROM keys WHERE `+
+		strings.Join(where, " AND ")+` ORDER BY hash LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &types.KeyPage{Keys: []types.KeySummary{}}
+	for rows.Next() {
+		if len(page.Keys) == limit {
+			page.NextCursor = page.Keys[limit-1].Hash
+			break
+		}
+		var (
+			summary types.KeySummary
+			address string
+		)
+		if err := rows.Scan(&summary.Hash, &summary.PublicKey, &address, &summary.CreatedAt, &summary.State); err != nil {
+			return nil, err
+		}
+		summary.Address = common.HexToAddress(address)
+		page.Keys = append(page.Keys, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetSchemaVersion get the schema version of the database
+func (d *sqliteDB) GetSchemaVersion() (int, error) {
+	return d.schemaVersion(d.db)
+}
+
+// SetAccessRule creates or replaces the access rule of rule.Subject.
+func (d *sqliteDB) SetAccessRule(rule *types.AccessRule) error {
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now().Unix()
+	roles, err := json.Marshal(rule.Roles)
+	if err != nil {
+		return err
+	}
+	keys, err := json.Marshal(rule.Keys)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO access_rules (subject, roles, keys, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (subject) DO UPDATE SET roles = excluded.roles, keys = excluded.keys, updated_at = excluded.updated_at`,
+		rule.Subject, string(roles), string(keys), rule.UpdatedAt)
+	return err
+}
+
+// GetAccessRule returns the access rule of subject, or ErrNotFound.
+func (d *sqliteDB) GetAccessRule(subject string) (*types.AccessRule, error) {
+	rules, err := d.queryAccessRules(`WHERE subject = ?`, subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, accessRuleNotFound(subject)
+	}
+	return &rules[0], nil
+}
+
+// DeleteAccessRule removes the access rule of subject, or returns ErrNotFound.
+func (d *sqliteDB) DeleteAccessRule(subject string) error {
+	res, err := d.db.Exec(`DELETE FROM access_rules WHERE subject = ?`, subject)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return accessRuleNotFound(subject)
+	}
+	return nil
+}
+
+// ListAccessRules returns every access rule, ordered by subject.
+func (d *sqliteDB) ListAccessRules() ([]types.AccessRule, error) {
+	return d.queryAccessRules(`ORDER BY subject`)
+}
+
+func (d *sqliteDB) queryAccessRules(where string, args ...interface{}) ([]types.AccessRule, error) {
+	rows, err := d.db.Query(`SELECT subject, roles, keys, updated_at FROM access_rules `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []types.AccessRule{}
+	for rows.Next() {
+		var (
+			rule        types.AccessRule
+			roles, keys string
+		)
+		if err := rows.Scan(&rule.Subject, &roles, &keys, &rule.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(roles), &rule.Roles); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(keys), &rule.Keys); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveJob creates or replaces the record of job.ID.
+func (d *sqliteDB) SaveJob(job *types.Job) error {
+	if err := validateJob(job); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`INSERT INTO jobs (id, kind, status, state, key_hash, owner, callback_url, result, error, error_type,
+			fingerprint, created_at, updated_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET kind = excluded.kind, status = excluded.status, state = excluded.state,
+			key_hash = excluded.key_hash, owner = excluded.owner, callback_url = excluded.callback_url,
+			result = excluded.result, error = excluded.error, error_type = excluded.error_type,
+			fingerprint = excluded.fingerprint,
+			created_at = excluded.created_at, updated_at = excluded.updated_at, finished_at = excluded.finished_at`,
+		job.ID, job.Kind, job.Status, job.State, job.KeyHash, job.Owner, job.CallbackURL, string(job.Result), job.Error, job.ErrorType,
+		job.Fingerprint, job.CreatedAt, job.UpdatedAt, job.FinishedAt)
+	return err
+}
+
+// GetJob returns the job record of id, or ErrNotFound.
+func (d *sqliteDB) GetJob(id string) (*types.Job, error) {
+	jobs, err := d.queryJobs(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, jobNotFound(id)
+	}
+	return &jobs[0], nil
+}
+
+// ListJobs returns the jobs in status, or every job when status is empty,
+// oldest first.
+func (d *sqliteDB) ListJobs(status types.JobStatus) ([]types.Job, error) {
+	if status == "" {
+		return d.queryJobs(`ORDER BY created_at, id`)
+	}
+	return d.queryJobs(`WHERE status = ? ORDER BY created_at, id`, status)
+}
+
+func (d *sqliteDB) queryJobs(where string, args ...interface{}) ([]types.Job, error) {
+	rows, err := d.db.Query(`SELECT id, kind, status, state, key_hash, owner, callback_url, result, error, error_type, fingerprint,
+			created_at, updated_at, finished_at
+		FROM jobs `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []types.Job{}
+	for rows.Next() {
+		var (
+			job    types.Job
+			result string
+		)
+		if err := rows.Scan(&job.ID, &job.Kind, &job.Status, &job.State, &job.KeyHash, &job.Owner, &job.CallbackURL, &result, &job.Error, &job.ErrorType,
+			&job.Fingerprint, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt); err != nil {
+			return nil, err
+		}
+		if result != "" {
+			job.Result = json.RawMessage(result)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveWebhookDelivery creates or replaces the webhook delivery delivery.ID.
+func (d *sqliteDB) SaveWebhookDelivery(delivery *types.WebhookDelivery) error {
+	if err := validateWebhookDelivery(delivery); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`INSERT INTO webhook_deliveries (id, job_id, url, client, payload, status, attempts, next_attempt_at,
+			last_error, created_at, updated_at, delivered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET job_id = excluded.job_id, url = excluded.url, client = excluded.client,
+			payload = excluded.payload, status = excluded.status, attempts = excluded.attempts,
+			next_attempt_at = excluded.next_attempt_at, last_error = excluded.last_error,
+			created_at = excluded.created_at, updated_at = excluded.updated_at, delivered_at = excluded.delivered_at`,
+		delivery.ID, delivery.JobID, delivery.URL, delivery.Client, string(delivery.Payload), delivery.Status, delivery.Attempts,
+		delivery.NextAttemptAt, delivery.LastError, delivery.CreatedAt, delivery.UpdatedAt, delivery.DeliveredAt)
+	return err
+}
+
+// GetWebhookDelivery returns the webhook delivery id, or ErrNotFound.
+func (d *sqliteDB) GetWebhookDelivery(id string) (*types.WebhookDelivery, error) {
+	deliveries, err := d.queryWebhookDeliveries(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, deliveryNotFound(id)
+	}
+	return &deliveries[0], nil
+}
+
+// ListWebhookDeliveries returns the webhook deliveries in status, or every
+// delivery when status is empty, oldest first.
+func (d *sqliteDB) ListWebhookDeliveries(status types.WebhookStatus) ([]types.WebhookDelivery, error) {
+	if status == "" {
+		return d.queryWebhookDeliveries(`ORDER BY created_at, id`)
+	}
+	return d.queryWebhookDeliveries(`WHERE status = ? ORDER BY created_at, id`, status)
+}
+
+func (d *sqliteDB) queryWebhookDeliveries(where string, args ...interface{}) ([]types.WebhookDelivery, error) {
+	rows, err := d.db.Query(`SELECT id, job_id, url, client, payload, status, attempts, next_attempt_at,
+			last_error, created_at, updated_at, delivered_at
+		FROM webhook_deliveries `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	for rows.Next() {
+		var (
+			delivery types.WebhookDelivery
+			payload  string
+		)
+		if err := rows.Scan(&delivery.ID, &delivery.JobID, &delivery.URL, &delivery.Client, &payload, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt,
+			&delivery.DeliveredAt); err != nil {
+			return nil, err
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetSignerConfig get cmd config
+func (d *sqliteDB) GetSignerConfig(hash, pubkey string) (*types.SignerConfig, error) {
+	log.Info("GetSignerConfig", "hash", hash, "pubkey", pubkey)
+
+	resultDKG, err := d.GetDKGResultData(hash)
+	if err != nil {
+		return nil, err
+	}
+	return signerConfig(d.kek, hash, pubkey, resultDKG)
+}
+
+// Rewrap re-seals every share with to and records it as the provider of the
+// database, in a single transaction. The store then uses to, and closes its
+// previous provider.
+func (d *sqliteDB) Rewrap(to KeyEncryptionProvider) error {
+	type sealedShare struct {
+		hash, pubkey, share string
+		epoch               uint32
+	}
+	var count int
+	err := d.update(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT s.key_hash, k.public_key, s.epoch, s.share
+			FROM shares s JOIN keys k ON k.hash = s.key_hash`)
+		if err != nil {
+			return err
+		}
+		var shares []sealedShare
+		for rows.Next() {
+			var s sealedShare
+			if err := rows.Scan(&s.hash, &s.pubkey, &s.epoch, &s.share); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			shares = append(shares, s)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, s := range shares {
+			sealed, err := rewrapShare(d.kek, to, s.share, s.pubkey)
+			if err != nil {
+				return fmt.Errorf("rewrap share epoch %d of %s: %w", s.epoch, s.hash, err)
+			}
+			if _, err := tx.Exec(`UPDATE shares SET share = ? WHERE key_hash = ? AND epoch = ?`, sealed, s.hash, s.epoch); err != nil {
+				return err
+			}
+		}
+		count = len(shares)
+		return setSQLiteKEK(tx, to.ID())
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("Rewrapped shares", "from", d.kek.ID(), "to", to.ID(), "shares", count)
+	previous := d.kek
+	d.kek = to
+	return closeKEK(previous)
+}
+
+func (d *sqliteDB) Defer() {
+	if err := closeKEK(d.kek); err != nil {
+		log.Error("error close key encryption provider", "err", err)
+	}
+	if err := d.db.Close(); err != nil {
+		log.Error("error close store", "err", err)
+	} else {
+		log.Info("store closed")
+	}
+}
@@ -12,29 +12,68 @@ import (
 type HandlerData interface {
 	SaveDKGResultData(hash string, result *dkg.Result) error
 	GetSignerConfig(hash, pubkey string) (*types.SignerConfig, error)
-	UpdateDKGResultData(hash string, result *reshare.Result) error
+	UpdateDKGResultData(hash string, epoch uint32, result *reshare.Result) error
+	GetShareEpochs(hash string) ([]uint32, error)
+	RollbackShareEpoch(hash string, epoch uint32) error
+	SetKeyState(hash string, state types.KeyState) error
+	ExportKey(hash string) (*types.ExportedKey, error)
+	ImportKey(key *types.ExportedKey) error
 	SaveSignerResultData(hash string, result types.RVSignature) error
+	AppendLedgerEntry(entry *types.LedgerEntry) error
+	ScanLedger(query types.LedgerQuery, fn func(entry *types.LedgerEntry) error) error
 	GetDKGResultData(hash string) (*types.DKGResult, error)
+	GetSignerResultData(hash string) (*types.RVSignature, error)
+	ListKeys(filter types.KeyFilter) (*types.KeyPage, error)
+	GetSchemaVersion() (int, error)
+	Rewrap(to KeyEncryptionProvider) error
+	SetAccessRule(rule *types.AccessRule) error
+	GetAccessRule(subject string) (*types.AccessRule, error)
+	DeleteAccessRule(subject string) error
+	ListAccessRules() ([]types.AccessRule, error)
+	SaveJob(job *types.Job) error
+	GetJob(id string) (*types.Job, error)
+	ListJobs(status types.JobStatus) ([]types.Job, error)
+	SaveWebhookDelivery(delivery *types.WebhookDelivery) error
+	GetWebhookDelivery(id string) (*types.WebhookDelivery, error)
+	ListWebhookDeliveries(status types.WebhookStatus) ([]types.WebhookDelivery, error)
 	Defer()
 }
 
+// NewStoreHandler opens the store selected by config, with its shares sealed
+// by the key encryption provider of config.KEK. privateKey is the node
+// identity key. The latency of its operations is recorded in the metrics.
 func NewStoreHandler(config types.StoreConfig, privateKey *ecdsa.PrivateKey) (HandlerData, error) {
+	kek, err := NewKeyEncryptionProvider(config.KEK, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Key encryption provider", "kek", kek.ID())
+
+	handler, err := openStore(config, kek)
+	if err != nil {
+		_ = closeKEK(kek)
+		return nil, err
+	}
+	return timedStore{handler}, nil
+}
+
+func openStore(config types.StoreConfig, kek KeyEncryptionProvider) (HandlerData, error) {
 	switch config.Type {
-	case types.StoreTypeMock:
-		log.Info("Store type is mock")
-		return NewMockDB(), nil
 	case types.StoreTypeBadger:
 		if config.Path == "" {
 			return nil, errors.New("badger path is empty")
 		}
-		if privateKey == nil {
-			return nil, errors.New("badger private key is nil")
-		}
 		log.Info("Store type is badger", "path", config.Path)
-		return NewBadgerDB(config.Path, privateKey), nil
+		return NewBadgerDB(config, kek)
+	case types.StoreTypeSQLite:
+		if config.Path == "" {
+			return nil, errors.New("sqlite path is empty")
+		}
+		log.Info("Store type is sqlite", "path", config.Path)
+		return NewSQLiteDB(config.Path, kek)
 	default:
-		log.Info("Store type is mock")
-		return NewMockDB(), nil
+		// memory, and the former "mock" type
+		log.Info("Store type is memory")
+		return NewMemoryDB(kek)
 	}
-
 }
@@ -0,0 +1,750 @@
+// Package storetest is a conformance suite for store.HandlerData backends.
+// Every backend must pass Run so that nodes behave the same whatever store
+// they are configured with.
+package storetest
+
+import (
+	"alice-tss/store"
+	"alice-tss/tsserr"
+	"alice-tss/types"
+	"alice-tss/utils"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/getamis/alice/crypto/birkhoffinterpolation"
+	"github.com/getamis/alice/crypto/ecpointgrouplaw"
+	"github.com/getamis/alice/crypto/tss/dkg"
+	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
+)
+
+// Factory opens an empty store sealing shares with kek. The store is closed
+// with Defer by the suite.
+type Factory func(t *testing.T, kek store.KeyEncryptionProvider) store.HandlerData
+
+// Run runs the conformance suite against the backend built by newHandler,
+// sealing shares with a fresh node key. Each test gets a fresh store.
+func Run(t *testing.T, newHandler Factory) {
+	RunKEK(t, func(t *testing.T) store.KeyEncryptionProvider {
+		nodeKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store.NewNodeKeyProvider(nodeKey)
+	}, newHandler)
+}
+
+// RunKEK runs the conformance suite with the key encryption providers built
+// by newKEK. The store owns the provider and closes it with Defer.
+func RunKEK(t *testing.T, newKEK func(t *testing.T) store.KeyEncryptionProvider, newHandler Factory) {
+	tests := map[string]func(t *testing.T, kek store.KeyEncryptionProvider, handler store.HandlerData){
+		"DKGResult":     testDKGResult,
+		"NotFound":      testNotFound,
+		"Signatures":    testSignatures,
+		"ListKeys":      testListKeys,
+		"ShareEpochs":   testShareEpochs,
+		"Ledger":        testLedger,
+		"SchemaVersion": testSchemaVersion,
+		"ExportImport":  testExportImport,
+		"KeyStates":     testKeyStates,
+		"Rewrap":        testRewrap,
+		"AccessRules":   testAccessRules,
+		"Jobs":          testJobs,
+		"Webhooks":      testWebhookDeliveries,
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			kek := newKEK(t)
+			handler := newHandler(t, kek)
+			defer handler.Defer()
+			test(t, kek, handler)
+		})
+	}
+}
+
+// NewDKGResult returns a DKG result for a fresh random key.
+func NewDKGResult(t *testing.T) *dkg.Result {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, err := ecpointgrouplaw.NewECPoint(utils.GetCurve(), key.X, key.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dkg.Result{
+		PublicKey: pubkey,
+		Share:     new(big.Int).Set(key.D),
+		Bks: map[string]*birkhoffinterpolation.BkParameter{
+			"peer-a": birkhoffinterpolation.NewBkParameter(big.NewInt(1), 0),
+			"peer-b": birkhoffinterpolation.NewBkParameter(big.NewInt(2), 0),
+		},
+	}
+}
+
+func compressedPubkey(result *dkg.Result) string {
+	return hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+}
+
+func testDKGResult(t *testing.T, kek store.KeyEncryptionProvider, handler store.HandlerData) {
+	result := NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := compressedPubkey(result)
+
+	record, err := handler.GetDKGResultData("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.PublicKey != pubkey || record.Epoch != 1 || len(record.BKs) != 2 {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if record.Address != crypto.PubkeyToAddress(*result.PublicKey.ToPubKey()) {
+		t.Fatalf("got address %s", record.Address.Hex())
+	}
+	if !utils.IsSealed(record.Share) || strings.Contains(record.Share, result.Share.Text(16)) {
+		t.Fatal("share is not sealed at rest")
+	}
+	if _, err := kek.Open(record.Share, []byte(pubkey)); err != nil {
+		t.Fatalf("share is not sealed with the store kek: %v", err)
+	}
+
+	cfg, err := handler.GetSignerConfig("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Share != result.Share.String() || cfg.Epoch != 1 || cfg.BKs["peer-b"].X != "2" {
+		t.Fatalf("unexpected signer config %+v", cfg)
+	}
+	other := NewDKGResult(t)
+	if _, err := handler.GetSignerConfig("0x01", compressedPubkey(other)); tsserr.CodeOf(err) != tsserr.PubkeyMismatch {
+		t.Fatalf("got %v for another public key", err)
+	}
+}
+
+func testNotFound(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	if _, err := handler.GetDKGResultData("0xmissing"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.KeyNotFound {
+		t.Fatalf("got %v for a missing key", err)
+	}
+	if _, err := handler.GetSignerResultData("0xmissing"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.SignatureNotFound {
+		t.Fatalf("got %v for a missing signature", err)
+	}
+	if _, err := handler.GetSignerConfig("0xmissing", ""); err == nil {
+		t.Fatal("signer config of a missing key must fail")
+	}
+	if err := handler.RollbackShareEpoch("0xmissing", 1); err == nil {
+		t.Fatal("rollback of a missing key must fail")
+	}
+	page, err := handler.ListKeys(types.KeyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Keys) != 0 || page.NextCursor != "" {
+		t.Fatalf("unexpected page %+v in an empty store", page)
+	}
+}
+
+func testSignatures(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	signature := types.RVSignature{R: "01", S: "02", Hash: "0xmessage", SessionID: "0xsession"}
+	if err := handler.SaveSignerResultData("0xsession", signature); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.GetSignerResultData("0xmessage"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.SignatureNotFound {
+		t.Fatalf("a signature is stored under its message: %v", err)
+	}
+	got, err := handler.GetSignerResultData("0xsession")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != signature {
+		t.Fatalf("got signature %+v, want %+v", got, signature)
+	}
+	if _, err := handler.GetDKGResultData("0xsession"); err == nil {
+		t.Fatal("a signature must not be readable as a DKG result")
+	}
+}
+
+func testListKeys(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	results := map[string]*dkg.Result{"0x01": NewDKGResult(t), "0x02": NewDKGResult(t), "0x03": NewDKGResult(t)}
+	for hash, result := range results {
+		if err := handler.SaveDKGResultData(hash, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := handler.ListKeys(types.KeyFilter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Keys) != 2 || first.Keys[0].Hash != "0x01" || first.NextCursor != "0x02" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	second, err := handler.ListKeys(types.KeyFilter{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Keys) != 1 || second.Keys[0].Hash != "0x03" || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+
+	address := crypto.PubkeyToAddress(*results["0x02"].PublicKey.ToPubKey())
+	byAddress, err := handler.ListKeys(types.KeyFilter{Address: address.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byAddress.Keys) != 1 || byAddress.Keys[0].Hash != "0x02" {
+		t.Fatalf("unexpected address filter result %+v", byAddress)
+	}
+	for _, invalid := range []string{"not-an-address", "0x02", address.Hex() + "00"} {
+		if _, err := handler.ListKeys(types.KeyFilter{Address: invalid}); tsserr.CodeOf(err) != tsserr.InvalidInput {
+			t.Fatalf("got %v for the address filter %q", err, invalid)
+		}
+	}
+	byPubkey, err := handler.ListKeys(types.KeyFilter{Pubkey: byAddress.Keys[0].PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byPubkey.Keys) != 1 || byPubkey.Keys[0].Address != address {
+		t.Fatalf("unexpected pubkey filter result %+v", byPubkey)
+	}
+	future, err := handler.ListKeys(types.KeyFilter{CreatedAfter: byPubkey.Keys[0].CreatedAt + 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(future.Keys) != 0 {
+		t.Fatalf("unexpected creation time filter result %+v", future)
+	}
+}
+
+func testShareEpochs(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	result := NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := compressedPubkey(result)
+
+	reshared := big.NewInt(42)
+	if err := handler.UpdateDKGResultData("0x01", 2, &reshare.Result{Share: reshared}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := handler.GetSignerConfig("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Epoch != 2 || cfg.Share != reshared.String() {
+		t.Fatalf("got share %s at epoch %d after reshare", cfg.Share, cfg.Epoch)
+	}
+
+	if err := handler.RollbackShareEpoch("0x01", 1); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = handler.GetSignerConfig("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Epoch != 1 || cfg.Share != result.Share.String() {
+		t.Fatalf("got share %s at epoch %d after rollback", cfg.Share, cfg.Epoch)
+	}
+	if err := handler.RollbackShareEpoch("0x01", 7); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.EpochMismatch {
+		t.Fatalf("got %v for a rollback to an unknown epoch", err)
+	}
+
+	if err := handler.UpdateDKGResultData("0x01", 1, &reshare.Result{Share: big.NewInt(43)}); tsserr.CodeOf(err) != tsserr.EpochMismatch {
+		t.Fatalf("got %v storing the current epoch again", err)
+	}
+
+	// 0x01 and 0x02 stand for two holders of a key: the first committed
+	// epoch 2 and rolled it back, the second never committed it. The next
+	// reshare must leave them on the same epoch.
+	if err := handler.SaveDKGResultData("0x02", result); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{"0x01", "0x02"} {
+		if err := handler.UpdateDKGResultData(hash, 2, &reshare.Result{Share: big.NewInt(43)}); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := handler.GetSignerConfig(hash, pubkey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Epoch != 2 || cfg.Share != "43" {
+			t.Fatalf("%s: got share %s at epoch %d after resharing again", hash, cfg.Share, cfg.Epoch)
+		}
+		epochs, err := handler.GetShareEpochs(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(epochs) != 2 || epochs[0] != 1 || epochs[1] != 2 {
+			t.Fatalf("%s: got epochs %v, want [1 2]", hash, epochs)
+		}
+	}
+	for _, epoch := range []uint32{1, 2} {
+		if err := handler.RollbackShareEpoch("0x01", epoch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfg, err := handler.GetSignerConfig("0x01", pubkey); err != nil || cfg.Share != "43" {
+		t.Fatalf("epoch 2 still holds the share it was rolled back from: %+v, %v", cfg, err)
+	}
+}
+
+func testLedger(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	entries := []types.LedgerEntry{
+		{ID: "a", KeyHash: "0x01", Digest: "0xd1", StartedAt: 100, Outcome: types.LedgerOutcomeSigned},
+		{ID: "b", KeyHash: "0x01", Digest: "0xd1", StartedAt: 200, Outcome: types.LedgerOutcomeSigned},
+		{ID: "c", KeyHash: "0x02", Digest: "0xd2", StartedAt: 300, Outcome: types.LedgerOutcomeFailed},
+	}
+	for i := range entries {
+		if err := handler.AppendLedgerEntry(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := handler.AppendLedgerEntry(&entries[0]); !errors.Is(err, store.ErrRecordExists) {
+		t.Fatalf("got %v when appending an entry twice", err)
+	}
+
+	queries := map[string]struct {
+		query types.LedgerQuery
+		want  []string
+	}{
+		"all":    {types.LedgerQuery{}, []string{"a", "b", "c"}},
+		"key":    {types.LedgerQuery{KeyHash: "0x01"}, []string{"a", "b"}},
+		"digest": {types.LedgerQuery{Digest: "0xd2"}, []string{"c"}},
+		"time":   {types.LedgerQuery{From: 150, To: 300}, []string{"b", "c"}},
+		"mixed":  {types.LedgerQuery{KeyHash: "0x01", To: 150}, []string{"a"}},
+		"limit":  {types.LedgerQuery{Limit: 1}, []string{"a"}},
+	}
+	for name, c := range queries {
+		got, err := store.QueryLedger(handler, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, entry := range got.Entries {
+			ids = append(ids, entry.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: got %v, want %v", name, ids, c.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := store.ExportLedger(context.Background(), handler, types.LedgerQuery{KeyHash: "0x01"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("exported %d lines, want 2", len(lines))
+	}
+	var exported types.LedgerEntry
+	if err := json.Unmarshal([]byte(lines[1]), &exported); err != nil || exported.ID != "b" {
+		t.Fatalf("unexpected export line %q", lines[1])
+	}
+
+	// Pages follow each other through the cursor, on the full scan and on the
+	// key index, including between entries started in the same second.
+	if err := handler.AppendLedgerEntry(&types.LedgerEntry{ID: "d", KeyHash: "0x01", Digest: "0xd3", StartedAt: 200}); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]struct {
+		query types.LedgerQuery
+		want  []string
+	}{
+		"all": {types.LedgerQuery{Limit: 1}, []string{"a", "b", "d", "c"}},
+		"key": {types.LedgerQuery{KeyHash: "0x01", Limit: 2}, []string{"a", "b", "d"}},
+	} {
+		var ids []string
+		for pages := 0; ; pages++ {
+			if pages > len(c.want) {
+				t.Fatalf("%s: the cursor does not advance", name)
+			}
+			page, err := store.QueryLedger(handler, c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range page.Entries {
+				ids = append(ids, entry.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			c.query.Cursor = page.NextCursor
+		}
+		if strings.Join(ids, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: paged %v, want %v", name, ids, c.want)
+		}
+	}
+	if _, err := store.QueryLedger(handler, types.LedgerQuery{Cursor: "b"}); tsserr.CodeOf(err) != tsserr.InvalidInput {
+		t.Fatalf("got %v for an invalid ledger cursor", err)
+	}
+}
+
+func testSchemaVersion(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	if version, err := handler.GetSchemaVersion(); err != nil || version != store.SchemaVersion {
+		t.Fatalf("got schema version %d (%v), want %d", version, err, store.SchemaVersion)
+	}
+}
+
+func testExportImport(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	result := NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.UpdateDKGResultData("0x01", 2, &reshare.Result{Share: big.NewInt(42)}); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := handler.ExportKey("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Epoch != 2 || len(exported.Shares) != 2 || exported.Shares[0].Share != result.Share.String() {
+		t.Fatalf("unexpected export %+v", exported)
+	}
+
+	if err := handler.ImportKey(exported); !errors.Is(err, store.ErrRecordExists) {
+		t.Fatalf("got %v when importing over an existing key", err)
+	}
+	exported.Hash = "0x02"
+	if err := handler.ImportKey(exported); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := compressedPubkey(result)
+	cfg, err := handler.GetSignerConfig("0x02", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Epoch != 2 || cfg.Share != "42" {
+		t.Fatalf("got share %s at epoch %d after import", cfg.Share, cfg.Epoch)
+	}
+	if err := handler.RollbackShareEpoch("0x02", 1); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = handler.GetSignerConfig("0x02", pubkey); err != nil || cfg.Share != result.Share.String() {
+		t.Fatalf("got share %v (%v) after rolling back an imported key", cfg, err)
+	}
+
+	exported.Hash = "0x03"
+	exported.PublicKey = compressedPubkey(NewDKGResult(t))
+	if err := handler.ImportKey(exported); err == nil {
+		t.Fatal("a key whose pubkey does not match must not be imported")
+	}
+}
+
+func testKeyStates(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	result := NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.UpdateDKGResultData("0x01", 2, &reshare.Result{Share: big.NewInt(42)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SaveDKGResultData("0x02", NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := compressedPubkey(result)
+
+	cfg, err := handler.GetSignerConfig("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.State != types.KeyStateActive {
+		t.Fatalf("got state %q for a new key", cfg.State)
+	}
+	for _, state := range []types.KeyState{types.KeyStateFrozen, types.KeyStateActive, types.KeyStateRetired} {
+		if err := handler.SetKeyState("0x01", state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := handler.SetKeyState("0x01", types.KeyStateActive); tsserr.CodeOf(err) != tsserr.InvalidKeyState {
+		t.Fatalf("got %v for a retired key becoming active again", err)
+	}
+	if err := handler.SetKeyState("0x01", "lost"); err == nil {
+		t.Fatal("an unknown state must be refused")
+	}
+	if cfg, err = handler.GetSignerConfig("0x01", pubkey); err != nil || cfg.State != types.KeyStateRetired {
+		t.Fatalf("got %v (%v) for a retired key", cfg, err)
+	}
+
+	retired, err := handler.ListKeys(types.KeyFilter{State: types.KeyStateRetired})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired.Keys) != 1 || retired.Keys[0].Hash != "0x01" || retired.Keys[0].State != types.KeyStateRetired {
+		t.Fatalf("unexpected state filter result %+v", retired)
+	}
+
+	if err := handler.SetKeyState("0x01", types.KeyStateDestroyed); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SetKeyState("0x01", types.KeyStateDestroyed); err != nil {
+		t.Fatalf("destroying a key again must be allowed: %v", err)
+	}
+	if _, err := handler.GetSignerConfig("0x01", pubkey); !errors.Is(err, store.ErrKeyDestroyed) || tsserr.CodeOf(err) != tsserr.InvalidKeyState {
+		t.Fatalf("got %v for the signer config of a destroyed key", err)
+	}
+	record, err := handler.GetDKGResultData("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.State != types.KeyStateDestroyed || record.Share != "" {
+		t.Fatalf("destroyed key still holds %+v", record)
+	}
+	if epochs, err := handler.GetShareEpochs("0x01"); err != nil || len(epochs) != 0 {
+		t.Fatalf("destroyed key still holds share epochs %v (%v)", epochs, err)
+	}
+	if err := handler.RollbackShareEpoch("0x01", 1); err == nil {
+		t.Fatal("a destroyed key must not be rolled back")
+	}
+}
+
+func testRewrap(t *testing.T, kek store.KeyEncryptionProvider, handler store.HandlerData) {
+	result := NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.UpdateDKGResultData("0x01", 2, &reshare.Result{Share: big.NewInt(42)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SaveDKGResultData("0x02", NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := compressedPubkey(result)
+
+	keyFile := filepath.Join(t.TempDir(), "kek")
+	if err := store.GenerateKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	to, err := store.NewKeyFileProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.Rewrap(to); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := handler.GetDKGResultData("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.Open(record.Share, []byte(pubkey)); err != nil {
+		t.Fatalf("share is not sealed with the new kek: %v", err)
+	}
+	if _, err := kek.Open(record.Share, []byte(pubkey)); err == nil {
+		t.Fatal("share is still sealed with the old kek")
+	}
+	cfg, err := handler.GetSignerConfig("0x01", pubkey)
+	if err != nil || cfg.Share != "42" {
+		t.Fatalf("got signer config %+v, %v", cfg, err)
+	}
+
+	// older epochs are rewrapped too
+	if err := handler.RollbackShareEpoch("0x01", 1); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = handler.GetSignerConfig("0x01", pubkey)
+	if err != nil || cfg.Share != result.Share.String() {
+		t.Fatalf("got signer config %+v, %v after rollback", cfg, err)
+	}
+	if err := handler.SaveDKGResultData("0x03", NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+	record, err = handler.GetDKGResultData("0x03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.Open(record.Share, []byte(record.PublicKey)); err != nil {
+		t.Fatalf("new shares are not sealed with the new kek: %v", err)
+	}
+}
+
+func testAccessRules(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	if _, err := handler.GetAccessRule("service-a"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.AccessRuleNotFound {
+		t.Fatalf("got %v for a missing access rule", err)
+	}
+	invalid := []*types.AccessRule{
+		{Roles: []types.Role{types.RoleSigner}},
+		{Subject: "service-a"},
+		{Subject: "service-a", Roles: []types.Role{"root"}},
+		{Subject: "service-a", Roles: []types.Role{types.RoleSigner}, Keys: []string{""}},
+	}
+	for _, rule := range invalid {
+		if err := handler.SetAccessRule(rule); err == nil {
+			t.Fatalf("invalid access rule %+v was stored", rule)
+		}
+	}
+
+	rule := &types.AccessRule{Subject: "service-b", Roles: []types.Role{types.RoleSigner}, Keys: []string{"0x01"}}
+	if err := handler.SetAccessRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SetAccessRule(&types.AccessRule{Subject: "service-a", Roles: []types.Role{types.RoleReader}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := handler.GetAccessRule("service-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UpdatedAt == 0 || !got.Allows(types.PermissionSign, "0x01") || got.Allows(types.PermissionSign, "0x02") {
+		t.Fatalf("unexpected access rule %+v", got)
+	}
+
+	rule.Keys = []string{types.AllKeys}
+	if err := handler.SetAccessRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := handler.ListAccessRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Subject != "service-a" || rules[0].Keys == nil || !rules[1].CanUse("0x02") {
+		t.Fatalf("unexpected access rules %+v", rules)
+	}
+
+	if err := handler.DeleteAccessRule("service-b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.DeleteAccessRule("service-b"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.AccessRuleNotFound {
+		t.Fatalf("got %v when deleting a missing access rule", err)
+	}
+	if rules, err = handler.ListAccessRules(); err != nil || len(rules) != 1 {
+		t.Fatalf("got %+v, %v after delete", rules, err)
+	}
+}
+
+func testJobs(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	if _, err := handler.GetJob("job-1"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.JobNotFound {
+		t.Fatalf("got %v for a missing job", err)
+	}
+	if err := handler.SaveJob(&types.Job{ID: "job-0", Status: types.JobStatusPending}); err == nil {
+		t.Fatal("job without a kind was stored")
+	}
+
+	jobs := []*types.Job{
+		{ID: "job-2", Kind: types.JobKindSign, Status: types.JobStatusRunning, KeyHash: "0x01", CreatedAt: 20},
+		{ID: "job-1", Kind: types.JobKindDKG, Status: types.JobStatusPending, KeyHash: "0x02", Owner: "service-a", CallbackURL: "https://hooks.example/tss", CreatedAt: 10},
+		{ID: "job-3", Kind: types.JobKindReshare, Status: types.JobStatusRunning, KeyHash: "0x01", CreatedAt: 20},
+	}
+	for _, job := range jobs {
+		if err := handler.SaveJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := *jobs[0]
+	done.Status = types.JobStatusDone
+	done.State = "Done"
+	done.Result = json.RawMessage(`{"r":"01","s":"02"}`)
+	done.FinishedAt = 30
+	if err := handler.SaveJob(&done); err != nil {
+		t.Fatal(err)
+	}
+	got, err := handler.GetJob("job-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != types.JobStatusDone || got.State != "Done" || string(got.Result) != string(done.Result) || got.FinishedAt != 30 {
+		t.Fatalf("unexpected job %+v", got)
+	}
+	if got, err = handler.GetJob("job-1"); err != nil || got.Owner != "service-a" || got.CallbackURL != "https://hooks.example/tss" || got.Result != nil {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	all, err := handler.ListJobs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].ID != "job-1" || all[1].ID != "job-2" || all[2].ID != "job-3" {
+		t.Fatalf("unexpected jobs %+v", all)
+	}
+	running, err := handler.ListJobs(types.JobStatusRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 1 || running[0].ID != "job-3" {
+		t.Fatalf("unexpected running jobs %+v", running)
+	}
+
+	failed := *jobs[2]
+	failed.Status = types.JobStatusFailed
+	failed.Error = "key 0x01 not found"
+	failed.ErrorType = string(tsserr.KeyNotFound)
+	failed.Fingerprint = "0xfingerprint"
+	if err := handler.SaveJob(&failed); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = handler.GetJob("job-3"); err != nil || got.Error != failed.Error || got.ErrorType != failed.ErrorType ||
+		got.Fingerprint != failed.Fingerprint {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, _ store.KeyEncryptionProvider, handler store.HandlerData) {
+	if _, err := handler.GetWebhookDelivery("hook-1"); !errors.Is(err, store.ErrNotFound) || tsserr.CodeOf(err) != tsserr.DeliveryNotFound {
+		t.Fatalf("got %v for a missing delivery", err)
+	}
+	if err := handler.SaveWebhookDelivery(&types.WebhookDelivery{ID: "hook-0", Status: types.WebhookStatusPending}); err == nil {
+		t.Fatal("delivery without a URL was stored")
+	}
+
+	deliveries := []*types.WebhookDelivery{
+		{ID: "hook-2", JobID: "job-1", URL: "https://a.example/hook", Payload: json.RawMessage(`{"event":"job.done"}`), Status: types.WebhookStatusPending, NextAttemptAt: 20, CreatedAt: 20},
+		{ID: "hook-1", JobID: "job-1", URL: "https://b.example/hook", Client: "service-a", Payload: json.RawMessage(`{"event":"job.done"}`), Status: types.WebhookStatusPending, NextAttemptAt: 10, CreatedAt: 10},
+		{ID: "hook-3", JobID: "job-2", URL: "https://a.example/hook", Payload: json.RawMessage(`{"event":"job.failed"}`), Status: types.WebhookStatusPending, NextAttemptAt: 20, CreatedAt: 20},
+	}
+	for _, delivery := range deliveries {
+		if err := handler.SaveWebhookDelivery(delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	retried := *deliveries[0]
+	retried.Attempts = 1
+	retried.LastError = "webhook answered 503 Service Unavailable"
+	retried.NextAttemptAt = 25
+	if err := handler.SaveWebhookDelivery(&retried); err != nil {
+		t.Fatal(err)
+	}
+	delivered := *deliveries[2]
+	delivered.Status = types.WebhookStatusDelivered
+	delivered.Attempts = 1
+	delivered.DeliveredAt = 21
+	if err := handler.SaveWebhookDelivery(&delivered); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := handler.GetWebhookDelivery("hook-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Attempts != 1 || got.LastError != retried.LastError || got.NextAttemptAt != 25 || string(got.Payload) != string(retried.Payload) {
+		t.Fatalf("unexpected delivery %+v", got)
+	}
+	if got, err = handler.GetWebhookDelivery("hook-1"); err != nil || got.Client != "service-a" || got.URL != "https://b.example/hook" {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	all, err := handler.ListWebhookDeliveries("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].ID != "hook-1" || all[1].ID != "hook-2" || all[2].ID != "hook-3" {
+		t.Fatalf("unexpected deliveries %+v", all)
+	}
+	pending, err := handler.ListWebhookDeliveries(types.WebhookStatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "hook-1" || pending[1].ID != "hook-2" {
+		t.Fatalf("unexpected pending deliveries %+v", pending)
+	}
+}
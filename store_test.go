@@ -0,0 +1,433 @@
+package main_test
+
+import (
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/types"
+	"alice-tss/utils"
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/getamis/alice/crypto/tss/ecdsa/gg18/reshare"
+)
+
+func TestBadgerConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, kek store.KeyEncryptionProvider) store.HandlerData {
+		handler, err := store.NewBadgerDB(types.StoreConfig{Path: t.TempDir()}, kek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, kek store.KeyEncryptionProvider) store.HandlerData {
+		handler, err := store.NewSQLiteDB(filepath.Join(t.TempDir(), "node.db"), kek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T, kek store.KeyEncryptionProvider) store.HandlerData {
+		handler, err := store.NewMemoryDB(kek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	})
+}
+
+func TestBadgerMigratesLegacyLayout(t *testing.T) {
+	dir := t.TempDir()
+	nodeKey, _ := crypto.GenerateKey()
+	tssKey, _ := crypto.GenerateKey()
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(&tssKey.PublicKey))
+	share := big.NewInt(123456789)
+
+	legacyShare, err := utils.Encrypt(common.Bytes2Hex(share.Bytes()), crypto.FromECDSA(nodeKey), pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsm := store.NewBadgerFSM(db)
+	err = fsm.Set("0xlegacy", &types.DKGResult{
+		Share:     legacyShare,
+		PublicKey: pubkey,
+		Pubkey: types.Pubkey{
+			X: hex.EncodeToString(tssKey.X.Bytes()),
+			Y: hex.EncodeToString(tssKey.Y.Bytes()),
+		},
+		BKs: map[string]types.BK{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.Set("0xmessage", types.RVSignature{R: "01", S: "02", Hash: "0xmessage"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	handler, err := store.NewBadgerDB(types.StoreConfig{Path: dir}, store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Defer()
+
+	record, err := handler.GetDKGResultData("0xlegacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.IsSealed(record.Share) {
+		t.Fatal("legacy share was not re-encrypted")
+	}
+	cfg, err := handler.GetSignerConfig("0xlegacy", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Share != share.String() || cfg.Epoch != 1 || cfg.State != types.KeyStateActive {
+		t.Fatalf("got share %s at epoch %d in state %q, want %s at epoch 1, active", cfg.Share, cfg.Epoch, cfg.State, share)
+	}
+
+	signature, err := handler.GetSignerResultData("0xmessage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.R != "01" || signature.S != "02" || signature.SessionID != "0xmessage" {
+		t.Fatalf("unexpected signature %+v", signature)
+	}
+	if _, err := handler.GetSignerResultData("0xlegacy"); err == nil {
+		t.Fatal("a DKG result must not be readable as a signature")
+	}
+	if version, err := handler.GetSchemaVersion(); err != nil || version != store.SchemaVersion {
+		t.Fatalf("got schema version %d (%v), want %d", version, err, store.SchemaVersion)
+	}
+}
+
+// TestBadgerDestroy reads the files of a badger store after a key was
+// destroyed: the share is never on disk in the clear, but badger keeps the
+// sealed share in the value log it still writes to, as the docs of the
+// destroyed state say.
+func TestBadgerDestroy(t *testing.T) {
+	dir := t.TempDir()
+	nodeKey, _ := crypto.GenerateKey()
+	handler, err := store.NewBadgerDB(types.StoreConfig{Path: dir}, store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := storetest.NewDKGResult(t)
+	if err := handler.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	record, err := handler.GetDKGResultData("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []types.KeyState{types.KeyStateRetired, types.KeyStateDestroyed} {
+		if err := handler.SetKeyState("0x01", state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if epochs, err := handler.GetShareEpochs("0x01"); err != nil || len(epochs) != 0 {
+		t.Fatalf("got share epochs %v, %v after destroying the key", epochs, err)
+	}
+	handler.Defer()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedIn := []string{}
+	for _, file := range files {
+		raw, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, []byte(result.Share.String())) || bytes.Contains(raw, result.Share.Bytes()) {
+			t.Fatalf("%s holds the share in the clear", file.Name())
+		}
+		if bytes.Contains(raw, []byte(record.Share)) {
+			sealedIn = append(sealedIn, file.Name())
+		}
+	}
+	if len(sealedIn) == 0 {
+		t.Fatal("the sealed share left the value log: destroy on badger may now be documented as a secure erase")
+	}
+	for _, name := range sealedIn {
+		if filepath.Ext(name) != ".vlog" {
+			t.Fatalf("the sealed share is still in %s", name)
+		}
+	}
+}
+
+func TestSQLiteTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.db")
+	nodeKey, _ := crypto.GenerateKey()
+	handler, err := store.NewSQLiteDB(path, store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SaveDKGResultData("0x01", storetest.NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.UpdateDKGResultData("0x01", 2, &reshare.Result{Share: big.NewInt(42)}); err != nil {
+		t.Fatal(err)
+	}
+	handler.Defer()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var epoch, shares int
+	if err := db.QueryRow(`SELECT epoch FROM keys WHERE hash = '0x01'`).Scan(&epoch); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM shares WHERE key_hash = '0x01'`).Scan(&shares); err != nil {
+		t.Fatal(err)
+	}
+	if epoch != 2 || shares != 2 {
+		t.Fatalf("got epoch %d with %d shares, want epoch 2 with 2 shares", epoch, shares)
+	}
+}
+
+func TestKeyBundle(t *testing.T) {
+	sourceKey, _ := crypto.GenerateKey()
+	source, err := store.NewBadgerDB(types.StoreConfig{Path: t.TempDir()}, store.NewNodeKeyProvider(sourceKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Defer()
+	result := storetest.NewDKGResult(t)
+	if err := source.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SaveDKGResultData("0x02", storetest.NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle bytes.Buffer
+	if n, err := store.ExportBundle(source, "correct horse", &bundle); err != nil || n != 2 {
+		t.Fatalf("exported %d keys: %v", n, err)
+	}
+	if bytes.Contains(bundle.Bytes(), []byte(result.Share.String())) {
+		t.Fatal("bundle leaks a share")
+	}
+
+	destinationKey, _ := crypto.GenerateKey()
+	destination, err := store.NewSQLiteDB(filepath.Join(t.TempDir(), "node.db"), store.NewNodeKeyProvider(destinationKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Defer()
+	if _, err := store.ImportBundle(destination, "wrong", bytes.NewReader(bundle.Bytes())); !errors.Is(err, store.ErrBundlePassword) {
+		t.Fatalf("got %v for a wrong password", err)
+	}
+	for _, params := range []map[string]int{
+		{"n": 1<<15 + 1}, {"n": 1 << 21}, {"n": 0}, {"r": 1 << 30}, {"r": 0}, {"p": 1 << 20}, {"n": 1 << 20, "r": 32},
+	} {
+		var tampered map[string]interface{}
+		if err := json.Unmarshal(bundle.Bytes(), &tampered); err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range params {
+			tampered[name] = value
+		}
+		raw, _ := json.Marshal(tampered)
+		if _, err := store.ImportBundle(destination, "correct horse", bytes.NewReader(raw)); !errors.Is(err, store.ErrUnsupportedBundle) {
+			t.Fatalf("got %v for scrypt parameters %v", err, params)
+		}
+	}
+	if n, err := store.ImportBundle(destination, "correct horse", bytes.NewReader(bundle.Bytes())); err != nil || n != 2 {
+		t.Fatalf("imported %d keys: %v", n, err)
+	}
+
+	record, err := destination.GetDKGResultData("0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.Open(record.Share, crypto.FromECDSA(destinationKey), []byte(record.PublicKey)); err != nil {
+		t.Fatalf("imported share is not sealed under the destination key: %v", err)
+	}
+	cfg, err := destination.GetSignerConfig("0x01", record.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Share != result.Share.String() {
+		t.Fatal("imported share does not match the exported one")
+	}
+	if _, err := store.ImportBundle(destination, "correct horse", bytes.NewReader(bundle.Bytes())); !errors.Is(err, store.ErrRecordExists) {
+		t.Fatalf("got %v when importing a bundle twice", err)
+	}
+}
+
+func TestBadgerBackupRestore(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	source, err := store.NewBadgerDB(types.StoreConfig{Path: t.TempDir(), GCInterval: time.Millisecond}, store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Defer()
+	result := storetest.NewDKGResult(t)
+	if err := source.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := store.Backup(source, &backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SaveDKGResultData("0x02", storetest.NewDKGResult(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RestoreBadger(t.TempDir(), bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "restored")
+	if err := store.RestoreBadger(dir, bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RestoreBadger(dir, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Fatal("restoring over an existing database must fail")
+	}
+
+	restored, err := store.NewBadgerDB(types.StoreConfig{Path: dir, GCInterval: -1}, store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Defer()
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+	cfg, err := restored.GetSignerConfig("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Share != result.Share.String() {
+		t.Fatal("restored share does not match")
+	}
+	if _, err := restored.GetDKGResultData("0x02"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v for a key written after the backup", err)
+	}
+
+	memory, _ := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err := store.Backup(memory, &backup); !errors.Is(err, store.ErrBackupUnsupported) {
+		t.Fatalf("got %v when backing up the memory store", err)
+	}
+}
+
+func TestStoreKEK(t *testing.T) {
+	for _, storeType := range []types.StoreType{types.StoreTypeBadger, types.StoreTypeSQLite} {
+		t.Run(string(storeType), func(t *testing.T) {
+			nodeKey, _ := crypto.GenerateKey()
+			keyFile := filepath.Join(t.TempDir(), "kek")
+			if err := store.GenerateKeyFile(keyFile); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.GenerateKeyFile(keyFile); err == nil {
+				t.Fatal("an existing key file must not be overwritten")
+			}
+			nodeConfig := types.StoreConfig{Type: storeType, Path: filepath.Join(t.TempDir(), "node"), GCInterval: -1}
+			fileConfig := nodeConfig
+			fileConfig.KEK = types.KEKConfig{Type: types.KEKTypeKeyFile, Path: keyFile}
+
+			handler, err := store.NewStoreHandler(nodeConfig, nodeKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := storetest.NewDKGResult(t)
+			if err := handler.SaveDKGResultData("0x01", result); err != nil {
+				t.Fatal(err)
+			}
+			handler.Defer()
+
+			if _, err := store.NewStoreHandler(fileConfig, nodeKey); !errors.Is(err, store.ErrKEKMismatch) {
+				t.Fatalf("got %v when opening with another kek", err)
+			}
+			handler, err = store.NewStoreHandler(nodeConfig, nodeKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kek, err := store.NewKeyEncryptionProvider(fileConfig.KEK, nodeKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := handler.Rewrap(kek); err != nil {
+				t.Fatal(err)
+			}
+			handler.Defer()
+
+			if _, err := store.NewStoreHandler(nodeConfig, nodeKey); !errors.Is(err, store.ErrKEKMismatch) {
+				t.Fatalf("got %v when opening with the node key after rewrap", err)
+			}
+			handler, err = store.NewStoreHandler(fileConfig, nodeKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer handler.Defer()
+			pubkey := hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+			cfg, err := handler.GetSignerConfig("0x01", pubkey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Share != result.Share.String() {
+				t.Fatal("rewrapped share does not match")
+			}
+		})
+	}
+}
+
+// TestPKCS11KEK runs against a PKCS#11 token holding an AES key, such as a
+// SoftHSM token set up with:
+//
+//	softhsm2-util --init-token --free --label alice-tss --pin 1234 --so-pin 1234
+//	pkcs11-tool --module $LIB --token-label alice-tss --login --pin 1234 \
+//		--keygen --key-type AES:32 --label kek
+//
+// and ALICE_TSS_PKCS11_LIBRARY=$LIB ALICE_TSS_PKCS11_PIN=1234.
+func TestPKCS11KEK(t *testing.T) {
+	library := os.Getenv("ALICE_TSS_PKCS11_LIBRARY")
+	if library == "" {
+		t.Skip("ALICE_TSS_PKCS11_LIBRARY is not set")
+	}
+	config := types.KEKConfig{
+		Type: types.KEKTypePKCS11,
+		PKCS11: types.PKCS11Config{
+			Library:    library,
+			TokenLabel: "alice-tss",
+			Pin:        os.Getenv("ALICE_TSS_PKCS11_PIN"),
+			KeyLabel:   "kek",
+		},
+	}
+	newKEK := func(t *testing.T) store.KeyEncryptionProvider {
+		kek, err := store.NewKeyEncryptionProvider(config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kek
+	}
+	storetest.RunKEK(t, newKEK, func(t *testing.T, kek store.KeyEncryptionProvider) store.HandlerData {
+		handler, err := store.NewMemoryDB(kek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	})
+}
@@ -0,0 +1,275 @@
+package main_test
+
+import (
+	"alice-tss/pb"
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/store/storetest"
+	"alice-tss/types"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/protobuf/proto"
+	gorpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+func TestKeyStateEnforcement(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := storetest.NewDKGResult(t)
+	if err := storeDB.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+	caller := &server.TssCaller{StoreDB: storeDB}
+
+	for _, state := range []types.KeyState{types.KeyStateFrozen, types.KeyStateRetired} {
+		if err := storeDB.SetKeyState("0x01", state); err != nil {
+			t.Fatal(err)
+		}
+		_, err := caller.SignMessage(context.Background(), nil, &pb.SignRequest{Hash: "0x01", Pubkey: pubkey, Message: "hello"}, nil)
+		if !errors.Is(err, server.ErrKeyState) {
+			t.Fatalf("%s: got %v when signing", state, err)
+		}
+		err = caller.Reshare(context.Background(), nil, &pb.ReshareRequest{Hash: "0x01", Pubkey: pubkey}, nil)
+		if !errors.Is(err, server.ErrKeyState) {
+			t.Fatalf("%s: got %v when resharing", state, err)
+		}
+	}
+
+	rollback := &pb.RollbackRequest{Hash: "0x01", Pubkey: pubkey, Epoch: 1}
+	if err := caller.PrepareRollback(rollback); !errors.Is(err, server.ErrKeyState) {
+		t.Fatalf("got %v when rolling back a retired key", err)
+	}
+	if err := caller.CommitKeyState(&pb.KeyStateRequest{Hash: "0x01", Pubkey: pubkey, State: "active"}); !errors.Is(err, server.ErrKeyState) {
+		t.Fatalf("got %v when reactivating a retired key", err)
+	}
+	if err := caller.CommitKeyState(&pb.KeyStateRequest{Hash: "0x01", Pubkey: pubkey, State: "destroyed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := caller.SignMessage(context.Background(), nil, &pb.SignRequest{Hash: "0x01", Pubkey: pubkey, Message: "hello"}, nil); !errors.Is(err, store.ErrKeyDestroyed) {
+		t.Fatalf("got %v when signing with a destroyed key", err)
+	}
+}
+
+func TestKeyViewHasNoShare(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := storetest.NewDKGResult(t)
+	if err := storeDB.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	pubkey := hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+	caller := &server.TssCaller{StoreDB: storeDB}
+
+	view, err := caller.GetKeyView("0x01", pubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "share") || strings.Contains(string(data), result.Share.String()) {
+		t.Fatalf("key view %s exposes share material", data)
+	}
+	if view.Hash != "0x01" || view.PublicKey != pubkey || view.State != types.KeyStateActive {
+		t.Fatalf("unexpected key view %+v", view)
+	}
+	other := hex.EncodeToString(crypto.CompressPubkey(storetest.NewDKGResult(t).PublicKey.ToPubKey()))
+	if _, err := caller.GetKeyView("0x01", other); err == nil {
+		t.Fatal("key view must not be returned for another public key")
+	}
+}
+
+// TestPeerCallsNeedHolders checks that only the holders of a key may roll it
+// back or change its state through the peer service of a node, and that
+// signings must name their session.
+func TestPeerCallsNeedHolders(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	node, nodeID, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+	if err := gorpc.NewServer(node, peer.ProtocolId).Register(&server.TssPeerService{
+		TssCaller: &server.TssCaller{StoreDB: storeDB},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	callerKey, _ := crypto.GenerateKey()
+	caller, callerID, err := peer.MakeBasicHost(0, callerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer caller.Close()
+
+	// The caller holds 0x02 and 0x03, not 0x01.
+	pubkeys := map[string]string{}
+	for _, hash := range []string{"0x01", "0x02", "0x03"} {
+		result := storetest.NewDKGResult(t)
+		if hash != "0x01" {
+			result.Bks[callerID.String()] = result.Bks["peer-b"]
+			delete(result.Bks, "peer-b")
+		}
+		if err := storeDB.SaveDKGResultData(hash, result); err != nil {
+			t.Fatal(err)
+		}
+		pubkeys[hash] = hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey()))
+	}
+
+	call := func(method string, request proto.Message) error {
+		data, err := proto.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = server.MsgToPeer(context.Background(), caller, server.PeerArgs{
+			PeerAddrTarget: node.Addrs()[0].String() + "/p2p/" + nodeID.String(),
+			SvcName:        "TssPeerService",
+			SvcMethod:      method,
+			Args:           server.PingArgs{Data: data},
+		})
+		return err
+	}
+	for _, hash := range []string{"0x01", "0x02"} {
+		destroy := &pb.KeyStateRequest{Hash: hash, Pubkey: pubkeys[hash], State: string(types.KeyStateDestroyed)}
+		rollback := &pb.RollbackRequest{Hash: hash, Pubkey: pubkeys[hash], Epoch: 1}
+		for method, request := range map[string]proto.Message{
+			"PrepareKeyState": destroy, "CommitKeyState": destroy,
+			"PrepareRollback": rollback, "CommitRollback": rollback,
+		} {
+			err := call(method, request)
+			if refused := err != nil && strings.Contains(err.Error(), "does not hold"); refused != (hash == "0x01") {
+				t.Fatalf("%s on %s: got %v", method, hash, err)
+			}
+		}
+	}
+	record, err := storeDB.GetDKGResultData("0x01")
+	if err != nil || record.State != types.KeyStateActive {
+		t.Fatalf("got %+v, %v", record, err)
+	}
+
+	// 0x02 is destroyed by now.
+	if err := storeDB.SetKeyState("0x03", types.KeyStateFrozen); err != nil {
+		t.Fatal(err)
+	}
+	for hash, want := range map[string]string{"0x01": "does not hold", "0x02": "is destroyed", "0x03": "is frozen"} {
+		err := call("Reshare", &pb.ReshareRequest{Hash: hash, Pubkey: pubkeys[hash], Epoch: 1, TargetEpoch: 2})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("reshare of %s: got %v, want %q", hash, err, want)
+		}
+	}
+
+	// Signings run in the session the initiator names.
+	err = call("SignMessage", &pb.SignRequest{Hash: "0x02", Pubkey: pubkeys["0x02"], Message: "68656c6c6f", Initiator: callerID.String()})
+	if err == nil || !strings.Contains(err.Error(), "no session ID") {
+		t.Fatalf("got %v for a signing without a session", err)
+	}
+}
+
+// TestPeerCallsStopWithContext checks that calling a peer that is down stops
+// retrying once the context of the session is cancelled.
+func TestPeerCallsStopWithContext(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	node, nodeID, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+	deadKey, _ := crypto.GenerateKey()
+	dead, deadID, err := peer.MakeBasicHost(0, deadKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addrs()[0].String()
+	dead.Close()
+
+	pm := peer.NewPeerManager(nodeID.String(), node, peer.ProtocolId)
+	pm.AddPeerID(deadID, deadAddr)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = server.RpcToPeer(pm, "TssPeerService", "RegisterDKG", []byte("0x01"))(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline of the context", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("the call stopped %s after it started", elapsed)
+	}
+}
+
+// TestSessionsReleasedWhenPeersFail checks that a signing whose peers cannot
+// be called leaves neither its stream handler nor a gap in the ledger.
+func TestSessionsReleasedWhenPeersFail(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	node, nodeID, err := peer.MakeBasicHost(0, nodeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+	otherKey, _ := crypto.GenerateKey()
+	other, otherID, err := peer.MakeBasicHost(0, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	result := storetest.NewDKGResult(t)
+	result.Bks[nodeID.String()] = result.Bks["peer-a"]
+	result.Bks[otherID.String()] = result.Bks["peer-b"]
+	delete(result.Bks, "peer-a")
+	delete(result.Bks, "peer-b")
+	if err := storeDB.SaveDKGResultData("0x01", result); err != nil {
+		t.Fatal(err)
+	}
+	pm := peer.NewPeerManager(nodeID.String(), node, peer.ProtocolId)
+	pm.AddPeerID(otherID, other.Addrs()[0].String())
+
+	caller := &server.TssCaller{StoreDB: storeDB}
+	_, err = caller.SignMessage(context.Background(), pm, &pb.SignRequest{
+		Hash:      "0x01",
+		Pubkey:    hex.EncodeToString(crypto.CompressPubkey(result.PublicKey.ToPubKey())),
+		Message:   "68656c6c6f",
+		SessionId: "0xsession",
+	}, func(context.Context) error {
+		return errors.New("peers down")
+	})
+	if err == nil || err.Error() != "peers down" {
+		t.Fatalf("got %v, want the error of the peers", err)
+	}
+	if slices.Contains(node.Mux().Protocols(), peer.GetProtocol("0xsession")) {
+		t.Fatal("the stream handler of the session is still set")
+	}
+	page, err := store.QueryLedger(storeDB, types.LedgerQuery{KeyHash: "0x01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := page.Entries
+	if len(entries) != 1 || entries[0].Outcome != types.LedgerOutcomeFailed || entries[0].Error != "peers down" {
+		t.Fatalf("unexpected ledger %+v", entries)
+	}
+}
@@ -0,0 +1,180 @@
+// Package tsserr types the failures that clients of a node can act on. Each
+// Code maps to a stable JSON-RPC error code, with the code and the details of
+// the error as data, and to a gRPC status carrying them in an ErrorInfo.
+package tsserr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code is the type of a failure.
+type Code string
+
+const (
+	KeyNotFound         Code = "KEY_NOT_FOUND"
+	PubkeyMismatch      Code = "PUBKEY_MISMATCH"
+	InsufficientPeers   Code = "INSUFFICIENT_PEERS"
+	PeerUnreachable     Code = "PEER_UNREACHABLE"
+	ProtocolFailed      Code = "PROTOCOL_FAILED"
+	Timeout             Code = "TIMEOUT"
+	Unauthorized        Code = "UNAUTHORIZED"
+	PermissionDenied    Code = "PERMISSION_DENIED"
+	InvalidInput        Code = "INVALID_INPUT"
+	IdempotencyConflict Code = "IDEMPOTENCY_CONFLICT"
+	SignatureNotFound   Code = "SIGNATURE_NOT_FOUND"
+	AccessRuleNotFound  Code = "ACCESS_RULE_NOT_FOUND"
+	JobNotFound         Code = "JOB_NOT_FOUND"
+	DeliveryNotFound    Code = "WEBHOOK_DELIVERY_NOT_FOUND"
+	InvalidKeyState     Code = "INVALID_KEY_STATE"
+	EpochMismatch       Code = "EPOCH_MISMATCH"
+)
+
+// Domain is the domain of the ErrorInfo of the gRPC statuses.
+const Domain = "alice-tss"
+
+// mapping is how a code is reported over each API. The JSON-RPC codes are
+// part of the API: never change one.
+var mapping = map[Code]struct {
+	rpc  int
+	grpc codes.Code
+}{
+	Unauthorized:        {-32001, codes.Unauthenticated},
+	KeyNotFound:         {-32002, codes.NotFound},
+	PubkeyMismatch:      {-32003, codes.InvalidArgument},
+	InsufficientPeers:   {-32004, codes.Unavailable},
+	PeerUnreachable:     {-32005, codes.Unavailable},
+	ProtocolFailed:      {-32006, codes.Aborted},
+	Timeout:             {-32007, codes.DeadlineExceeded},
+	PermissionDenied:    {-32008, codes.PermissionDenied},
+	IdempotencyConflict: {-32009, codes.AlreadyExists},
+	SignatureNotFound:   {-32010, codes.NotFound},
+	AccessRuleNotFound:  {-32011, codes.NotFound},
+	JobNotFound:         {-32012, codes.NotFound},
+	DeliveryNotFound:    {-32013, codes.NotFound},
+	InvalidKeyState:     {-32014, codes.FailedPrecondition},
+	EpochMismatch:       {-32015, codes.FailedPrecondition},
+	InvalidInput:        {-32602, codes.InvalidArgument},
+}
+
+// Codes returns every code, in the order of their JSON-RPC codes.
+func Codes() []Code {
+	return []Code{Unauthorized, KeyNotFound, PubkeyMismatch, InsufficientPeers, PeerUnreachable,
+		ProtocolFailed, Timeout, PermissionDenied, IdempotencyConflict, SignatureNotFound, AccessRuleNotFound,
+		JobNotFound, DeliveryNotFound, InvalidKeyState, EpochMismatch, InvalidInput}
+}
+
+// RPCCode returns the JSON-RPC error code of c.
+func (c Code) RPCCode() int {
+	return mapping[c].rpc
+}
+
+// GRPCCode returns the gRPC status code of c.
+func (c Code) GRPCCode() codes.Code {
+	if m, ok := mapping[c]; ok {
+		return m.grpc
+	}
+	return codes.Unknown
+}
+
+// Error is a failure of type Code. Details are the values a client may need
+// to handle it, such as the hash of the key that was not found.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]string
+	err     error
+}
+
+// New returns an error of code, formatted like fmt.Errorf: it wraps the
+// errors given with %w.
+func New(code Code, format string, args ...interface{}) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Code: code, Message: err.Error(), err: err}
+}
+
+// With sets the detail key of e to value, and returns e.
+func (e *Error) With(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Data is the data of the JSON-RPC errors.
+type Data struct {
+	Type    Code              `json:"type"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Data returns the JSON-RPC error data of e.
+func (e *Error) Data() Data {
+	return Data{Type: e.Code, Details: e.Details}
+}
+
+// GRPCStatus returns the gRPC status of e, with an ErrorInfo whose reason is
+// the code of e and whose metadata are its details.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code.GRPCCode(), e.Message)
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   string(e.Code),
+		Domain:   Domain,
+		Metadata: e.Details,
+	})
+	if err != nil {
+		return st
+	}
+	return withInfo
+}
+
+// From returns the typed error in the chain of err, with the message of err,
+// or nil when there is none. Deadlines exceeded are timeouts.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var typed *Error
+	switch {
+	case errors.As(err, &typed):
+		if typed == err {
+			return typed
+		}
+		return &Error{Code: typed.Code, Message: err.Error(), Details: typed.Details, err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: Timeout, Message: err.Error(), err: err}
+	}
+	return nil
+}
+
+// CodeOf returns the code of the typed error in the chain of err, or "".
+func CodeOf(err error) Code {
+	if typed := From(err); typed != nil {
+		return typed.Code
+	}
+	return ""
+}
+
+// FromStatus returns the typed error of a gRPC status returned by a node, or
+// nil when the status has no ErrorInfo of Domain.
+func FromStatus(st *status.Status) *Error {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			return &Error{Code: Code(info.Reason), Message: st.Message(), Details: info.Metadata, err: st.Err()}
+		}
+	}
+	return nil
+}
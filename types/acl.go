@@ -0,0 +1,90 @@
+package types
+
+import "slices"
+
+// Role is a set of permissions given to an API caller.
+type Role string
+
+const (
+	// RoleAdmin may do anything, with any key, including DKG, resharing and
+	// the admin service.
+	RoleAdmin Role = "admin"
+	// RoleSigner signs with, and reads, the keys of its access rule.
+	RoleSigner Role = "signer"
+	// RoleReader reads the keys of its access rule, their signatures and
+	// their ledger.
+	RoleReader Role = "reader"
+)
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleSigner, RoleReader:
+		return true
+	}
+	return false
+}
+
+// Permission is what an API method needs from its caller.
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"
+	PermissionSign  Permission = "sign"
+	PermissionAdmin Permission = "admin"
+)
+
+// Grants reports whether r includes permission.
+func (r Role) Grants(permission Permission) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleSigner:
+		return permission == PermissionSign || permission == PermissionRead
+	case RoleReader:
+		return permission == PermissionRead
+	}
+	return false
+}
+
+// AllKeys in the keys of an access rule matches every key.
+const AllKeys = "*"
+
+// AccessRule says what the API caller Subject may do: its roles, and the
+// hashes of the keys it may use. Admins may use every key. Subject is
+// qualified by the authentication method of the caller, e.g. "jwt:alice".
+type AccessRule struct {
+	Subject   string   `json:"subject"`
+	Roles     []Role   `json:"roles"`
+	Keys      []string `json:"keys"`
+	UpdatedAt int64    `json:"updatedAt,omitempty"`
+}
+
+// IsAdmin reports whether the rule has the admin role.
+func (r *AccessRule) IsAdmin() bool {
+	return slices.Contains(r.Roles, RoleAdmin)
+}
+
+// Grants reports whether one of the roles of the rule includes permission.
+func (r *AccessRule) Grants(permission Permission) bool {
+	for _, role := range r.Roles {
+		if role.Grants(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUse reports whether the rule lets its subject use the key hash.
+func (r *AccessRule) CanUse(hash string) bool {
+	return r.IsAdmin() || slices.Contains(r.Keys, AllKeys) || slices.Contains(r.Keys, hash)
+}
+
+// Allows reports whether the rule grants permission on the key hash. An empty
+// hash asks for the permission alone.
+func (r *AccessRule) Allows(permission Permission, hash string) bool {
+	if !r.Grants(permission) {
+		return false
+	}
+	return hash == "" || r.CanUse(hash)
+}
@@ -1,19 +1,184 @@
 package types
 
+import "time"
+
 type StoreType string
 
 const (
+	StoreTypeMemory StoreType = "memory"
+	// StoreTypeMock is the former name of StoreTypeMemory.
 	StoreTypeMock   StoreType = "mock"
 	StoreTypeBadger StoreType = "badger"
+	StoreTypeSQLite StoreType = "sqlite"
 )
 
 type StoreConfig struct {
 	Type StoreType
 	Path string
+	// GCInterval is how often badger value log GC runs, 10m by default.
+	// A negative interval disables it.
+	GCInterval time.Duration
+	// BackupDir is where admin backups are written. Backups are disabled
+	// when it is empty.
+	BackupDir string
+	// KEK selects the key that encrypts shares at rest.
+	KEK KEKConfig
+}
+
+type KEKType string
+
+const (
+	// KEKTypeNodeKey derives the share encryption key from the node identity
+	// key. It is the default, and how shares were always encrypted.
+	KEKTypeNodeKey KEKType = "node-key"
+	// KEKTypeKeyFile uses a random AES-256 key kept in a separate file.
+	KEKTypeKeyFile KEKType = "key-file"
+	// KEKTypePKCS11 encrypts shares inside a PKCS#11 token.
+	KEKTypePKCS11 KEKType = "pkcs11"
+)
+
+// KEKConfig configures the key encryption provider of a store.
+type KEKConfig struct {
+	Type KEKType
+	// Path is the key file of the key-file provider.
+	Path   string
+	PKCS11 PKCS11Config
+}
+
+// PKCS11Config locates an AES-256 secret key on a PKCS#11 token.
+type PKCS11Config struct {
+	// Library is the path of the PKCS#11 module, e.g. libsofthsm2.so.
+	Library    string
+	TokenLabel string
+	Pin        string
+	KeyLabel   string
+}
+
+// AdminConfig enables admin capabilities that are off by default.
+type AdminConfig struct {
+	// ExposeShares enables admin.GetSignerConfig, which returns the
+	// decrypted share of a key. It is meant for debugging only.
+	ExposeShares bool
+}
+
+// TLSConfig serves the JSON-RPC and gRPC APIs over TLS when CertFile and
+// KeyFile are set.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile verifies the client certificates used by mTLS
+	// authentication.
+	ClientCAFile string
+}
+
+// AuthConfig configures how callers of the JSON-RPC and gRPC APIs are
+// authenticated. A caller is accepted by the first method its credentials
+// match. Authentication is disabled when no method is configured.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig
+	JWT     JWTConfig
+	// MTLS accepts callers presenting a client certificate signed by
+	// tls.clientCAFile, identified by its common name.
+	MTLS           bool
+	SignedRequests SignedRequestConfig
+	// Admins are subjects with the admin role whatever the access rules in
+	// the store say, so that a fresh node can be administered. Like the
+	// subjects of access rules, they are qualified by an authentication
+	// method, e.g. "api-key:ops".
+	Admins []string
+}
+
+// APIKeyConfig is a static API key, sent in the X-API-Key header.
+type APIKeyConfig struct {
+	// Name identifies the caller using the key.
+	Name string
+	Key  string
+}
+
+// JWTConfig accepts bearer tokens signed with HS256/384/512 under Secret, or
+// ES256/384/512 under the EC public key in PublicKeyFile. Tokens must have a
+// subject and an expiry.
+type JWTConfig struct {
+	Secret        string
+	PublicKeyFile string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+}
+
+// SignedRequestConfig accepts requests signed, Sign-In-With-Ethereum style,
+// by one of Addresses.
+type SignedRequestConfig struct {
+	// Domain is the domain named in the signed message.
+	Domain    string
+	Addresses []string
+	// MaxAge bounds how old the issued-at time of a request may be, 5m by
+	// default.
+	MaxAge time.Duration
+}
+
+// WebhookConfig configures the HMAC-signed callbacks posted when jobs
+// finish. Webhooks are disabled when neither Secret nor Clients are set.
+type WebhookConfig struct {
+	// Secret signs the callbacks of requests that name a callback URL, and
+	// of clients without a secret of their own.
+	Secret  string
+	Clients []ClientWebhookConfig
+	// AllowedHosts, when set, are the only hosts that request callback URLs
+	// may point to.
+	AllowedHosts []string
+	// AllowPrivateHosts lets webhooks reach loopback, private, link-local and
+	// other non-public addresses, which are refused by default.
+	AllowPrivateHosts bool
+	// MaxAttempts bounds the deliveries of a callback, 10 by default.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, 5s by default and at
+	// least 1s. It doubles with every attempt, up to an hour.
+	RetryBackoff time.Duration
+}
+
+// ClientWebhookConfig is called for every job started by the API caller
+// Subject, qualified by its authentication method, e.g. "mtls:service-a".
+type ClientWebhookConfig struct {
+	Subject string
+	URL     string
+	Secret  string
+}
+
+// TimeoutConfig bounds the calls of the JSON-RPC, REST and gRPC APIs by
+// class, and the shutdown of the node. Zero durations take the defaults.
+// Progress streams are not bounded.
+type TimeoutConfig struct {
+	// Query bounds the calls that read or cancel keys, jobs and the ledger,
+	// 5s by default. The ledger export is streamed for as long as it takes,
+	// and Query only bounds each of its writes.
+	Query time.Duration
+	// Session bounds the calls that start signing, DKG and resharing, 2m by
+	// default. REST and gRPC calls wait for their session.
+	Session time.Duration
+	// Admin bounds the admin calls, 30s by default: rollbacks and key state
+	// changes ask every holder of the key.
+	Admin time.Duration
+	// ReadHeader bounds reading the headers of an HTTP request, 10s by
+	// default.
+	ReadHeader time.Duration
+	// Idle closes idle HTTP keep-alive connections, after 2m by default.
+	Idle time.Duration
+	// Shutdown is how long a stopping node waits for the calls, jobs and
+	// sessions in flight before cancelling them, 30s by default.
+	Shutdown time.Duration
 }
 
+// AppConfig is the configuration file of a node. GRPC is the port of the gRPC
+// API, which is not served when it is zero.
 type AppConfig struct {
-	Port  int64
-	RPC   int
-	Store StoreConfig
+	Port     int64
+	RPC      int
+	GRPC     int
+	Store    StoreConfig
+	Admin    AdminConfig
+	TLS      TLSConfig
+	Auth     AuthConfig
+	Webhooks WebhookConfig
+	Timeouts TimeoutConfig
 }
@@ -0,0 +1,50 @@
+package types
+
+// HealthCheck is the outcome of one readiness check of a node.
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is whether a node can serve: its store is open, its libp2p host
+// listening, and it is connected to the peers that hold its active keys.
+type Readiness struct {
+	Ready  bool          `json:"ready"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// PeerStatus is a peer as a node sees it. Times are unix seconds.
+type PeerStatus struct {
+	ID        string   `json:"id"`
+	Addrs     []string `json:"addrs"`
+	Connected bool     `json:"connected"`
+	// LastSeen is when the node was last connected to the peer, zero when
+	// it was not since the node started.
+	LastSeen int64 `json:"lastSeen"`
+	// Local peers are the hosts of the self service, in the process of the
+	// node.
+	Local bool `json:"local,omitempty"`
+	// Keys are the active keys that the peer holds with the node.
+	Keys []string `json:"keys,omitempty"`
+}
+
+// SessionStatus is a protocol session running on a node. Initiator is set
+// when the node started it, rather than a peer.
+type SessionStatus struct {
+	Kind      JobKind `json:"kind"`
+	KeyHash   string  `json:"keyHash"`
+	Initiator bool    `json:"initiator"`
+	StartedAt int64   `json:"startedAt"`
+}
+
+// NodeStatus is the detailed status of a node, with its readiness.
+type NodeStatus struct {
+	Readiness
+	PeerID      string          `json:"peerId"`
+	ListenAddrs []string        `json:"listenAddrs"`
+	Peers       []PeerStatus    `json:"peers"`
+	Sessions    []SessionStatus `json:"sessions"`
+	// Jobs are the jobs running on the node.
+	Jobs []Job `json:"jobs"`
+}
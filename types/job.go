@@ -0,0 +1,54 @@
+package types
+
+import "encoding/json"
+
+// JobKind is the operation a job runs.
+type JobKind string
+
+const (
+	JobKindDKG     JobKind = "dkg"
+	JobKindSign    JobKind = "sign"
+	JobKindReshare JobKind = "reshare"
+)
+
+// JobStatus is where a job is in its life. A job is created pending, runs,
+// and ends done, failed or cancelled.
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusDone      JobStatus = "done"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// Finished reports whether a job in status s is over.
+func (s JobStatus) Finished() bool {
+	return s == JobStatusDone || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// Job is an operation started through the API, which runs in the background
+// and is read back by ID. Owner is the caller that started it, e.g.
+// "jwt:alice". State is the protocol state of its session, Result is set once
+// it is done and Error once it failed or was cancelled, with the tsserr code
+// of typed failures as ErrorType. The job is posted to
+// CallbackURL once it is over. Jobs started with an idempotency key keep the
+// Fingerprint of their request, to tell retries from other requests. Times
+// are unix seconds.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        JobKind         `json:"kind"`
+	Status      JobStatus       `json:"status"`
+	State       string          `json:"state,omitempty"`
+	KeyHash     string          `json:"keyHash,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	CallbackURL string          `json:"callbackUrl,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ErrorType   string          `json:"errorType,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	CreatedAt   int64           `json:"createdAt"`
+	UpdatedAt   int64           `json:"updatedAt"`
+	FinishedAt  int64           `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,29 @@
+package types
+
+// ProgressEventType is what a progress event reports.
+type ProgressEventType string
+
+const (
+	// ProgressJob carries the job as it is when a client subscribes.
+	ProgressJob ProgressEventType = "job"
+	// ProgressState reports a protocol state change of the session.
+	ProgressState ProgressEventType = "state"
+	// ProgressMessage reports that the message of a round arrived from a peer.
+	ProgressMessage ProgressEventType = "message"
+	// ProgressResult carries the finished job. It is the last event.
+	ProgressResult ProgressEventType = "result"
+)
+
+// ProgressEvent is a step of a protocol session, streamed to the clients that
+// follow it. Peer and Message are the sender and round type of a message, and
+// Job is set on job and result events. Time is in unix milliseconds.
+type ProgressEvent struct {
+	Type     ProgressEventType `json:"type"`
+	JobID    string            `json:"jobId,omitempty"`
+	Time     int64             `json:"time"`
+	OldState string            `json:"oldState,omitempty"`
+	State    string            `json:"state,omitempty"`
+	Peer     string            `json:"peer,omitempty"`
+	Message  string            `json:"message,omitempty"`
+	Job      *Job              `json:"job,omitempty"`
+}
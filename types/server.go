@@ -1,7 +1,9 @@
 package types
 
-type RpcDataArgs struct {
-	Data interface{}
+// RpcArgs are the params of the JSON-RPC methods that take a request as
+// data: {"data": {...}}.
+type RpcArgs[T any] struct {
+	Data T `json:"data"`
 }
 
 type RpcKeyArgs struct {
@@ -11,10 +13,119 @@ type RpcKeyArgs struct {
 type RpcNoneArgs struct {
 }
 
-type RpcDataReply struct {
-	Data interface{}
+// RpcReply is the result of the JSON-RPC methods: {"Data": ...}.
+type RpcReply[T any] struct {
+	Data T
 }
 
 type RpcMessageReply struct {
 	Message string
 }
+
+// JobOptions are the options that every request starting a job accepts
+// next to its own data. CallbackURL gets the job once it is over. A request
+// with the IdempotencyKey of an earlier request of the same client returns
+// the job of the earlier one instead of starting another, so that retries
+// are safe.
+type JobOptions struct {
+	CallbackURL    string `json:"callbackUrl"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// Options returns o, and makes the requests that embed JobOptions JobRequests.
+func (o JobOptions) Options() JobOptions {
+	return o
+}
+
+// JobRequest is the data of a request starting a job.
+type JobRequest interface {
+	Options() JobOptions
+}
+
+// BackupRequest names the file an admin backup is written to, inside the
+// configured backup directory. An empty name picks one from the current time.
+type BackupRequest struct {
+	Name string `json:"name"`
+}
+
+// BackupStatus describes the last admin backup. Times are unix seconds.
+type BackupStatus struct {
+	Path       string `json:"path"`
+	Running    bool   `json:"running"`
+	Bytes      int64  `json:"bytes"`
+	StartedAt  int64  `json:"startedAt"`
+	FinishedAt int64  `json:"finishedAt,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// KeyRequest selects a key by its hash, and the public key it must have.
+type KeyRequest struct {
+	Hash   string `json:"hash"`
+	Pubkey string `json:"pubkey"`
+}
+
+// SignRequest is the data of signer.SignMessage and signer.SelfSignMessage.
+// Epoch is the share epoch to sign with, zero for the current one.
+type SignRequest struct {
+	Hash    string `json:"hash"`
+	Pubkey  string `json:"pubkey"`
+	Message string `json:"message"`
+	Epoch   uint32 `json:"epoch"`
+	JobOptions
+}
+
+// DKGRequest is the data of signer.RegisterDKG and signer.RegisterSelfDKG.
+type DKGRequest struct {
+	JobOptions
+}
+
+// ReshareRequest is the data of signer.Reshare. Epoch is the share epoch to
+// reshare from, zero for the current one.
+type ReshareRequest struct {
+	Hash   string `json:"hash"`
+	Pubkey string `json:"pubkey"`
+	Epoch  uint32 `json:"epoch"`
+	JobOptions
+}
+
+// CheckSignatureRequest is the data of signer.CheckSignature: the message
+// whose stored signature is checked against pubkey. SessionID picks the
+// signature of one session; empty picks the latest signature of the message
+// under pubkey.
+type CheckSignatureRequest struct {
+	Message   string `json:"message"`
+	Pubkey    string `json:"pubkey"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// RollbackRequest is the data of admin.RollbackEpoch.
+type RollbackRequest struct {
+	Hash   string `json:"hash"`
+	Pubkey string `json:"pubkey"`
+	Epoch  uint32 `json:"epoch"`
+}
+
+// KeyStateRequest is the data of admin.SetKeyState.
+type KeyStateRequest struct {
+	Hash   string   `json:"hash"`
+	Pubkey string   `json:"pubkey"`
+	State  KeyState `json:"state"`
+}
+
+// KeyStateReply is the result of admin.SetKeyState.
+type KeyStateReply struct {
+	Hash  string   `json:"hash"`
+	State KeyState `json:"state"`
+}
+
+// RollbackReply is the result of admin.RollbackEpoch.
+type RollbackReply struct {
+	Hash  string `json:"hash"`
+	Epoch uint32 `json:"epoch"`
+}
+
+// WebhookQuery is the data of admin.ListWebhookDeliveries. An empty status
+// selects every delivery.
+type WebhookQuery struct {
+	Status WebhookStatus `json:"status"`
+}
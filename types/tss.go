@@ -16,6 +16,8 @@ type SignerConfig struct {
 	Share  string        `json:"share"`
 	Pubkey Pubkey        `json:"pubkey"`
 	BKs    map[string]BK `json:"bks"`
+	Epoch  uint32        `json:"epoch"`
+	State  KeyState      `json:"state"`
 }
 
 type DKGConfig struct {
@@ -29,6 +31,137 @@ type DKGResult struct {
 	PublicKey string         `json:"publicKey"`
 	Address   common.Address `json:"address"`
 	BKs       map[string]BK  `json:"bks"`
+	Epoch     uint32         `json:"epoch"`
+	CreatedAt int64          `json:"createdAt,omitempty"`
+	State     KeyState       `json:"state"`
+}
+
+// KeyView is the public view of a key, without any share material. It is what
+// the signing API returns for a key.
+type KeyView struct {
+	Hash      string         `json:"hash"`
+	PublicKey string         `json:"publicKey"`
+	Pubkey    Pubkey         `json:"pubkey"`
+	Address   common.Address `json:"address"`
+	BKs       map[string]BK  `json:"bks"`
+	Epoch     uint32         `json:"epoch"`
+	CreatedAt int64          `json:"createdAt,omitempty"`
+	State     KeyState       `json:"state"`
+}
+
+// View returns the public view of the key stored as r under hash.
+func (r *DKGResult) View(hash string) *KeyView {
+	return &KeyView{
+		Hash:      hash,
+		PublicKey: r.PublicKey,
+		Pubkey:    r.Pubkey,
+		Address:   r.Address,
+		BKs:       r.BKs,
+		Epoch:     r.Epoch,
+		CreatedAt: r.CreatedAt,
+		State:     r.State,
+	}
+}
+
+// KeyState is the lifecycle state of a key. A key is created active, can be
+// frozen and unfrozen, and is eventually retired and destroyed.
+type KeyState string
+
+const (
+	// KeyStateActive keys sign and reshare.
+	KeyStateActive KeyState = "active"
+	// KeyStateFrozen keys are suspended until they are made active again.
+	KeyStateFrozen KeyState = "frozen"
+	// KeyStateRetired keys are kept for the record but never used again.
+	KeyStateRetired KeyState = "retired"
+	// KeyStateDestroyed keys have had their shares erased. The badger store
+	// may keep the sealed shares on disk; the SQLite store overwrites them.
+	KeyStateDestroyed KeyState = "destroyed"
+)
+
+// KeyOperation is an operation whose use of a key depends on its state.
+type KeyOperation string
+
+const (
+	KeyOperationSign     KeyOperation = "sign"
+	KeyOperationReshare  KeyOperation = "reshare"
+	KeyOperationRollback KeyOperation = "rollback"
+)
+
+// Valid reports whether s is a known state.
+func (s KeyState) Valid() bool {
+	switch s {
+	case KeyStateActive, KeyStateFrozen, KeyStateRetired, KeyStateDestroyed:
+		return true
+	}
+	return false
+}
+
+// Allows reports whether a key in state s may be used for op. Only active keys
+// sign and reshare; a frozen key can still be rolled back.
+func (s KeyState) Allows(op KeyOperation) bool {
+	switch op {
+	case KeyOperationSign, KeyOperationReshare:
+		return s == KeyStateActive
+	case KeyOperationRollback:
+		return s == KeyStateActive || s == KeyStateFrozen
+	}
+	return false
+}
+
+// CanBecome reports whether a key may move from state s to next. Retiring and
+// destroying are one way; staying in the same state is always allowed, so that
+// a change can be applied again on nodes that missed it.
+func (s KeyState) CanBecome(next KeyState) bool {
+	if !next.Valid() {
+		return false
+	}
+	if s == next {
+		return true
+	}
+	switch s {
+	case KeyStateActive, KeyStateFrozen:
+		return true
+	case KeyStateRetired:
+		return next == KeyStateDestroyed
+	}
+	return false
+}
+
+// ShareEpoch is the encrypted share a node held for a key after DKG (epoch 1)
+// or after a reshare. Older epochs are kept so that a key can be rolled back.
+type ShareEpoch struct {
+	Epoch     uint32        `json:"epoch"`
+	Share     string        `json:"share"`
+	BKs       map[string]BK `json:"bks"`
+	CreatedAt int64         `json:"createdAt"`
+}
+
+// KeyFilter selects the keys returned by a key listing. Empty fields match
+// everything; CreatedAfter and CreatedBefore are unix seconds, inclusive.
+type KeyFilter struct {
+	Address       string   `json:"address"`
+	Pubkey        string   `json:"pubkey"`
+	CreatedAfter  int64    `json:"createdAfter"`
+	CreatedBefore int64    `json:"createdBefore"`
+	State         KeyState `json:"state"`
+	Cursor        string   `json:"cursor"`
+	Limit         int      `json:"limit"`
+}
+
+// KeySummary is the public part of a DKG result, as returned by key listings.
+type KeySummary struct {
+	Hash      string         `json:"hash"`
+	PublicKey string         `json:"publicKey"`
+	Address   common.Address `json:"address"`
+	CreatedAt int64          `json:"createdAt"`
+	State     KeyState       `json:"state"`
+}
+
+// KeyPage is one page of a key listing. NextCursor is empty on the last page.
+type KeyPage struct {
+	Keys       []KeySummary `json:"keys"`
+	NextCursor string       `json:"nextCursor,omitempty"`
 }
 
 type ReshareConfig struct {
@@ -36,6 +169,8 @@ type ReshareConfig struct {
 	Share     string        `json:"share"`
 	Pubkey    Pubkey        `json:"pubkey"`
 	BKs       map[string]BK `json:"bks"`
+	// Epoch is the share epoch the reshared share is stored as.
+	Epoch uint32 `json:"epoch"`
 }
 
 type ReshareResult struct {
@@ -43,7 +178,72 @@ type ReshareResult struct {
 }
 
 type RVSignature struct {
-	R    string `json:"r"`
-	S    string `json:"s"`
-	Hash string `json:"hash"`
+	R         string `json:"r"`
+	S         string `json:"s"`
+	Hash      string `json:"hash"`
+	SessionID string `json:"sessionId,omitempty"`
+	// KeyHash is the key that signed, unset in signatures of older builds.
+	KeyHash string `json:"keyHash,omitempty"`
+}
+
+const (
+	LedgerOutcomeSigned = "signed"
+	LedgerOutcomeFailed = "failed"
+)
+
+// LedgerEntry records one signing session on this node. Entries are never
+// overwritten, so signing the same message twice leaves two entries. Times are
+// unix seconds.
+type LedgerEntry struct {
+	ID           string   `json:"id"`
+	KeyHash      string   `json:"keyHash"`
+	Pubkey       string   `json:"pubkey"`
+	Digest       string   `json:"digest"`
+	R            string   `json:"r,omitempty"`
+	S            string   `json:"s,omitempty"`
+	Participants []string `json:"participants"`
+	Initiator    string   `json:"initiator"`
+	StartedAt    int64    `json:"startedAt"`
+	FinishedAt   int64    `json:"finishedAt"`
+	Outcome      string   `json:"outcome"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// LedgerQuery selects ledger entries by key, message digest and start time.
+// Empty fields match everything; From and To are unix seconds, inclusive.
+// Cursor is the NextCursor of the previous page.
+type LedgerQuery struct {
+	KeyHash string `json:"keyHash"`
+	Digest  string `json:"digest"`
+	From    int64  `json:"from"`
+	To      int64  `json:"to"`
+	Cursor  string `json:"cursor"`
+	Limit   int    `json:"limit"`
+}
+
+// LedgerPage is one page of a ledger query. NextCursor is empty on the last page.
+type LedgerPage struct {
+	Entries    []LedgerEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// ExportedKey is a DKG result with every share epoch in plaintext, as carried
+// by an encrypted key bundle. It must never be stored or sent unencrypted.
+type ExportedKey struct {
+	Hash      string          `json:"hash"`
+	PublicKey string          `json:"publicKey"`
+	Pubkey    Pubkey          `json:"pubkey"`
+	Address   common.Address  `json:"address"`
+	CreatedAt int64           `json:"createdAt"`
+	Epoch     uint32          `json:"epoch"`
+	State     KeyState        `json:"state"`
+	Shares    []ExportedShare `json:"shares"`
+}
+
+// ExportedShare is one share epoch of an ExportedKey. Share is decimal.
+type ExportedShare struct {
+	Epoch     uint32        `json:"epoch"`
+	Share     string        `json:"share"`
+	BKs       map[string]BK `json:"bks"`
+	CreatedAt int64         `json:"createdAt"`
 }
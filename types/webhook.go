@@ -0,0 +1,39 @@
+package types
+
+import "encoding/json"
+
+// WebhookStatus is where the delivery of a callback is.
+type WebhookStatus string
+
+const (
+	WebhookStatusPending   WebhookStatus = "pending"
+	WebhookStatusDelivered WebhookStatus = "delivered"
+	// WebhookStatusFailed deliveries ran out of attempts.
+	WebhookStatusFailed WebhookStatus = "failed"
+)
+
+// WebhookEvent is the JSON body posted to a webhook.
+type WebhookEvent struct {
+	// ID identifies the delivery, so that receivers can drop duplicates.
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Job   *Job   `json:"job"`
+}
+
+// WebhookDelivery is a callback to post, kept until it is delivered or runs
+// out of attempts. Client is the subject of the client webhook it comes
+// from, empty for the callback URL of a request. Times are unix seconds.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	JobID         string          `json:"jobId"`
+	URL           string          `json:"url"`
+	Client        string          `json:"client,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        WebhookStatus   `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt int64           `json:"nextAttemptAt,omitempty"`
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     int64           `json:"createdAt"`
+	UpdatedAt     int64           `json:"updatedAt"`
+	DeliveredAt   int64           `json:"deliveredAt,omitempty"`
+}
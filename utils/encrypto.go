@@ -3,9 +3,36 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
 
 	"github.com/getamis/sirius/log"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// envelopeV1 prefixes AES-256-GCM envelopes produced by Seal. The ':' is
+	// outside the base64 alphabet, so sealed values never collide with the
+	// legacy AES-CFB output of Encrypt.
+	envelopeV1 = "v1:"
+
+	envelopeSaltSize = 16
+	envelopeKeySize  = 32
+)
+
+var envelopeInfo = []byte("alice-tss share encryption v1")
+
+var (
+	// ErrUnsupportedEnvelope is returned when a value was not produced by Seal.
+	ErrUnsupportedEnvelope = errors.New("unsupported envelope version")
+	// ErrCorruptedEnvelope is returned when an envelope fails authentication,
+	// either because it was tampered with or because the key is wrong.
+	ErrCorruptedEnvelope = errors.New("envelope is corrupted or sealed with another key")
 )
 
 func B64Encode(b []byte) string {
@@ -22,6 +49,9 @@ func B64Decode(s string) []byte {
 }
 
 // Encrypt method is to encrypt or hide any classified text
+//
+// Deprecated: Encrypt is unauthenticated. It is kept only to migrate records
+// written before Seal existed; use Seal for new data.
 func Encrypt(text string, secret []byte, salt string) (string, error) {
 	block, err := aes.NewCipher(secret)
 	if err != nil {
@@ -36,6 +66,9 @@ func Encrypt(text string, secret []byte, salt string) (string, error) {
 }
 
 // Decrypt method is to extract back the encrypted text
+//
+// Deprecated: Decrypt cannot detect tampering. It is kept only to migrate
+// records written before Seal existed; use Open for new data.
 func Decrypt(text string, secret []byte, salt string) (string, error) {
 	block, err := aes.NewCipher(secret)
 	if err != nil {
@@ -47,3 +80,92 @@ func Decrypt(text string, secret []byte, salt string) (string, error) {
 	cfb.XORKeyStream(plainText, cipherText)
 	return string(plainText), nil
 }
+
+// IsSealed reports whether value is an envelope produced by Seal.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, envelopeV1)
+}
+
+// Seal encrypts plaintext into a versioned AES-256-GCM envelope. The key is
+// derived from secret with HKDF-SHA256 and a random per-envelope salt; aad is
+// authenticated but not stored, so the same aad must be given to Open.
+func Seal(plaintext, secret, aad []byte) (string, error) {
+	salt := make([]byte, envelopeSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+	aead, err := newEnvelopeAEAD(secret, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := make([]byte, 0, len(salt)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, plaintext, aad)
+	return envelopeV1 + B64Encode(out), nil
+}
+
+// Open authenticates and decrypts an envelope produced by Seal. It never
+// returns partially decrypted data: any tampering, truncation or wrong key
+// yields ErrCorruptedEnvelope.
+func Open(envelope string, secret, aad []byte) ([]byte, error) {
+	if !IsSealed(envelope) {
+		return nil, ErrUnsupportedEnvelope
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(envelope, envelopeV1))
+	if err != nil || len(raw) < envelopeSaltSize {
+		return nil, ErrCorruptedEnvelope
+	}
+	aead, err := newEnvelopeAEAD(secret, raw[:envelopeSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	raw = raw[envelopeSaltSize:]
+	if len(raw) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrCorruptedEnvelope
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrCorruptedEnvelope
+	}
+	return plaintext, nil
+}
+
+func newEnvelopeAEAD(secret, salt []byte) (cipher.AEAD, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty envelope secret")
+	}
+	key := make([]byte, envelopeKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, envelopeInfo), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Zero overwrites b, so that secrets do not linger in memory once used.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// ZeroInt overwrites the words of x and sets it to 0.
+func ZeroInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+}
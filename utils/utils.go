@@ -55,7 +55,7 @@ func ConvertDKGResult(cfgPubkey types.Pubkey, cfgShare string, cfgBKs map[string
 	// Build share.
 	share, ok := new(big.Int).SetString(cfgShare, 10)
 	if !ok {
-		log.Error("Cannot convert string to big int", "share", share)
+		log.Error("Cannot convert share to big int")
 		return nil, ErrConversion
 	}
 
@@ -149,3 +149,9 @@ func RandomHash() string {
 	}
 	return ToHexHash(append([]byte(timeNow.String()), randBytes...))
 }
+
+// SessionID returns the ID of a signing session of the key hash started by
+// initiator. The nonce makes every session of the same message distinct.
+func SessionID(initiator, keyHash, nonce string) string {
+	return ToHexHash([]byte(initiator + "/" + keyHash + "/" + nonce))
+}
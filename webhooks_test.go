@@ -0,0 +1,220 @@
+package main_test
+
+import (
+	"alice-tss/auth"
+	"alice-tss/peer"
+	"alice-tss/server"
+	"alice-tss/store"
+	"alice-tss/types"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestWebhooks fails a job left running by a previous run of the node, and
+// checks that its callback and the webhook of its owner get the signed event,
+// the callback after a retry.
+func TestWebhooks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string][]types.WebhookEvent{}
+		calls    = map[string]int{}
+	)
+	secrets := map[string]string{"/callback": "global-secret", "/client": "client-secret"}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(server.HeaderWebhookTimestamp), 10, 64)
+		if r.Header.Get(server.HeaderWebhookSignature) != server.SignWebhook(secrets[r.URL.Path], timestamp, body) {
+			t.Errorf("bad signature on %s", r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		calls[r.URL.Path]++
+		if r.URL.Path == "/callback" && calls[r.URL.Path] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event types.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.ID != r.Header.Get(server.HeaderWebhookID) {
+			t.Errorf("bad event %s (%v)", body, err)
+		}
+		received[r.URL.Path] = append(received[r.URL.Path], event)
+	}))
+	defer receiver.Close()
+
+	nodeKey, _ := crypto.GenerateKey()
+	storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storeDB.Defer()
+	if err := storeDB.SaveJob(&types.Job{
+		ID: "job-1", Kind: types.JobKindSign, Status: types.JobStatusRunning, KeyHash: "0x01",
+		Owner: "api-key:service-a", CallbackURL: receiver.URL + "/callback", CreatedAt: 1, UpdatedAt: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &types.AppConfig{Webhooks: types.WebhookConfig{
+		Secret: "global-secret",
+		Clients: []types.ClientWebhookConfig{
+			{Subject: "api-key:service-a", URL: receiver.URL + "/client", Secret: "client-secret"},
+			{Subject: "api-key:service-b", URL: receiver.URL + "/other"},
+		},
+		MaxAttempts:       3,
+		RetryBackoff:      time.Second,
+		AllowPrivateHosts: true,
+	}}
+	handler, err := server.NewRouter(config, nil, storeDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var deliveries []types.WebhookDelivery
+		data := rpcCall(t, handler, "", "admin.ListWebhookDeliveries", map[string]interface{}{"data": map[string]interface{}{"status": "delivered"}}, false)
+		if err := json.Unmarshal(data, &deliveries); err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %+v", deliveries)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["/callback"] != 2 || calls["/client"] != 1 || calls["/other"] != 0 {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	for path, events := range received {
+		if len(events) != 1 || events[0].Event != "job.failed" || events[0].Job.ID != "job-1" || events[0].Job.Status != types.JobStatusFailed {
+			t.Fatalf("unexpected events on %s: %+v", path, events)
+		}
+	}
+	deliveries, err := storeDB.ListWebhookDeliveries("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.URL == receiver.URL+"/callback" && (delivery.Attempts != 2 || delivery.LastError != "") {
+			t.Fatalf("unexpected callback delivery %+v", delivery)
+		}
+	}
+}
+
+// TestWebhookTargets checks that webhooks reach no private address unless
+// allowed, be it named by a callback URL or dialled, and follow no redirect.
+func TestWebhookTargets(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+		}
+	}))
+	defer receiver.Close()
+
+	failedDelivery := func(storeDB store.HandlerData) types.WebhookDelivery {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			deliveries, err := storeDB.ListWebhookDeliveries(types.WebhookStatusFailed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) == 1 {
+				return deliveries[0]
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("failed deliveries %+v", deliveries)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	newNode := func(callbackURL string, allowPrivate bool) (store.HandlerData, http.Handler) {
+		t.Helper()
+		nodeKey, _ := crypto.GenerateKey()
+		storeDB, err := store.NewMemoryDB(store.NewNodeKeyProvider(nodeKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(storeDB.Defer)
+		// left running by a previous run of the node
+		if err := storeDB.SaveJob(&types.Job{
+			ID: "job-1", Kind: types.JobKindSign, Status: types.JobStatusRunning, KeyHash: "0x01",
+			CallbackURL: callbackURL, CreatedAt: 1, UpdatedAt: 1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		host, pid, err := peer.MakeBasicHost(0, nodeKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { host.Close() })
+		handler, err := server.NewRouter(&types.AppConfig{
+			Auth: types.AuthConfig{APIKeys: []types.APIKeyConfig{{Name: "ops", Key: "ops-key"}}, Admins: []string{"api-key:ops"}},
+			Webhooks: types.WebhookConfig{
+				Secret:            "global-secret",
+				MaxAttempts:       1,
+				AllowPrivateHosts: allowPrivate,
+			},
+		}, peer.NewPeerManager(pid.String(), host, peer.ProtocolId), storeDB, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return storeDB, handler
+	}
+
+	// The receiver listens on a loopback address.
+	storeDB, handler := newNode(receiver.URL+"/private", false)
+	if delivery := failedDelivery(storeDB); !strings.Contains(delivery.LastError, "not public") {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+	for _, callbackURL := range []string{"http://169.254.169.254/latest/meta-data", "http://[::1]/tss", "http://10.0.0.1/tss", receiver.URL} {
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "signer.SignMessage", "id": "1",
+			"params": []interface{}{map[string]interface{}{"data": map[string]string{
+				"hash": "0x01", "pubkey": "02ab", "message": "68656c6c6f", "callbackUrl": callbackURL,
+			}}}})
+		req := httptest.NewRequest(http.MethodPost, "/tss", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.HeaderAPIKey, "ops-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if !strings.Contains(rec.Body.String(), "is not public") {
+			t.Fatalf("callback %s got %s", callbackURL, rec.Body.String())
+		}
+	}
+
+	storeDB, _ = newNode(receiver.URL+"/redirect", true)
+	if delivery := failedDelivery(storeDB); !strings.Contains(delivery.LastError, "redirects are not followed") {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["/private"] != 0 || calls["/redirect"] != 1 || calls["/target"] != 0 {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+}